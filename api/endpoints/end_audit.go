@@ -0,0 +1,82 @@
+package endpoints
+
+import (
+	"github.com/kataras/iris/v12"
+
+	"go.api.backend/api/middlewares"
+	"go.api.backend/schema"
+	"go.api.backend/schema/dto"
+	"go.api.backend/schema/mapper"
+	"go.api.backend/service"
+	"go.api.backend/service/utils"
+)
+
+type HAudit struct {
+	response *utils.SvcResponse
+	service *service.SvcAudit
+}
+
+// NewAuditHandler create and register the audit log handler and endpoints. The audit log is read only, its
+// entries are written by the services along with every mutation.
+//
+// - app [*iris.Application] ~ Iris App instance
+//
+// - pSvc [*service.SvcAudit] ~ Audit log service instance pointer
+//
+// - r [*utils.SvcResponse] ~ Response service instance
+//
+// - pPolicy [*middlewares.RoutePolicy] ~ Route protection policy
+func NewAuditHandler(app *iris.Application, pSvc *service.SvcAudit, r *utils.SvcResponse, pPolicy *middlewares.RoutePolicy) HAudit {
+
+	// --- VARS SETUP ---
+	h := HAudit{r, pSvc}
+	mdwAdminGuard := middlewares.NewRoleGuardMiddleware(r, schema.RolAdmin)			// reading the audit log requires the admin role
+
+	// --- REGISTERING ENDPOINTS ---
+	auditRouter := app.Party("/audit")
+	{
+		auditRouter.Get("/", pPolicy.Authed(mdwAdminGuard, h.getAudit)...)
+	}
+
+	return h
+}
+
+// region ======== ENDPOINT HANDLERS =====================================================
+
+// getAudit list the audit log entries of an entity type, or of a single entity, newest first
+// @Summary Get the audit log
+// @Description Get a page of audit log entries, newest first. Every entry tells who (access token subject) did what (action) to which entity, the entity before & after, and the request ID. It requires the admin role
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Insert access token" default(Bearer <Add access token here>)
+// @Tags Audit
+// @Produce json
+// @Param	entity	query	string	true	"Entity type"						Enums(book)
+// @Param	id		query	int		false	"Entity Id, all the entities if none"	Format(uint32)
+// @Param	page	query	int		false	"Page number, 1000000 at most"		Format(uint32)
+// @Param	limit	query	int		false	"Page size, 100 at most"			Format(uint32)
+// @Success 200 {object} dto.PageOut{data=[]models.AuditEntry} "Page of audit entries"
+// @Failure 401 {object} dto.ApiError "err.unauthorized"
+// @Failure 403 {object} dto.ApiError "err.forbidden"
+// @Failure 422 {object} dto.ApiError "err.invalid_data"
+// @Failure 500 {object} dto.ApiError "err.repo_ops"
+// @Router /audit [get]
+func (h HAudit) getAudit(ctx iris.Context) {
+	var qDto dto.AuditListIn
+
+	if e := ctx.ReadQuery(&qDto); e != nil {
+		(*h.response).ResErr(iris.StatusUnprocessableEntity, schema.ErrVal, e.Error(), &ctx) // 422 ReadQuery do the validation here
+		return
+	}
+
+	// Mapping & listing
+	opts, filter := mapper.ToAuditListV(&qDto)
+	list, total, err := (*h.service).GetAll(filter, opts)
+
+	// Preparing the response
+	if err != nil {
+		(*h.response).ResFromErr(err, &ctx)
+	} else {
+		(*h.response).ResWithDataStatus(iris.StatusOK, mkPage(ctx, list, nil, total, opts), &ctx)	// Offset pagination
+	}
+}
+// endregion =============================================================================
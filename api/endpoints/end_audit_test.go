@@ -0,0 +1,69 @@
+package endpoints
+
+import (
+	"testing"
+
+	"github.com/iris-contrib/httpexpect/v2"
+	"github.com/kataras/iris/v12"
+	"github.com/kataras/iris/v12/httptest"
+
+	"go.api.backend/schema"
+	"go.api.backend/schema/models"
+)
+
+func TestHAudit_Get(t *testing.T) {
+	seed := []models.Book{{Name: "Dune", Items: 30}, {Name: "Emma", Items: 10}}
+
+	runBookReqs(t, seed, []bookReq{
+		{
+			name: "list the entries of a book", method: "GET", path: "/audit", query: map[string]string{"entity": "book", "id": "1"},
+			auth: testToken(t, schema.RolAdmin), trashed: []uint{1}, wantStatus: iris.StatusOK,
+			check: func(e *httptest.Expect, res *httpexpect.Response) {
+				page := res.JSON().Object()
+				page.ValueEqual("Total", 2)
+
+				entry := page.Value("Data").Array().First().Object()	// Newest first
+				entry.ValueEqual("Action", schema.AuditDelete)
+				entry.ValueEqual("Actor", "tester")
+				entry.Value("RequestId").String().NotEmpty()
+				entry.Value("Before").Object().ValueEqual("Name", "Dune")
+				entry.Value("After").Null()
+
+				page.Value("Data").Array().Last().Object().ValueEqual("Actor", schema.AuditSystemActor)	// Seeded
+			},
+		},
+		{
+			name: "list the entries of all the books, paginated", method: "GET", path: "/audit",
+			query: map[string]string{"entity": "book", "limit": "1"}, auth: testToken(t, schema.RolAdmin), wantStatus: iris.StatusOK,
+			check: func(e *httptest.Expect, res *httpexpect.Response) {
+				page := res.JSON().Object()
+				page.ValueEqual("Total", 2)
+				page.Value("Data").Array().Length().Equal(1)
+				page.Value("Next").String().Contains("page=2")
+			},
+		},
+		{
+			name: "the request ID is recorded", method: "PUT", path: "/books/2", headers: map[string]string{"X-Request-ID": "req-42"},
+			auth: testToken(t, schema.RolUser, schema.ScopeBooksWrite), body: map[string]interface{}{"name": "Emma", "items": 11},
+			wantStatus: iris.StatusOK,
+			check: func(e *httptest.Expect, res *httpexpect.Response) {
+				res.Header("X-Request-Id").Equal("req-42")
+
+				entry := e.GET("/audit").WithQuery("entity", "book").WithQuery("id", 2).WithHeader("Authorization", testToken(t, schema.RolAdmin)).
+					Expect().Status(iris.StatusOK).JSON().Object().Value("Data").Array().First().Object()
+				entry.ValueEqual("Action", schema.AuditUpdate)
+				entry.ValueEqual("RequestId", "req-42")
+				entry.Value("After").Object().ValueEqual("Items", 11)
+			},
+		},
+		{name: "without a token", method: "GET", path: "/audit", query: map[string]string{"entity": "book"}, wantStatus: iris.StatusUnauthorized},
+		{
+			name: "without the admin role", method: "GET", path: "/audit", query: map[string]string{"entity": "book"},
+			auth: testToken(t, schema.RolUser, schema.ScopeBooksWrite), wantStatus: iris.StatusForbidden, wantTitle: schema.ErrForbidden,
+		},
+		{
+			name: "unknown entity", method: "GET", path: "/audit", query: map[string]string{"entity": "user"},
+			auth: testToken(t, schema.RolAdmin), wantStatus: iris.StatusUnprocessableEntity, wantTitle: schema.ErrVal,
+		},
+	})
+}
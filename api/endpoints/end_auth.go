@@ -4,12 +4,13 @@ import (
 	"github.com/kataras/iris/v12"
 	"github.com/kataras/iris/v12/context"
 	"github.com/kataras/iris/v12/hero"
-	"github.com/kataras/iris/v12/middleware/jwt"
 
-	"go.api.backend/lib"
+	"go.api.backend/api/middlewares"
 	"go.api.backend/schema"
 	"go.api.backend/schema/dto"
-	"go.api.backend/schema/mapper"
+	"go.api.backend/schema/errs"
+	"go.api.backend/schema/models"
+	"go.api.backend/service"
 	"go.api.backend/service/auth"
 	"go.api.backend/service/utils"
 )
@@ -18,10 +19,13 @@ type HAuth struct {
 	response *utils.SvcResponse
 	appConf *utils.SvcConfig
 	providers map[string]bool
+	users *service.SvcUser
+	tokens *auth.SvcToken
 }
 
-// NewAuthHandler create and register the authentication handlers for the App. For the moment, all the
-// auth handlers emulates the Oauth2 "password" grant-type using the "client-credentials" flow.
+// NewAuthHandler create and register the authentication handlers for the App. The auth handlers emulates the Oauth2
+// "password" grant-type using the "client-credentials" flow, or redirect the user to the provider login page
+// (authorization code flow, e.g. OpenID Connect) for the providers supporting it.
 //
 // - app [*iris.Application] ~ Iris App instance
 //
@@ -30,16 +34,18 @@ type HAuth struct {
 // - svcR [*utils.SvcResponse] ~ Response service instance
 //
 // - svcC [utils.SvcConfig] ~ Configuration service instance
-func NewAuthHandler (app *iris.Application, MdwAuthChecker *context.Handler, svcR *utils.SvcResponse, svcC *utils.SvcConfig) HAuth {
+//
+// - pUsers [*service.SvcUser] ~ Users service instance pointer, for the default (database) provider users management
+//
+// - pTokens [*auth.SvcToken] ~ Tokens service instance pointer, issuing & rotating the tokens
+//
+// - svcA [*auth.SvcAuthentication] ~ Authentication service instance, holding the providers
+func NewAuthHandler (app *iris.Application, MdwAuthChecker *context.Handler, svcR *utils.SvcResponse, svcC *utils.SvcConfig, pUsers *service.SvcUser, pTokens *auth.SvcToken, svcA *auth.SvcAuthentication) HAuth {
 
 	// --- VARS SETUP ---
-	h := HAuth{svcR, svcC, make(map[string]bool)}
-	// filling providers
-	h.providers["sisec"] = true
-	// h.providers["another_provider"] = true
-	// h.providers["another_provider"] = true
-
-	svcA := auth.NewSvcAuthentication(h.providers, svcC) 					// creating authentication Service
+	h := HAuth{svcR, svcC, make(map[string]bool), pUsers, pTokens}
+	// filling providers from the authentication service ones
+	for provider := range svcA.AuthProviders { h.providers[provider] = true }
 
 	// registering unprotected router
 	authRouter := app.Party("/auth")								// authorize
@@ -51,8 +57,19 @@ func NewAuthHandler (app *iris.Application, MdwAuthChecker *context.Handler, svc
 		hero.Register(svcA)
 
 		// --- REGISTERING ENDPOINTS ---
+		authRouter.Get("/providers", hero.Handler(h.listProviders))		// enabled providers, for the login UIs
+
 		// authRouter.Post("/<provider>")										// provider is the auth provider to be used.
 		authRouter.Post("/{provider}", hero.Handler(h.authIntent)) 		// using a provider named 'sisec'.
+		authRouter.Get("/{provider}/login", hero.Handler(h.login))		// redirect providers, e.g. OpenID Connect
+		authRouter.Get("/{provider}/callback", hero.Handler(h.callback))
+
+		authRouter.Post("/refresh", h.refresh)
+
+		// default (database) provider users management
+		authRouter.Post("/register", h.register)
+		authRouter.Post("/password/forgot", h.forgotPassword)
+		authRouter.Post("/password/reset", h.resetPassword)
 	}
 
 	// registering protected router
@@ -66,8 +83,13 @@ func NewAuthHandler (app *iris.Application, MdwAuthChecker *context.Handler, svc
 		// --- REGISTERING ENDPOINTS ---
 		guardAuthRouter.Get("/protected", h.protectedSample)
 		guardAuthRouter.Get("/logout", h.logout)
+		guardAuthRouter.Put("/password", h.changePassword)
+		guardAuthRouter.Put("/users/{username}/scopes", middlewares.NewRoleGuardMiddleware(svcR, schema.RolAdmin), h.setUserScopes)
 	}
 
+	// access tokens verification keys, for the downstream services
+	app.Get("/.well-known/jwks.json", h.jwks)
+
 	return h
 }
 
@@ -91,12 +113,12 @@ func (h HAuth) protectedSample(ctx iris.Context) {
 	// @in header
 	// @name Authorization
 
-	claims := jwt.Get(ctx).(*dto.AccessTokenData)
+	claims := middlewares.Claims(ctx)
 	(*h.response).ResOKWithData(claims, &ctx)
 }
 
-// logout this endpoint invalidated a previously granted access token
-// @Description This endpoint invalidated a previously granted access token
+// logout this endpoint invalidated a previously granted access token, and the user's refresh tokens
+// @Description This endpoint invalidated a previously granted access token, and revokes all the user's refresh tokens (every session must login again)
 // @Security ApiKeyAuth
 // @Param Authorization header string true "Insert access token" default(Bearer <Add access token here>)
 // @Tags Auth
@@ -106,7 +128,11 @@ func (h HAuth) protectedSample(ctx iris.Context) {
 // @Failure 500 {object} dto.ApiError "err.generic
 // @Router /auth/logout [get]
 func (h HAuth) logout(ctx iris.Context) {
+	sub := middlewares.Claims(ctx).Claims.Sub
+
 	err := ctx.Logout()
+	if err == nil { err = (*h.tokens).Revoke(sub) }
+
 	if err != nil {
 		(*h.response).ResErr(iris.StatusInternalServerError, schema.ErrGeneric, err.Error(), &ctx)
 	} else {
@@ -114,17 +140,42 @@ func (h HAuth) logout(ctx iris.Context) {
 	}
 }
 
+// listProviders list the enabled auth providers, with their login flow
+// @Summary List the auth providers
+// @Description List the enabled auth providers, in the configured order, for the login UIs. The "password" flow providers take the user credentials POSTed to their LoginUrl, the "redirect" flow ones need the browser sent to their LoginUrl
+// @Tags Auth
+// @Produce json
+// @Success 200 {array} dto.AuthProviderOut "OK"
+// @Router /auth/providers [get]
+func (h HAuth) listProviders(ctx iris.Context, authService *auth.SvcAuthentication) {
+	(*h.response).ResOKWithData(authService.Enabled(), &ctx)
+}
+
+// jwks publish the access tokens verification keys
+// @Summary Access tokens verification keys
+// @Description JSON Web Key Set (RFC 7517) of the keys verifying the access tokens, by key id (kid header of the tokens). The signing key goes first, the rest are the previous keys still verifying the tokens issued before a rotation. Empty while the tokens are signed with the HS256 shared key, which is secret
+// @Tags Auth
+// @Produce json
+// @Success 200 {object} dto.JWKSOut "OK"
+// @Router /.well-known/jwks.json [get]
+func (h HAuth) jwks(ctx iris.Context) {
+	ctx.Header("Cache-Control", "public, max-age=300")				// the downstream services refetch it on an unknown kid
+	(*h.response).ResOKWithData((*h.tokens).JWKS(), &ctx)
+}
+
 // authIntent Intent to grant authentication using the provider user's credentials and the specified  auth provider
 // @Summary Auth the user credential through a provider
 // @Description Intent to grant authentication using the provider user's credentials and the specified  auth provider
 // @Tags Auth
 // @Accept multipart/form-data
 // @Produce json
-// @Param	provider	path	string			true	"Requested Book Id"
+// @Param	provider	path	string			true	"Auth provider name"
 // @Param 	credential 	body 	dto.UserCredIn 	true	"User Login Credential"
-// @Success 202 "Accepted"
+// @Success 202 {object} dto.TokenOut "Accepted"
 // @Failure 401 {object} dto.ApiError "err.unauthorized"
 // @Failure 400 {object} dto.ApiError "err.wrong_auth_provider"
+// @Failure 502 {object} dto.ApiError "err.http_res_err | err.upstream_config"
+// @Failure 503 {object} dto.ApiError "err.upstream_unavailable"
 // @Failure 504 {object} dto.ApiError "err.network"
 // @Failure 500 {object} dto.ApiError "err.json_parse | err.wrong_type_assertion"
 // @Router /auth/{provider} [post]
@@ -137,26 +188,337 @@ func (h HAuth) authIntent(ctx iris.Context, uCred *dto.UserCredIn, authService *
 		return
 	}
 
-	// requesting authorization to SISEC with user credentials
-	authGrantedData, e, eCode := authService.AuthProviders[provider].GrantIntent(uCred, h.appConf)
-	if eCode == schema.ErrInvalidType {
-		(*h.response).ResErr(iris.StatusInternalServerError, eCode, schema.ErrDetInvalidType, &ctx)
-	} else if e != nil && eCode == schema.ErrNetwork {
-		(*h.response).ResErr(iris.StatusGatewayTimeout, eCode, e.Error(), &ctx)
-	} else if e != nil && eCode == schema.ErrUnauthorized {
-		(*h.response).ResErr(iris.StatusUnauthorized, eCode, e.Error(), &ctx)
-	} else if e != nil {
-		(*h.response).ResErr(iris.StatusInternalServerError, eCode, e.Error(), &ctx)
+	// requesting authorization to the provider with user credentials
+	tokenData, e, eCode := authService.AuthProviders[provider].GrantIntent(ctx.Request().Context(), uCred, h.appConf)
+	if e != nil || eCode != "" {
+		h.resProviderErr(e, eCode, &ctx)
+		return
+	}
+
+	h.resTokens(provider, tokenData, &ctx)
+}
+
+// login redirect the user to the provider login page, starting an authorization code flow (e.g. OpenID Connect)
+// @Summary Login through the provider page
+// @Description Redirect the user to the provider login page (OAuth2 authorization code flow with PKCE, e.g. OpenID Connect). Once logged in, the provider redirects the user back to /auth/{provider}/callback. The login must be completed within 10 minutes
+// @Tags Auth
+// @Produce json
+// @Param	provider	path	string	true	"Auth provider name"
+// @Success 302 "Found"
+// @Header 302 {string} Location "Provider login page url"
+// @Failure 400 {object} dto.ApiError "err.wrong_auth_provider"
+// @Failure 502 {object} dto.ApiError "err.http_res_err | err.upstream_config"
+// @Failure 503 {object} dto.ApiError "err.upstream_unavailable"
+// @Failure 504 {object} dto.ApiError "err.network"
+// @Failure 500 {object} dto.ApiError "err.json_parse | err.generic"
+// @Router /auth/{provider}/login [get]
+func (h HAuth) login(ctx iris.Context, authService *auth.SvcAuthentication) {
+	p, detail := h.redirectProvider(ctx.Params().Get("provider"), authService)
+	if p == nil {
+		(*h.response).ResErr(iris.StatusBadRequest, schema.ErrWrongAuthProvider, detail, &ctx)
+		return
+	}
+
+	authURL, e, eCode := p.AuthCodeURL(ctx.Request().Context())
+	if e != nil {
+		h.resProviderErr(e, eCode, &ctx)
+		return
+	}
+
+	ctx.Redirect(authURL, iris.StatusFound)
+}
+
+// callback complete a login started at /auth/{provider}/login, the provider redirects the user here
+// @Summary Login callback of the provider page
+// @Description Complete a login started at /auth/{provider}/login, exchanging the authorization code for the user identity (a verified OpenID Connect ID token). The provider redirects the user here
+// @Tags Auth
+// @Produce json
+// @Param	provider			path	string	true	"Auth provider name"
+// @Param	code				query	string	false	"Authorization code"
+// @Param	state				query	string	true	"Login state"
+// @Param	error				query	string	false	"Login error, e.g. access_denied"
+// @Param	error_description	query	string	false	"Login error description"
+// @Success 202 {object} dto.TokenOut "Accepted"
+// @Failure 400 {object} dto.ApiError "err.wrong_auth_provider"
+// @Failure 401 {object} dto.ApiError "err.unauthorized"
+// @Failure 502 {object} dto.ApiError "err.http_res_err | err.upstream_config"
+// @Failure 503 {object} dto.ApiError "err.upstream_unavailable"
+// @Failure 504 {object} dto.ApiError "err.network"
+// @Failure 500 {object} dto.ApiError "err.json_parse | err.jwt_generation"
+// @Router /auth/{provider}/callback [get]
+func (h HAuth) callback(ctx iris.Context, authService *auth.SvcAuthentication) {
+	p, detail := h.redirectProvider(ctx.Params().Get("provider"), authService)
+	if p == nil {
+		(*h.response).ResErr(iris.StatusBadRequest, schema.ErrWrongAuthProvider, detail, &ctx)
+		return
+	}
+
+	// the user denied the login, or the provider failed (RFC 6749 section 4.1.2.1)
+	if e := ctx.URLParam("error"); e != "" {
+		detail := "the login was denied by the provider - " + e
+		if d := ctx.URLParam("error_description"); d != "" { detail += ": " + d }
+		(*h.response).ResErr(iris.StatusUnauthorized, schema.ErrUnauthorized, detail, &ctx)
+		return
+	}
+
+	tokenData, e, eCode := p.Exchange(ctx.Request().Context(), ctx.URLParam("code"), ctx.URLParam("state"))
+	if e != nil || eCode != "" {
+		h.resProviderErr(e, eCode, &ctx)
+		return
+	}
+
+	h.resTokens(ctx.Params().Get("provider"), tokenData, &ctx)
+}
+
+// refresh rotate a refresh token, granting a new access & refresh tokens pair
+// @Summary Refresh the access token
+// @Description Rotate a refresh token, granting a new access & refresh tokens pair with the user's current roles & scopes (default provider users). The refresh tokens are single use, reusing one revokes all the tokens descending from the same login, and they all expire RefreshTkMaxAge hours after the login
+// @Tags Auth
+// @Accept multipart/form-data
+// @Produce json
+// @Param	refresh_token	formData	string	true	"Refresh token"
+// @Success 202 {object} dto.TokenOut "Accepted"
+// @Failure 401 {object} dto.ApiError "err.unauthorized"
+// @Failure 500 {object} dto.ApiError "err.jwt_generation"
+// @Router /auth/refresh [post]
+func (h HAuth) refresh(ctx iris.Context) {
+	refreshToken := ctx.PostValue("refresh_token")
+	if refreshToken == "" {
+		(*h.response).ResErr(iris.StatusUnauthorized, schema.ErrUnauthorized, schema.ErrDetInvalidRefreshTk, &ctx)
+		return
+	}
+
+	tokens, err := (*h.tokens).Refresh(refreshToken)
+
+	if err != nil && errs.KindOf(err) == errs.Internal {
+		(*h.response).ResErr(iris.StatusInternalServerError, schema.ErrJwtGen, err.Error(), &ctx)
+	} else if err != nil {																// 401 invalid refresh token
+		(*h.response).ResFromErr(err, &ctx)
+	} else {
+		(*h.response).ResWithDataStatus(iris.StatusAccepted, tokens, &ctx)
+	}
+}
+
+// register create a new user for the default (database) auth provider
+// @Summary Register a new user
+// @Description Register a new user for the default (database) auth provider. The user can login through /auth/default
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param	user	body	dto.UserRegisterIn	true	"User Data"
+// @Success 201 {object} models.User "OK"
+// @Failure 422 {object} dto.ApiError "err.duplicate_key || err.invalid_data"
+// @Failure 500 {object} dto.ApiError "err.repo_ops"
+// @Router /auth/register [post]
+func (h HAuth) register(ctx iris.Context) {
+	var uDto dto.UserRegisterIn
+
+	if e := ctx.ReadJSON(&uDto); e != nil {
+		(*h.response).ResErr(iris.StatusUnprocessableEntity, schema.ErrVal, e.Error(), &ctx) // 422 ReadJSON do the validation here
+		return
+	}
+
+	user := models.User{Username: uDto.Username}
+	err := (*h.users).Register(&user, uDto.Password)
+
+	if err != nil {
+		(*h.response).ResFromErr(err, &ctx) // 422 Unprocessable 'cause duplicate username, or 500
+	} else {
+		(*h.response).ResWithDataStatus(iris.StatusCreated, user, &ctx)
+	}
+}
+
+// changePassword change the password of the logged user
+// @Summary Change the password
+// @Description Change the password of the logged user of the default (database) auth provider. All the user's refresh tokens are revoked
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Insert access token" default(Bearer <Add access token here>)
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param	passwords	body	dto.PasswordChangeIn	true	"Old and new passwords"
+// @Success 204 "OK"
+// @Failure 401 {object} dto.ApiError "err.unauthorized"
+// @Failure 404 {object} dto.ApiError "err.not_found"
+// @Failure 422 {object} dto.ApiError "err.invalid_data"
+// @Failure 500 {object} dto.ApiError "err.repo_ops"
+// @Router /auth/password [put]
+func (h HAuth) changePassword(ctx iris.Context) {
+	var pDto dto.PasswordChangeIn
+
+	if e := ctx.ReadJSON(&pDto); e != nil {
+		(*h.response).ResErr(iris.StatusUnprocessableEntity, schema.ErrVal, e.Error(), &ctx)
+		return
+	}
+
+	claims := middlewares.Claims(ctx)
+	err := (*h.users).ChangePassword(claims.Claims.Sub, pDto.OldPassword, pDto.NewPassword)
+
+	if err != nil {
+		(*h.response).ResFromErr(err, &ctx) // 401 wrong old password, 404 not a default provider user, or 500
+	} else {
+		(*h.response).ResOK(&ctx)
+	}
+}
+
+// setUserScopes grant scopes to an user
+// @Summary Set the scopes of an user
+// @Description Replace the scopes of an user of the default (database) auth provider, e.g. granting books:write (the self-registered users have none). The user gets them on its next login. It requires the admin role
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Insert access token" default(Bearer <Add access token here>)
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param	username	path	string	true	"Username"
+// @Param	scopes	body	dto.UserScopesIn	true	"Granted scopes, empty for revoking them all"
+// @Success 204 "OK"
+// @Failure 401 {object} dto.ApiError "err.unauthorized"
+// @Failure 403 {object} dto.ApiError "err.forbidden"
+// @Failure 404 {object} dto.ApiError "err.not_found"
+// @Failure 422 {object} dto.ApiError "err.invalid_data"
+// @Failure 500 {object} dto.ApiError "err.repo_ops"
+// @Router /auth/users/{username}/scopes [put]
+func (h HAuth) setUserScopes(ctx iris.Context) {
+	var sDto dto.UserScopesIn
+
+	if e := ctx.ReadJSON(&sDto); e != nil {
+		(*h.response).ResErr(iris.StatusUnprocessableEntity, schema.ErrVal, e.Error(), &ctx)
+		return
+	}
+
+	if err := (*h.users).SetScopes(ctx.Params().Get("username"), sDto.Scopes); err != nil {
+		(*h.response).ResFromErr(err, &ctx) // 404 not a default provider user, or 500
+	} else {
+		(*h.response).ResOK(&ctx)
+	}
+}
+
+// forgotPassword request a password reset token
+// @Summary Request a password reset
+// @Description Request a password reset token for an user of the default (database) auth provider. The response is the same whether the user exist or not. Only in debug mode the token is retrieved in the response
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param	user	body	dto.PasswordForgotIn	true	"Username"
+// @Success 202 "Accepted"
+// @Failure 422 {object} dto.ApiError "err.invalid_data"
+// @Failure 500 {object} dto.ApiError "err.repo_ops"
+// @Router /auth/password/forgot [post]
+func (h HAuth) forgotPassword(ctx iris.Context) {
+	var fDto dto.PasswordForgotIn
+
+	if e := ctx.ReadJSON(&fDto); e != nil {
+		(*h.response).ResErr(iris.StatusUnprocessableEntity, schema.ErrVal, e.Error(), &ctx)
+		return
+	}
+
+	token, err := (*h.users).ForgotPassword(fDto.Username)
+	if err != nil {
+		(*h.response).ResErr(iris.StatusInternalServerError, schema.ErrRepositoryOps, err.Error(), &ctx)
+		return
+	}
+
+	// TODO deliver the token through an out of band channel (e.g. mail). Meanwhile, it's only retrieved in debug mode
+	if h.appConf.Debug && token != "" {
+		(*h.response).ResWithDataStatus(iris.StatusAccepted, token, &ctx)
+	} else {
+		ctx.StatusCode(iris.StatusAccepted)
+	}
+}
+
+// resetPassword set a new password using a password reset token
+// @Summary Reset the password
+// @Description Set a new password for an user of the default (database) auth provider, using a previously requested password reset token. All the user's refresh tokens are revoked
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param	reset	body	dto.PasswordResetIn	true	"Reset data"
+// @Success 204 "OK"
+// @Failure 401 {object} dto.ApiError "err.unauthorized"
+// @Failure 422 {object} dto.ApiError "err.invalid_data"
+// @Failure 500 {object} dto.ApiError "err.repo_ops"
+// @Router /auth/password/reset [post]
+func (h HAuth) resetPassword(ctx iris.Context) {
+	var rDto dto.PasswordResetIn
+
+	if e := ctx.ReadJSON(&rDto); e != nil {
+		(*h.response).ResErr(iris.StatusUnprocessableEntity, schema.ErrVal, e.Error(), &ctx)
+		return
+	}
+
+	err := (*h.users).ResetPassword(rDto.Username, rDto.Token, rDto.NewPassword)
+
+	if err != nil {
+		(*h.response).ResFromErr(err, &ctx) // 401 invalid reset token, or 500
+	} else {
+		(*h.response).ResOK(&ctx)
 	}
+}
+// endregion =============================================================================
 
-	// if so far so good, we are going to create the auth token
-	tokenData := mapper.ToAccessTokenDataV(&authGrantedData.Access_Token)
-	accessToken, er := lib.MkAccessToken(tokenData, []byte(h.appConf.JWTSignKey), h.appConf.TkMaxAge)
+
+// region ======== PROVIDER HELPERS ======================================================
+
+// redirectProvider get an enabled provider supporting the login redirect (authorization code flow), or the error
+// detail if there isn't
+//
+// - provider [string] ~ Provider name
+//
+// - authService [*auth.SvcAuthentication] ~ Authentication service instance, holding the providers
+func (h HAuth) redirectProvider(provider string, authService *auth.SvcAuthentication) (auth.RedirectProvider, string) {
+	if !h.providers[provider] { return nil, schema.ErrDetInvalidProvider }
+
+	p, ok := authService.AuthProviders[provider].(auth.RedirectProvider)
+	if !ok { return nil, schema.ErrDetNoRedirectLogin }
+
+	return p, ""
+}
+
+// resProviderErr respond a provider error, according to its code: the rejected credentials are a 401, the upstream
+// failures (and the rejected app credentials) a 502 / 503 / 504, and the unsupported grants a 400
+//
+// - e [error] ~ Provider error
+//
+// - eCode [string] ~ Provider error code
+//
+// - ctx [*iris.Context] ~ Iris request context
+func (h HAuth) resProviderErr(e error, eCode string, ctx *iris.Context) {
+	detail := schema.ErrDetInvalidType
+	if e != nil { detail = e.Error() }
+
+	switch eCode {
+	case schema.ErrInvalidType:
+		(*h.response).ResErr(iris.StatusInternalServerError, eCode, schema.ErrDetInvalidType, ctx)
+	case schema.ErrNetwork:
+		(*h.response).ResErr(iris.StatusGatewayTimeout, eCode, detail, ctx)
+	case schema.ErrUnauthorized:
+		(*h.response).ResErr(iris.StatusUnauthorized, eCode, detail, ctx)
+	case schema.ErrWrongAuthProvider:
+		(*h.response).ResErr(iris.StatusBadRequest, eCode, detail, ctx)
+	case schema.ErrUpstreamUnavailable:
+		(*h.response).ResErr(iris.StatusServiceUnavailable, eCode, detail, ctx)
+	case schema.ErrHttpResError, schema.ErrUpstreamConfig:
+		(*h.response).ResErr(iris.StatusBadGateway, eCode, detail, ctx)
+	default:
+		(*h.response).ResErr(iris.StatusInternalServerError, eCode, detail, ctx)
+	}
+}
+
+// resTokens create the access & refresh tokens of a granted authentication, and respond them
+//
+// - provider [string] ~ Name of the auth provider that granted the authentication
+//
+// - tokenData [*dto.AccessTokenData] ~ Data to be tokenized, from the provider
+//
+// - ctx [*iris.Context] ~ Iris request context
+func (h HAuth) resTokens(provider string, tokenData *dto.AccessTokenData, ctx *iris.Context) {
+	tokens, er := (*h.tokens).Issue(provider, tokenData)
 	if er != nil {
-		(*h.response).ResErr(iris.StatusInternalServerError, schema.ErrJwtGen, er.Error(), &ctx)
+		(*h.response).ResErr(iris.StatusInternalServerError, schema.ErrJwtGen, er.Error(), ctx)
+		return
 	}
 
-	(*h.response).ResWithDataStatus(iris.StatusAccepted, string(accessToken), &ctx)
+	(*h.response).ResWithDataStatus(iris.StatusAccepted, tokens, ctx)
 }
 // endregion =============================================================================
 
@@ -0,0 +1,355 @@
+package endpoints
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/iris-contrib/httpexpect/v2"
+	"github.com/kataras/iris/v12"
+	"github.com/kataras/iris/v12/httptest"
+	kjwt "github.com/kataras/jwt"
+
+	"go.api.backend/api/middlewares"
+	"go.api.backend/lib"
+	"go.api.backend/repo/mem"
+	"go.api.backend/schema"
+	"go.api.backend/schema/dto"
+	"go.api.backend/service"
+	"go.api.backend/service/auth"
+	"go.api.backend/service/auth/authtest"
+	"go.api.backend/service/utils"
+)
+
+// newTestAuthApp create an iris app with the auth endpoints, the sisec provider pointing to a fake SISEC (knowing
+// the user "alice"), the "acme" OpenID Connect provider pointing to a mock issuer (logging in "bob"), and the default
+// provider users and the refresh tokens kept in memory
+func newTestAuthApp(t *testing.T) (*httptest.Expect, *authtest.FakeSisec, *authtest.FakeOIDC) {
+	t.Helper()
+
+	app, fake, issuer := newTestAuthIris(t, testKeys)
+	return httptest.New(t, app), fake, issuer
+}
+
+// newTestAuthIris create the iris app of newTestAuthApp with the given access tokens keyring, without the test client
+func newTestAuthIris(t *testing.T, keys *lib.Keyring) (*iris.Application, *authtest.FakeSisec, *authtest.FakeOIDC) {
+	t.Helper()
+
+	fake := authtest.NewFakeSisec("app", "app-pass", map[string]authtest.SisecUser{
+		"alice": {Password: "secret", Domain: "web", Rol: schema.RolUser, Scope: schema.ScopeBooksWrite},
+	})
+	t.Cleanup(fake.Close)
+
+	svcC := &utils.SvcConfig{}
+	svcC.JWTSignKey, svcC.TkMaxAge, svcC.RefreshTkMaxAge = string(testSigKey), 5, 1
+	svcC.Debug = true // the password reset tokens are retrieved in the responses
+
+	issuer := authtest.NewFakeOIDC("app", "", authtest.OIDCUser{Sub: "bob", Claims: map[string]interface{}{"roles": []string{schema.RolAdmin}}})
+	t.Cleanup(issuer.Close)
+
+	svcC.AuthProviders = []utils.AuthProviderConf{
+		{
+			Type: "oidc", Name: "acme", Enabled: true, Url: issuer.URL, ClientId: "app", RedirectUrl: "http://app.test/auth/acme/callback",
+			RolClaim: "roles", RoleMap: map[string]string{schema.RolAdmin: schema.RolAdmin},
+		},
+		{Type: "default", Name: "default", Enabled: true},
+		{Type: "sisec", Name: "sisec", Enabled: true, Url: fake.URL, ClientId: "app", ClientSecret: "app-pass"},
+	}
+
+	userRepo := mem.NewRepoMemUser()
+	tokenRepo := mem.NewRepoMemRefreshToken()
+	svcUser := service.NewSvcUsers(&userRepo, &tokenRepo)
+	svcA, err := auth.NewSvcAuthentication(svcC, &userRepo)
+	if err != nil { t.Fatal(err) }
+
+	app := iris.New()
+	app.Validator = validator.New()
+
+	svcToken := auth.NewSvcToken(&tokenRepo, svcC, keys, svcA)
+	bgCtx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	mdwAuthChecker := middlewares.NewAuthCheckerMiddleware(keys, auth.NewBlocklist(bgCtx, svcC, nil))
+	NewAuthHandler(app, &mdwAuthChecker, utils.NewSvcResponse(svcC), svcC, &svcUser, &svcToken, svcA)
+
+	return app, fake, issuer
+}
+
+func TestHAuth_SisecIntent(t *testing.T) {
+	tests := []struct {
+		name       string
+		provider   string
+		username   string
+		password   string
+		failWith   int
+		closed     bool
+		clientPass string // App credential expected by SISEC, if it was changed there
+		wantStatus int
+		wantTitle  string
+	}{
+		{name: "granted", provider: "sisec", username: "alice", password: "secret", wantStatus: iris.StatusAccepted},
+		{name: "wrong password", provider: "sisec", username: "alice", password: "nope", wantStatus: iris.StatusUnauthorized, wantTitle: schema.ErrUnauthorized},
+		{
+			name: "forbidden", provider: "sisec", username: "alice", password: "secret", failWith: iris.StatusForbidden,
+			wantStatus: iris.StatusUnauthorized, wantTitle: schema.ErrUnauthorized,
+		},
+		{
+			name: "unauthorized", provider: "sisec", username: "alice", password: "secret", failWith: iris.StatusUnauthorized,
+			wantStatus: iris.StatusUnauthorized, wantTitle: schema.ErrUnauthorized,
+		},
+		{
+			name: "wrong app credential", provider: "sisec", username: "alice", password: "secret", clientPass: "rotated",
+			wantStatus: iris.StatusBadGateway, wantTitle: schema.ErrUpstreamConfig,
+		},
+		{name: "unknown provider", provider: "acme", username: "alice", password: "secret", wantStatus: iris.StatusBadRequest, wantTitle: schema.ErrWrongAuthProvider},
+		{
+			name: "SISEC unavailable", provider: "sisec", username: "alice", password: "secret", failWith: iris.StatusServiceUnavailable,
+			wantStatus: iris.StatusServiceUnavailable, wantTitle: schema.ErrUpstreamUnavailable,
+		},
+		{
+			name: "unexpected SISEC response", provider: "sisec", username: "alice", password: "secret", failWith: iris.StatusNotFound,
+			wantStatus: iris.StatusBadGateway, wantTitle: schema.ErrHttpResError,
+		},
+		{
+			name: "SISEC down", provider: "sisec", username: "alice", password: "secret", closed: true,
+			wantStatus: iris.StatusGatewayTimeout, wantTitle: schema.ErrNetwork,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, fake, _ := newTestAuthApp(t)
+			if tt.failWith != 0 { fake.FailWith(tt.failWith) }
+			if tt.closed { fake.Close() }
+			if tt.clientPass != "" { fake.ClientPass = tt.clientPass }
+
+			res := e.POST("/auth/{provider}", tt.provider).
+				WithFormField("username", tt.username).WithFormField("password", tt.password).WithFormField("domain", "web").
+				Expect().Status(tt.wantStatus)
+
+			if tt.wantTitle != "" {
+				res.JSON(problemJSON).Object().ValueEqual("title", tt.wantTitle)
+				return
+			}
+
+			// The granted access token opens the protected endpoints, and the refresh token rotates
+			tokens := res.JSON().Object()
+			e.GET("/auth/protected").WithHeader("Authorization", "Bearer " + tokens.Value("AccessToken").String().Raw()).
+				Expect().Status(iris.StatusOK)
+			e.POST("/auth/refresh").WithFormField("refresh_token", tokens.Value("RefreshToken").String().Raw()).
+				Expect().Status(iris.StatusAccepted).JSON().Object().Value("AccessToken").String().NotEmpty()
+		})
+	}
+}
+
+func TestHAuth_OIDCLogin(t *testing.T) {
+	app, _, issuer := newTestAuthIris(t, testKeys)
+	e := httptest.New(t, app)
+
+	// the login redirects to the issuer, which redirects back to the callback with the code & state
+	noRedirects := &http.Client{Transport: httpexpect.NewBinder(app), CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	authURL := e.GET("/auth/{provider}/login", "acme").WithClient(noRedirects).
+		Expect().Status(iris.StatusFound).Header("Location").Raw()
+	cb, err := issuer.Authorize(authURL)
+	if err != nil { t.Fatal(err) }
+
+	tokens := e.GET("/auth/{provider}/callback", "acme").WithQuery("code", cb.Query().Get("code")).WithQuery("state", cb.Query().Get("state")).
+		Expect().Status(iris.StatusAccepted).JSON().Object()
+
+	claims := e.GET("/auth/protected").WithHeader("Authorization", "Bearer " + tokens.Value("AccessToken").String().Raw()).
+		Expect().Status(iris.StatusOK).JSON().Object().Value("Claims").Object()
+	claims.ValueEqual("Sub", "acme:bob").ValueEqual("Rol", schema.RolAdmin)
+
+	// the login state is single use
+	e.GET("/auth/{provider}/callback", "acme").WithQuery("code", cb.Query().Get("code")).WithQuery("state", cb.Query().Get("state")).
+		Expect().Status(iris.StatusUnauthorized).JSON(problemJSON).Object().ValueEqual("title", schema.ErrUnauthorized)
+}
+
+func TestHAuth_OIDCLogin_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		provider   string
+		query      map[string]string
+		wantStatus int
+		wantTitle  string
+	}{
+		{name: "unknown provider", path: "login", provider: "other", wantStatus: iris.StatusBadRequest, wantTitle: schema.ErrWrongAuthProvider},
+		{name: "password provider", path: "login", provider: "sisec", wantStatus: iris.StatusBadRequest, wantTitle: schema.ErrWrongAuthProvider},
+		{
+			name: "login denied", path: "callback", provider: "acme", query: map[string]string{"error": "access_denied", "state": "x"},
+			wantStatus: iris.StatusUnauthorized, wantTitle: schema.ErrUnauthorized,
+		},
+		{
+			name: "forged state", path: "callback", provider: "acme", query: map[string]string{"code": "x", "state": "x"},
+			wantStatus: iris.StatusUnauthorized, wantTitle: schema.ErrUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, _, _ := newTestAuthApp(t)
+
+			req := e.GET("/auth/{provider}/" + tt.path, tt.provider)
+			for k, v := range tt.query { req = req.WithQuery(k, v) }
+
+			req.Expect().Status(tt.wantStatus).JSON(problemJSON).Object().ValueEqual("title", tt.wantTitle)
+		})
+	}
+
+	// the password grant isn't supported by the OpenID Connect provider
+	e, _, _ := newTestAuthApp(t)
+	e.POST("/auth/{provider}", "acme").WithFormField("username", "bob").WithFormField("password", "secret").WithFormField("domain", "web").
+		Expect().Status(iris.StatusBadRequest).JSON(problemJSON).Object().ValueEqual("title", schema.ErrWrongAuthProvider)
+}
+
+// The providers set on the tests have no declaration, so they're listed by name, without type nor label
+func TestHAuth_Providers(t *testing.T) {
+	e, _, _ := newTestAuthApp(t)
+
+	list := e.GET("/auth/providers").Expect().Status(iris.StatusOK).JSON().Array()
+	list.Length().Equal(3)
+	list.Element(0).Object().ValueEqual("Name", "acme").ValueEqual("Flow", schema.AuthFlowRedirect).ValueEqual("LoginUrl", "/auth/acme/login")
+	list.Element(2).Object().ValueEqual("Name", "sisec").ValueEqual("Flow", schema.AuthFlowPassword).ValueEqual("LoginUrl", "/auth/sisec")
+}
+
+
+// A downstream service verifies the access tokens with the published keys only
+func TestHAuth_JWKS(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	der, _ := x509.MarshalPKCS8PrivateKey(edKey)
+	file := filepath.Join(t.TempDir(), "ed.pem")
+	if err := ioutil.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil { t.Fatal(err) }
+
+	keys, err := lib.LoadKeyring([]string{file}, nil, time.Time{})
+	if err != nil { t.Fatal(err) }
+	app, _, _ := newTestAuthIris(t, keys)
+	e := httptest.New(t, app)
+
+	tk := e.POST("/auth/{provider}", "sisec").
+		WithFormField("username", "alice").WithFormField("password", "secret").WithFormField("domain", "web").
+		Expect().Status(iris.StatusAccepted).JSON().Object().Value("AccessToken").String().Raw()
+	e.GET("/auth/protected").WithHeader("Authorization", "Bearer " + tk).Expect().Status(iris.StatusOK)
+
+	set := dto.JWKSIn{}
+	res := e.GET("/.well-known/jwks.json").Expect().Status(iris.StatusOK)
+	res.JSON().Object().Value("keys").Array().Element(0).Object().ValueEqual("kty", "OKP").ValueEqual("alg", "EdDSA").NotContainsKey("d")
+	if err := json.Unmarshal([]byte(res.Body().Raw()), &set); err != nil { t.Fatal(err) }
+
+	published, err := lib.KeysFromJWKS(&set)
+	if err != nil { t.Fatal(err) }
+	if _, err := kjwt.VerifyWithHeaderValidator(nil, nil, []byte(tk), published.ValidateHeader); err != nil { t.Fatalf("verifying with the published keys = %v", err) }
+
+	// the HS256 key is secret, nothing is published
+	e, _, _ = newTestAuthApp(t)
+	e.GET("/.well-known/jwks.json").Expect().Status(iris.StatusOK).JSON().Object().Value("keys").Array().Empty()
+}
+
+// The self-registered users can't write until an admin grants them the write scope
+func TestHAuth_UserScopes(t *testing.T) {
+	e, _, _ := newTestAuthApp(t)
+
+	e.POST("/auth/register").WithJSON(map[string]string{"Username": "carol", "Password": "my.secret.pass"}).
+		Expect().Status(iris.StatusCreated).JSON().Object().Value("Scopes").Null()
+	tokens := testLogin(e, "carol", "my.secret.pass")
+	testClaims(e, tokens).Value("Scope").Null()
+
+	grant := map[string][]string{"Scopes": {schema.ScopeBooksWrite}}
+	tests := []struct {
+		name       string
+		username   string
+		auth       string
+		body       interface{}
+		wantStatus int
+	}{
+		{name: "not an admin", username: "carol", auth: testToken(t, schema.RolUser, schema.ScopeBooksWrite), body: grant, wantStatus: iris.StatusForbidden},
+		{name: "anonymous", username: "carol", body: grant, wantStatus: iris.StatusUnauthorized},
+		{name: "unknown scope", username: "carol", auth: testToken(t, schema.RolAdmin), body: map[string][]string{"Scopes": {"books:all"}}, wantStatus: iris.StatusUnprocessableEntity},
+		{name: "unknown user", username: "dave", auth: testToken(t, schema.RolAdmin), body: grant, wantStatus: iris.StatusNotFound},
+		{name: "granted", username: "carol", auth: testToken(t, schema.RolAdmin), body: grant, wantStatus: iris.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := e.PUT("/auth/users/{username}/scopes", tt.username).WithJSON(tt.body)
+			if tt.auth != "" { req = req.WithHeader("Authorization", tt.auth) }
+			req.Expect().Status(tt.wantStatus)
+		})
+	}
+
+	// the new logins get the scope, and the previous ones on their refresh
+	testClaims(e, testLogin(e, "carol", "my.secret.pass")).Value("Scope").Array().Equal([]string{schema.ScopeBooksWrite})
+	refreshed := e.POST("/auth/refresh").WithFormField("refresh_token", tokens.Value("RefreshToken").String().Raw()).
+		Expect().Status(iris.StatusAccepted).JSON().Object()
+	testClaims(e, refreshed).Value("Scope").Array().Equal([]string{schema.ScopeBooksWrite})
+}
+
+// Logging out or changing the password revokes the refresh tokens of every user's session
+func TestHAuth_RefreshRevocation(t *testing.T) {
+	tests := []struct {
+		name   string
+		revoke func(e *httpexpect.Expect, tokens *httpexpect.Object)
+	}{
+		{
+			name: "logout",
+			revoke: func(e *httpexpect.Expect, tokens *httpexpect.Object) {
+				e.GET("/auth/logout").WithHeader("Authorization", "Bearer " + tokens.Value("AccessToken").String().Raw()).
+					Expect().Status(iris.StatusNoContent)
+			},
+		},
+		{
+			name: "password change",
+			revoke: func(e *httpexpect.Expect, tokens *httpexpect.Object) {
+				e.PUT("/auth/password").WithHeader("Authorization", "Bearer " + tokens.Value("AccessToken").String().Raw()).
+					WithJSON(map[string]string{"OldPassword": "my.secret.pass", "NewPassword": "my.new.secret.pass"}).
+					Expect().Status(iris.StatusNoContent)
+			},
+		},
+		{
+			name: "password reset",
+			revoke: func(e *httpexpect.Expect, _ *httpexpect.Object) {
+				token := e.POST("/auth/password/forgot").WithJSON(map[string]string{"Username": "carol"}).
+					Expect().Status(iris.StatusAccepted).JSON().String().Raw()
+				e.POST("/auth/password/reset").WithJSON(map[string]string{"Username": "carol", "Token": token, "NewPassword": "my.new.secret.pass"}).
+					Expect().Status(iris.StatusNoContent)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, _, _ := newTestAuthApp(t)
+			e.POST("/auth/register").WithJSON(map[string]string{"Username": "carol", "Password": "my.secret.pass"}).
+				Expect().Status(iris.StatusCreated)
+			sessions := []*httpexpect.Object{testLogin(e, "carol", "my.secret.pass"), testLogin(e, "carol", "my.secret.pass")}
+
+			tt.revoke(e, sessions[0])
+
+			for _, tokens := range sessions {
+				e.POST("/auth/refresh").WithFormField("refresh_token", tokens.Value("RefreshToken").String().Raw()).
+					Expect().Status(iris.StatusUnauthorized).JSON(problemJSON).Object().ValueEqual("title", schema.ErrUnauthorized)
+			}
+		})
+	}
+}
+
+// testLogin login an user of the default provider, getting its tokens
+func testLogin(e *httpexpect.Expect, username string, password string) *httpexpect.Object {
+	return e.POST("/auth/{provider}", "default").
+		WithFormField("username", username).WithFormField("password", password).WithFormField("domain", "web").
+		Expect().Status(iris.StatusAccepted).JSON().Object()
+}
+
+// testClaims get the claims of the access token of the given tokens, see the protected sample endpoint
+func testClaims(e *httpexpect.Expect, tokens *httpexpect.Object) *httpexpect.Object {
+	return e.GET("/auth/protected").WithHeader("Authorization", "Bearer " + tokens.Value("AccessToken").String().Raw()).
+		Expect().Status(iris.StatusOK).JSON().Object()
+}
@@ -1,14 +1,28 @@
 package endpoints
 
 import (
-	"github.com/go-pg/pg/v10"
+	"bufio"
+	"bytes"
+	stdctx "context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"path/filepath"
 	"github.com/kataras/iris/v12"
-	"go.api.backend/repo/db"
+	"github.com/kataras/iris/v12/context"
+	"github.com/kataras/iris/v12/middleware/requestid"
+	"go.api.backend/api/middlewares"
+	"go.api.backend/lib"
 	"go.api.backend/schema"
 	"go.api.backend/schema/dto"
+	"go.api.backend/schema/errs"
 	"go.api.backend/schema/mapper"
+	"go.api.backend/schema/models"
 	"go.api.backend/service"
 	"go.api.backend/service/utils"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -24,17 +38,17 @@ type HBook struct {
 //
 // - app [*iris.Application] ~ Iris App instance
 //
-// - path [*pg.DB] ~ Postgres database instance
+// - pSvc [*service.SvcBook] ~ Books service instance pointer, backed by the database or the memory repository
 //
 // - r [*utils.SvcResponse] ~ Response service instance
-func NewBookHandler(app *iris.Application, dbCtx *pg.DB, r *utils.SvcResponse) HBook {
+//
+// - pPolicy [*middlewares.RoutePolicy] ~ Route protection policy, guarding the reads (if not public) and the writes
+func NewBookHandler(app *iris.Application, pSvc *service.SvcBook, r *utils.SvcResponse, pPolicy *middlewares.RoutePolicy) HBook {
 
 	// --- VARS SETUP ---
-	// TIP As an alternative, we may not use a pointer and leave the cleaning job to the GO garbage collector
-	bookRepo := db.NewRepoDbBook(dbCtx)									// Instantiating repo
-	bookService := service.NewSvcBooks(&bookRepo)						// Instantiating service
-
-	h := HBook{r, &bookService}
+	h := HBook{r, pSvc}
+	p := *pPolicy
+	mdwAdminGuard := middlewares.NewRoleGuardMiddleware(r, schema.RolAdmin)			// purging the trash requires the admin role
 
 	// --- REGISTERING ENDPOINTS ---
 	booksRouter := app.Party("/books") 						// This is a go closure, but with a named function
@@ -43,13 +57,24 @@ func NewBookHandler(app *iris.Application, dbCtx *pg.DB, r *utils.SvcResponse) H
 		// booksRouter.Use(iris.Compression)
 
 		// --- DEPENDENCIES ---
-		// hero.Register(service.NewSvcBooks(&bookRepo))
+		// hero.Register(pSvc)
 
-		booksRouter.Get("/", h.getBooks)
-		booksRouter.Get("/{id:uint64}", h.getBookById)
-		booksRouter.Post("/", h.createBook)
-		booksRouter.Put("/{id:uint64}", h.updateBook)				// PUT vs PATCH https://stackoverflow.com/a/34400076/4196056
-		booksRouter.Delete("/{id:uint64}", h.delBookById)
+		booksRouter.Get("/", p.Read(h.getBooks)...)
+		booksRouter.Get("/search", p.Read(h.searchBooks)...)
+		booksRouter.Get("/{id:uint64}", p.Read(h.getBookById)...)
+		booksRouter.Get("/export", p.Read(h.exportBooks)...)
+		booksRouter.Post("/import", p.Write(h.importBooks)...)
+		booksRouter.Get("/trash", p.Write(h.getTrash)...)							// the trash is for the writers
+		booksRouter.Post("/{id:uint64}/restore", p.Write(h.restoreBook)...)
+		booksRouter.Delete("/trash/{id:uint64}", p.Authed(mdwAdminGuard, h.purgeBook)...)
+		booksRouter.Delete("/trash", p.Authed(mdwAdminGuard, h.purgeTrash)...)
+		booksRouter.Post("/batch", p.Write(h.createBooks)...)
+		booksRouter.Put("/batch", p.Write(h.updateBooks)...)
+		booksRouter.Delete("/batch", p.Write(h.delBooks)...)
+		booksRouter.Post("/", p.Write(h.createBook)...)
+		booksRouter.Put("/{id:uint64}", p.Write(h.updateBook)...)					// PUT vs PATCH https://stackoverflow.com/a/34400076/4196056
+		booksRouter.Patch("/{id:uint64}", p.Write(h.patchBook)...)
+		booksRouter.Delete("/{id:uint64}", p.Write(h.delBookById)...)
 		// booksRouter.Get("/", hero.Handler(getBooks))					// sample with dependency injection
 		// booksRouter.Post("/", createBooks)							// when no dependencies injection (but context) is needed
 	}
@@ -59,33 +84,101 @@ func NewBookHandler(app *iris.Application, dbCtx *pg.DB, r *utils.SvcResponse) H
 
 // region ======== ENDPOINT HANDLERS =====================================================
 
-// getBooks list all the books in the repository
+// getBooks list the books in the repository, paginated, filtered and sorted
 // @Summary Get Books
-// @Description Get the books in the repository
+// @Description Get a page of books in the repository. It supports offset (page) or keyset (after / before an Id) pagination, filtering and sorting by any book column. Public unless the ReadAccess conf is token, then it requires an access token
+// @Security ApiKeyAuth
 // @Tags Books
 // @Produce json
-// @Success 200 {array} models.Book "List of Books"
+// @Param	page		query	int		false	"Page number, for offset pagination, 1000000 at most"	Format(uint32)
+// @Param	limit		query	int		false	"Page size, 100 at most"						Format(uint32)
+// @Param	after		query	int		false	"Keyset cursor, books placed after this Id"		Format(uint32)
+// @Param	before		query	int		false	"Keyset cursor, books placed before this Id"	Format(uint32)
+// @Param	sort		query	string	false	"Comma separated columns, '-' prefix for descending order. E.g. -items,name"
+// @Param	name		query	string	false	"Case-insensitive name substring"
+// @Param	items_min	query	int		false	"Minimum amount of items"						Format(uint32)
+// @Param	items_max	query	int		false	"Maximum amount of items"						Format(uint32)
+// @Success 200 {object} dto.PageOut{data=[]models.Book} "Page of Books"
+// @Failure 401 {object} dto.ApiError "err.unauthorized"
+// @Failure 422 {object} dto.ApiError "err.invalid_data"
 // @Failure 500 {object} dto.ApiError "err.repo_ops"
 // @Router /books [get]
 func (h HBook) getBooks(ctx iris.Context) {
-	books, err := (*h.service).GetAll()
+	h.listBooks(ctx, false)
+}
+
+// getTrash list the books in the trash (deleted), paginated, filtered and sorted the same way than getBooks
+// @Summary Get the trash
+// @Description Get a page of deleted books, they can be restored until they are purged. It supports the same pagination, filtering and sorting than the books listing. It requires the write scopes (WriteScopes conf, books:write by default)
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Insert access token" default(Bearer <Add access token here>)
+// @Tags Books
+// @Produce json
+// @Param	page		query	int		false	"Page number, for offset pagination, 1000000 at most"	Format(uint32)
+// @Param	limit		query	int		false	"Page size, 100 at most"						Format(uint32)
+// @Param	after		query	int		false	"Keyset cursor, books placed after this Id"		Format(uint32)
+// @Param	before		query	int		false	"Keyset cursor, books placed before this Id"	Format(uint32)
+// @Param	sort		query	string	false	"Comma separated columns, '-' prefix for descending order. E.g. -items,name"
+// @Param	name		query	string	false	"Case-insensitive name substring"
+// @Param	items_min	query	int		false	"Minimum amount of items"						Format(uint32)
+// @Param	items_max	query	int		false	"Maximum amount of items"						Format(uint32)
+// @Success 200 {object} dto.PageOut{data=[]models.Book} "Page of deleted Books"
+// @Failure 401 {object} dto.ApiError "err.unauthorized"
+// @Failure 403 {object} dto.ApiError "err.forbidden"
+// @Failure 422 {object} dto.ApiError "err.invalid_data"
+// @Failure 500 {object} dto.ApiError "err.repo_ops"
+// @Router /books/trash [get]
+func (h HBook) getTrash(ctx iris.Context) {
+	h.listBooks(ctx, true)
+}
+
+// searchBooks full-text search over the books
+// @Summary Search Books
+// @Description Full-text search over the books (the trash excluded). Every word of the query is matched as a prefix (e.g. "hob" matches "Hobbit") and all of them must match. The hits come most relevant first, with a snippet having the matches between <mark> tags. Public unless the ReadAccess conf is token, then it requires an access token
+// @Security ApiKeyAuth
+// @Tags Books
+// @Produce json
+// @Param	q		query	string	true	"Search query, e.g. the hobb"
+// @Param	page	query	int		false	"Page number, 1000000 at most"	Format(uint32)
+// @Param	limit	query	int		false	"Page size, 100 at most"	Format(uint32)
+// @Success 200 {object} dto.PageOut{data=[]models.BookHit} "Page of hits"
+// @Failure 401 {object} dto.ApiError "err.unauthorized"
+// @Failure 422 {object} dto.ApiError "err.invalid_data"
+// @Failure 500 {object} dto.ApiError "err.repo_ops"
+// @Router /books/search [get]
+func (h HBook) searchBooks(ctx iris.Context) {
+	var qDto dto.BookSearchIn
+
+	if e := ctx.ReadQuery(&qDto); e != nil {
+		(*h.response).ResErr(iris.StatusUnprocessableEntity, schema.ErrVal, e.Error(), &ctx) // 422 ReadQuery do the validation here
+		return
+	}
+
+	// Mapping & searching
+	opts := mapper.ToBookSearchV(&qDto)
+	hits, total, err := (*h.service).Search(qDto.Q, opts)
 
 	// Preparing the response
-	if err != nil {
-		(*h.response).ResErr(iris.StatusInternalServerError, schema.ErrRepositoryOps, err.Error(), &ctx)
+	if err != nil {													// e.g. 422 no searchable words
+		(*h.response).ResFromErr(err, &ctx)
 	} else {
-		(*h.response).ResWithDataStatus(iris.StatusOK, books, &ctx)
+		(*h.response).ResWithDataStatus(iris.StatusOK, mkPage(ctx, hits, nil, total, opts), &ctx)	// Offset pagination
 	}
 }
 
 // getBookById Get a book by Id or 404 if doesn't exist
 // @Summary Get book by Id
-// @Description Get a book through its Id
+// @Description Get a book through its Id. The response carries the book ETag (its version), a matching If-None-Match gives a 304. Public unless the ReadAccess conf is token, then it requires an access token
+// @Security ApiKeyAuth
 // @Tags Books
 // @Accept  json
 // @Produce json
-// @Param	id	path	int	true	"Requested Book Id"	Format(uint32)
+// @Param	id				path	int		true	"Requested Book Id"	Format(uint32)
+// @Param	If-None-Match	header	string	false	"Book ETag known by the client"
 // @Success 200 {object} models.Book "OK"
+// @Header	200	{string}	ETag	"Book version"
+// @Success 304 "Not Modified"
+// @Failure 401 {object} dto.ApiError "err.unauthorized"
 // @Failure 404 {object} dto.ApiError "err.not_found"
 // @Failure 500 {object} dto.ApiError "Internal error"
 // @Router /books/{id} [get]
@@ -96,11 +189,16 @@ func(h HBook) getBookById(ctx iris.Context) {
 
 	// Preparing the response
 	if book.CreatedAt != *new(time.Time) && err == nil {											// 200 Founded
+		ctx.Header("ETag", bookETag(&book))
+
+		if inm := ctx.GetHeader("If-None-Match"); inm != "" && etagMatch(inm, bookETag(&book), true) {
+			ctx.StatusCode(iris.StatusNotModified)												// 304 the client copy is fresh
+			return
+		}
+
 		(*h.response).ResWithDataStatus(iris.StatusOK, book, &ctx)
-	} else if err != nil && err.Error()[4:11] == schema.StrDB404 { // 404 from repo
-		(*h.response).ResErr(iris.StatusNotFound, schema.ErrNotFound, schema.ErrDetNotFound, &ctx)
 	} else if err != nil {
-		(*h.response).ResErr(iris.StatusInternalServerError, schema.ErrGeneric, err.Error(), &ctx) // returning some other error may happen
+		(*h.response).ResFromErr(err, &ctx) // 404 from repo, or some other error may happen
 	}
 
 	// Regarding the "Nilnes" IDE warning, I think the book will not be null. Se the called service method.
@@ -108,18 +206,28 @@ func(h HBook) getBookById(ctx iris.Context) {
 
 // delBookById deletes a Book by Id or 404 if doesn't exist
 // @Summary Delete a Book
-// @Description Deletes a Book by its Id
+// @Description Deletes a Book by its Id, moving it to the trash (it can be restored until it is purged). It requires the write scopes (WriteScopes conf, books:write by default)
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Insert access token" default(Bearer <Add access token here>)
 // @Tags Books
 // @Accept  json
 // @Produce  json
-// @Param 	id	path	int true	"Book ID"	Format(uint32)
+// @Param 	id			path	int		true	"Book ID"	Format(uint32)
+// @Param	If-Match	header	string	false	"Book ETag, the book is deleted only if it wasn't modified meanwhile"
 // @Success 204 "No Content"
+// @Failure 401 {object} dto.ApiError "err.unauthorized"
+// @Failure 403 {object} dto.ApiError "err.forbidden"
 // @Failure 404 {object} dto.ApiError "err.not_found"
+// @Failure 412 {object} dto.ApiError "err.precondition_failed"
 // @Failure 500 {object} dto.ApiError "err.repo_ops"
 // @Router /books/{id} [delete]
 func (h HBook) delBookById(ctx iris.Context) {
 	bookId := ctx.Params().GetUintDefault("id", 0)
-	deleted, err := (*h.service).DelByID(&bookId)
+
+	version, ok := h.ifMatch(ctx, bookId, nil)
+	if !ok { return }
+
+	deleted, err := (*h.service).DelByID(auditCtx(ctx), &bookId, version)
 
 	// Preparing the response
 	if err == nil && deleted == 0 {
@@ -127,18 +235,22 @@ func (h HBook) delBookById(ctx iris.Context) {
 	} else if err == nil && deleted > 0 {
 		(*h.response).ResDelete(&ctx) // 204 & empty schema
 	} else if err != nil {
-		(*h.response).ResErr(iris.StatusInternalServerError, schema.ErrRepositoryOps, err.Error(), &ctx) // returning some other error may happen
+		(*h.response).ResFromErr(err, &ctx) // returning some other error may happen
 	}
 }
 
 // createBook create a new book
 // @Summary Create a new book
-// @Description Create a new book from the passed schema
+// @Description Create a new book from the passed schema. It requires the write scopes (WriteScopes conf, books:write by default)
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Insert access token" default(Bearer <Add access token here>)
 // @Tags Books
 // @Accept	json
 // @Produce json
 // @Param	book	body	dto.BookCreateIn	true	"Book Data"
 // @Success 201 {object} models.Book "OK"
+// @Failure 401 {object} dto.ApiError "err.unauthorized"
+// @Failure 403 {object} dto.ApiError "err.forbidden"
 // @Failure 422 {object} dto.ApiError "err.duplicate_key || Invalid schema"
 // @Failure 500 {object} dto.ApiError "err.repo_ops || Internal error"
 // @Router /books [post]
@@ -155,15 +267,9 @@ func (h HBook) createBook(ctx iris.Context) {
 	// Mapping
 	book := mapper.ToBookCreateV(&bDto)
 
-	err := (*h.service).Create(book)
+	err := (*h.service).Create(auditCtx(ctx), book)
 	if err != nil {
-
-		if err.Error() == schema.ErrDuplicateKey { // 422 Unprocessable 'cause duplicate key
-			(*h.response).ResErr(iris.StatusUnprocessableEntity, schema.ErrDuplicateKey, schema.ErrDetDuplicateKey, &ctx)
-		} else {																							// 500
-			(*h.response).ResErr(iris.StatusInternalServerError, schema.ErrRepositoryOps, err.Error(), &ctx)
-		}
-
+		(*h.response).ResFromErr(err, &ctx) // 422 Unprocessable 'cause duplicate key, or 500
 	} else {		// All good
 		(*h.response).ResWithDataStatus(iris.StatusCreated, book, &ctx)
 	}
@@ -171,14 +277,21 @@ func (h HBook) createBook(ctx iris.Context) {
 
 // updateBook update the book having the Id passed as path parameter, with the schema passed in the request body
 // @Summary Update the indicated book
-// @Description Update the book having the specified Id with the schema passed in the request body
+// @Description Update the book having the specified Id with the schema passed in the request body. It requires the write scopes (WriteScopes conf, books:write by default)
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Insert access token" default(Bearer <Add access token here>)
 // @Tags Books
 // @Accept	json
 // @Produce json
-// @Param 	id		path	int					true	"Book ID"	Format(uint32)
-// @Param	book	body	dto.BookUpdateIn	true	"Book Data"
+// @Param 	id			path	int					true	"Book ID"	Format(uint32)
+// @Param	If-Match	header	string				false	"Book ETag, the book is updated only if it wasn't modified meanwhile"
+// @Param	book		body	dto.BookUpdateIn	true	"Book Data"
 // @Success 200 {object} models.Book "OK"
+// @Header	200	{string}	ETag	"New book version"
+// @Failure 401 {object} dto.ApiError "err.unauthorized"
+// @Failure 403 {object} dto.ApiError "err.forbidden"
 // @Failure 404 {object} dto.ApiError "err.not_found"
+// @Failure 412 {object} dto.ApiError "err.precondition_failed"
 // @Failure 422 {object} dto.ApiError "err.duplicate_key || Invalid schema"
 // @Failure 500 {object} dto.ApiError "err.repo_ops || Internal error"
 // @Router /books/{id} [put]
@@ -195,21 +308,823 @@ func (h HBook) updateBook(ctx iris.Context) {
 	// Mapping
 	book := mapper.ToBookUpdateV(&bDto)
 
+	// Preconditions
+	version, ok := h.ifMatch(ctx, book.Id, nil)
+	if !ok { return }
+	book.Version = version
+
 	// Updating
-	updated, err := (*h.service).UpdateBook(book)
-
-	if err != nil && err.Error() == schema.ErrNotFound { // 404 Wrong ID
-		(*h.response).ResErr(iris.StatusNotFound, schema.ErrNotFound, schema.ErrDetNotFound, &ctx)
-	} else if err != nil && err.Error() == schema.ErrDuplicateKey { // Same unique field, name in this case
-		(*h.response).ResErr(iris.StatusUnprocessableEntity, schema.ErrDuplicateKey, schema.ErrDetDuplicateKey, &ctx)
-	} else if err != nil {																				// Something happen
-		(*h.response).ResErr(iris.StatusInternalServerError, schema.ErrRepositoryOps, err.Error(), &ctx)
+	updated, err := (*h.service).UpdateBook(auditCtx(ctx), book)
+
+	if err != nil {																						// 404 Wrong ID, 412 modified meanwhile, 422 same unique field (name in this case) or something happen
+		(*h.response).ResFromErr(err, &ctx)
 	} else if updated > 0 {																				// All good, bDto was updated
+		ctx.Header("ETag", bookETag(book))
+		(*h.response).ResWithDataStatus(iris.StatusOK, book, &ctx)
+	}
+}
+
+// patchBook partially update the book having the Id passed as path parameter, applying the patch in the request body
+// @Summary Patch the indicated book
+// @Description Partially update a book with a JSON Merge Patch (application/merge-patch+json) or a JSON Patch (application/json-patch+json) over its editable fields, e.g. {"Items": 12} or [{"op": "replace", "path": "/Items", "value": 12}]. Only the changed columns are written. It requires the write scopes (WriteScopes conf, books:write by default)
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Insert access token" default(Bearer <Add access token here>)
+// @Tags Books
+// @Accept	application/merge-patch+json,application/json-patch+json
+// @Produce json
+// @Param 	id			path	int					true	"Book ID"	Format(uint32)
+// @Param	If-Match	header	string				false	"Book ETag, the book is patched only if it wasn't modified meanwhile"
+// @Param	patch		body	dto.BookCreateIn	true	"Patch document"
+// @Success 200 {object} models.Book "OK"
+// @Header	200	{string}	ETag	"New book version"
+// @Failure 401 {object} dto.ApiError "err.unauthorized"
+// @Failure 403 {object} dto.ApiError "err.forbidden"
+// @Failure 404 {object} dto.ApiError "err.not_found"
+// @Failure 412 {object} dto.ApiError "err.precondition_failed"
+// @Failure 415 {object} dto.ApiError "err.unsupported_media_type"
+// @Failure 422 {object} dto.ApiError "err.duplicate_key || err.invalid_data"
+// @Failure 500 {object} dto.ApiError "err.repo_ops || Internal error"
+// @Router /books/{id} [patch]
+func (h HBook) patchBook(ctx iris.Context) {
+	bookId := ctx.Params().GetUintDefault("id", 0)
+
+	patch, err := ctx.GetBody()
+	if err != nil {
+		(*h.response).ResErr(iris.StatusUnprocessableEntity, schema.ErrVal, err.Error(), &ctx)
+		return
+	}
+
+	// Current book
+	book, err := (*h.service).GetByID(&bookId)
+	if err != nil {
+		(*h.response).ResFromErr(err, &ctx) // 404 from repo, or some other error may happen
+		return
+	}
+
+	// Preconditions, the update is conditioned to the matched version (if any)
+	version, ok := h.ifMatch(ctx, bookId, &book)
+	if !ok { return }
+	current := book.Version
+	book.Version = version
+
+	// Patching the editable fields document
+	doc, _ := json.Marshal(mapper.ToBookPatchDocV(&book))
+	patched, err := lib.ApplyPatch(doc, patch, ctx.GetContentTypeRequested())
+	if err == lib.ErrPatchMediaType {
+		(*h.response).ResErr(iris.StatusUnsupportedMediaType, schema.ErrMediaType, err.Error(), &ctx)
+		return
+	} else if err != nil {
+		(*h.response).ResErr(iris.StatusUnprocessableEntity, schema.ErrVal, schema.ErrDetInvalidPatch + ": " + err.Error(), &ctx)
+		return
+	}
+
+	// Validating the patched document the same way than the create / update ones, unknown fields aren't allowed
+	var bDto dto.BookCreateIn
+	dec := json.NewDecoder(bytes.NewReader(patched))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&bDto); err != nil {
+		(*h.response).ResErr(iris.StatusUnprocessableEntity, schema.ErrVal, schema.ErrDetInvalidPatch + ": " + err.Error(), &ctx)
+		return
+	}
+	if err := ctx.Application().Validate(&bDto); err != nil {
+		(*h.response).ResErr(iris.StatusUnprocessableEntity, schema.ErrVal, err.Error(), &ctx)
+		return
+	}
+
+	// Updating only the touched columns, if any
+	if columns := mapper.ToBookPatchV(&book, &bDto); len(columns) > 0 {
+		if _, err := (*h.service).UpdateBook(auditCtx(ctx), &book, columns...); err != nil {
+			(*h.response).ResFromErr(err, &ctx) // 404 deleted meanwhile, 412 modified meanwhile, 422 same unique field or something happen
+			return
+		}
+	} else if version == 0 {
+		book.Version = current						// Nothing changed, the version remains
+	}
+
+	ctx.Header("ETag", bookETag(&book))
+	(*h.response).ResWithDataStatus(iris.StatusOK, book, &ctx)
+}
+
+// restoreBook take a book out of the trash
+// @Summary Restore a deleted book
+// @Description Take a book out of the trash. It fails if its name was taken meanwhile by another book. It requires the write scopes (WriteScopes conf, books:write by default)
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Insert access token" default(Bearer <Add access token here>)
+// @Tags Books
+// @Produce json
+// @Param 	id	path	int	true	"Book ID"	Format(uint32)
+// @Success 200 {object} models.Book "OK"
+// @Failure 401 {object} dto.ApiError "err.unauthorized"
+// @Failure 403 {object} dto.ApiError "err.forbidden"
+// @Failure 404 {object} dto.ApiError "err.not_found"
+// @Failure 422 {object} dto.ApiError "err.duplicate_key"
+// @Failure 500 {object} dto.ApiError "err.repo_ops"
+// @Router /books/{id}/restore [post]
+func (h HBook) restoreBook(ctx iris.Context) {
+	bookId := ctx.Params().GetUintDefault("id", 0)
+	book, err := (*h.service).Restore(auditCtx(ctx), &bookId)
+
+	if err != nil {
+		(*h.response).ResFromErr(err, &ctx) // 404 not in the trash, 422 name taken meanwhile, or something happen
+	} else {
+		ctx.Header("ETag", bookETag(&book))
 		(*h.response).ResWithDataStatus(iris.StatusOK, book, &ctx)
 	}
 }
 
+// purgeBook delete for good a book in the trash
+// @Summary Purge a deleted book
+// @Description Delete for good a book in the trash, it can't be restored anymore. It requires the admin role
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Insert access token" default(Bearer <Add access token here>)
+// @Tags Books
+// @Produce json
+// @Param 	id	path	int	true	"Book ID"	Format(uint32)
+// @Success 204 "No Content"
+// @Failure 401 {object} dto.ApiError "err.unauthorized"
+// @Failure 403 {object} dto.ApiError "err.forbidden"
+// @Failure 404 {object} dto.ApiError "err.not_found"
+// @Failure 500 {object} dto.ApiError "err.repo_ops"
+// @Router /books/trash/{id} [delete]
+func (h HBook) purgeBook(ctx iris.Context) {
+	bookId := ctx.Params().GetUintDefault("id", 0)
+	purged, err := (*h.service).PurgeByID(auditCtx(ctx), &bookId)
+
+	if err != nil {
+		(*h.response).ResFromErr(err, &ctx)
+	} else if purged == 0 {
+		(*h.response).ResErr(iris.StatusNotFound, schema.ErrNotFound, schema.ErrDetNotFound, &ctx) // 404 not in the trash
+	} else {
+		(*h.response).ResDelete(&ctx)
+	}
+}
+
+// purgeTrash delete for good all the books in the trash
+// @Summary Empty the trash
+// @Description Delete for good all the books in the trash, they can't be restored anymore. It requires the admin role
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Insert access token" default(Bearer <Add access token here>)
+// @Tags Books
+// @Produce json
+// @Success 204 "No Content"
+// @Failure 401 {object} dto.ApiError "err.unauthorized"
+// @Failure 403 {object} dto.ApiError "err.forbidden"
+// @Failure 500 {object} dto.ApiError "err.repo_ops"
+// @Router /books/trash [delete]
+func (h HBook) purgeTrash(ctx iris.Context) {
+	if _, err := (*h.service).PurgeTrash(auditCtx(ctx)); err != nil {
+		(*h.response).ResFromErr(err, &ctx)
+	} else {
+		(*h.response).ResDelete(&ctx)
+	}
+}
+
+// createBooks create many books in one transaction
+// @Summary Create books in batch
+// @Description Create many books in one transaction. Every item is validated on its own and gets an outcome (created, duplicate, invalid...). If atomic then any failed item rolls back the whole batch (422), otherwise the rest of the items are kept. It requires the write scopes (WriteScopes conf, books:write by default)
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Insert access token" default(Bearer <Add access token here>)
+// @Tags Books
+// @Accept	json
+// @Produce json
+// @Param	batch	body	dto.BookBatchCreateIn	true	"Books Data, 1000 at most"
+// @Success 200 {object} dto.BatchOut "Outcome per book"
+// @Failure 401 {object} dto.ApiError "err.unauthorized"
+// @Failure 403 {object} dto.ApiError "err.forbidden"
+// @Failure 422 {object} dto.BatchOut "Atomic batch rolled back || err.invalid_data"
+// @Failure 500 {object} dto.ApiError "err.repo_ops"
+// @Router /books/batch [post]
+func (h HBook) createBooks(ctx iris.Context) {
+	var bDto dto.BookBatchCreateIn
+
+	if e := ctx.ReadJSON(&bDto); e != nil {
+		(*h.response).ResErr(iris.StatusUnprocessableEntity, schema.ErrVal, e.Error(), &ctx)
+		return
+	}
+
+	// Validating every item, only the valid ones reach the service
+	results, idx := mkBatchResults(ctx, len(bDto.Items), func(i int) interface{} { return &bDto.Items[i] })
+	books := make([]models.Book, len(idx))
+	for j, i := range idx { books[j] = *mapper.ToBookCreateV(&bDto.Items[i]) }
+
+	var outcomes []error
+	var err error
+	if !bDto.Atomic || len(idx) == len(results) { outcomes, err = (*h.service).CreateBatch(auditCtx(ctx), books, bDto.Atomic) }
+
+	h.resBatch(ctx, bDto.Atomic, results, idx, outcomes, err, schema.BatchCreated, func(j int) uint { return books[j].Id })
+}
+
+// updateBooks update many books in one transaction
+// @Summary Update books in batch
+// @Description Update many books in one transaction, see the batch creation. It requires the write scopes (WriteScopes conf, books:write by default)
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Insert access token" default(Bearer <Add access token here>)
+// @Tags Books
+// @Accept	json
+// @Produce json
+// @Param	batch	body	dto.BookBatchUpdateIn	true	"Books Data, 1000 at most"
+// @Success 200 {object} dto.BatchOut "Outcome per book"
+// @Failure 401 {object} dto.ApiError "err.unauthorized"
+// @Failure 403 {object} dto.ApiError "err.forbidden"
+// @Failure 422 {object} dto.BatchOut "Atomic batch rolled back || err.invalid_data"
+// @Failure 500 {object} dto.ApiError "err.repo_ops"
+// @Router /books/batch [put]
+func (h HBook) updateBooks(ctx iris.Context) {
+	var bDto dto.BookBatchUpdateIn
+
+	if e := ctx.ReadJSON(&bDto); e != nil {
+		(*h.response).ResErr(iris.StatusUnprocessableEntity, schema.ErrVal, e.Error(), &ctx)
+		return
+	}
+
+	results, idx := mkBatchResults(ctx, len(bDto.Items), func(i int) interface{} { return &bDto.Items[i] })
+	books := make([]models.Book, len(idx))
+	for j, i := range idx { books[j] = *mapper.ToBookUpdateV(&bDto.Items[i]) }
+
+	var outcomes []error
+	var err error
+	if !bDto.Atomic || len(idx) == len(results) { outcomes, err = (*h.service).UpdateBatch(auditCtx(ctx), books, bDto.Atomic) }
+
+	h.resBatch(ctx, bDto.Atomic, results, idx, outcomes, err, schema.BatchUpdated, func(j int) uint { return books[j].Id })
+}
+
+// delBooks delete many books in one transaction
+// @Summary Delete books in batch
+// @Description Delete (move to the trash) many books in one transaction, see the batch creation. It requires the write scopes (WriteScopes conf, books:write by default)
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Insert access token" default(Bearer <Add access token here>)
+// @Tags Books
+// @Accept	json
+// @Produce json
+// @Param	batch	body	dto.BookBatchDeleteIn	true	"Books Ids, 1000 at most"
+// @Success 200 {object} dto.BatchOut "Outcome per book"
+// @Failure 401 {object} dto.ApiError "err.unauthorized"
+// @Failure 403 {object} dto.ApiError "err.forbidden"
+// @Failure 422 {object} dto.BatchOut "Atomic batch rolled back || err.invalid_data"
+// @Failure 500 {object} dto.ApiError "err.repo_ops"
+// @Router /books/batch [delete]
+func (h HBook) delBooks(ctx iris.Context) {
+	var bDto dto.BookBatchDeleteIn
+
+	if e := ctx.ReadJSON(&bDto); e != nil {
+		(*h.response).ResErr(iris.StatusUnprocessableEntity, schema.ErrVal, e.Error(), &ctx) // 422 ReadJSON validate the Ids here
+		return
+	}
+
+	results, idx := mkBatchResults(ctx, len(bDto.Ids), nil)
+	outcomes, err := (*h.service).DelBatch(auditCtx(ctx), bDto.Ids, bDto.Atomic)
+
+	h.resBatch(ctx, bDto.Atomic, results, idx, outcomes, err, schema.BatchDeleted, func(j int) uint { return bDto.Ids[j] })
+}
+
+// exportBooks stream all the books as a CSV or JSON Lines file
+// @Summary Export the books
+// @Description Download all the books (excluding the trash) as a CSV or JSON Lines file. The books are streamed, so the catalog size doesn't matter. Public unless the ReadAccess conf is token, then it requires an access token
+// @Security ApiKeyAuth
+// @Tags Books
+// @Produce text/csv,application/x-ndjson
+// @Param	format	query	string	false	"File format, csv (default) or jsonl"	Enums(csv, jsonl)
+// @Success 200 {file} file "Books file"
+// @Failure 401 {object} dto.ApiError "err.unauthorized"
+// @Failure 422 {object} dto.ApiError "err.invalid_data"
+// @Failure 500 {object} dto.ApiError "err.repo_ops"
+// @Router /books/export [get]
+func (h HBook) exportBooks(ctx iris.Context) {
+	var fDto dto.BookFileIn
+
+	if e := ctx.ReadQuery(&fDto); e != nil {
+		(*h.response).ResErr(iris.StatusUnprocessableEntity, schema.ErrVal, e.Error(), &ctx)
+		return
+	}
+	if fDto.Format == "" { fDto.Format = fileCsv }
+
+	ctx.ContentType(fileMediaTypes[fDto.Format])
+	ctx.Header("Content-Disposition", `attachment; filename="books.` + fDto.Format + `"`)
+	ctx.StatusCode(iris.StatusOK)
+
+	// Row writers, the output is flushed to the client every exportFlushEvery books
+	var write func(book *models.Book) error
+	var flush func() error
+	n := 0
+
+	if fDto.Format == fileCsv {
+		w := csv.NewWriter(ctx.ResponseWriter())
+		_ = w.Write(mapper.BookCsvHeader)
+
+		write = func(book *models.Book) error { return w.Write(mapper.ToBookCsvRecordV(book)) }
+		flush = func() error { w.Flush(); return w.Error() }
+	} else {
+		w := bufio.NewWriter(ctx.ResponseWriter())
+		enc := json.NewEncoder(w)
+
+		write = func(book *models.Book) error { return enc.Encode(book) }
+		flush = w.Flush
+	}
+
+	err := (*h.service).ForEach(func(book *models.Book) error {
+		if err := write(book); err != nil { return err }
+
+		if n++; n % exportFlushEvery == 0 {
+			if err := flush(); err != nil { return err }
+			ctx.ResponseWriter().Flush()
+		}
+		return nil
+	})
+	if err == nil { err = flush() }
+
+	if err != nil && ctx.ResponseWriter().Written() == context.NoWritten {
+		(*h.response).ResFromErr(err, &ctx)											// Nothing sent yet, we can still respond the error
+	} else if err != nil {
+		ctx.Application().Logger().Error("books export interrupted: ", err.Error())	// The client gets a truncated file
+	}
+}
+
+// importBooks create or update (by name) the books of a CSV or JSON Lines file
+// @Summary Import books
+// @Description Upload a CSV or JSON Lines file with books, they are created or, if there is already a book with the same name, updated (the items). Every row is validated like a new book, the CSV header must have the Name and Items columns (the rest are ignored, so an export can be imported back). The failed rows are reported. It requires the write scopes (WriteScopes conf, books:write by default)
+// @Security ApiKeyAuth
+// @Param Authorization header string true "Insert access token" default(Bearer <Add access token here>)
+// @Tags Books
+// @Accept	multipart/form-data
+// @Produce json
+// @Param	file	formData	file	true	"CSV or JSON Lines file"
+// @Param	format	query		string	false	"File format, csv or jsonl. Taken from the file extension if omitted"	Enums(csv, jsonl)
+// @Success 200 {object} dto.ImportOut "Import report"
+// @Failure 401 {object} dto.ApiError "err.unauthorized"
+// @Failure 403 {object} dto.ApiError "err.forbidden"
+// @Failure 422 {object} dto.ApiError "err.invalid_data"
+// @Failure 500 {object} dto.ApiError "err.repo_ops"
+// @Router /books/import [post]
+func (h HBook) importBooks(ctx iris.Context) {
+	var fDto dto.BookFileIn
+
+	if e := ctx.ReadQuery(&fDto); e != nil {
+		(*h.response).ResErr(iris.StatusUnprocessableEntity, schema.ErrVal, e.Error(), &ctx)
+		return
+	}
+
+	file, fHeader, err := ctx.FormFile("file")
+	if err != nil {
+		(*h.response).ResErr(iris.StatusUnprocessableEntity, schema.ErrVal, err.Error(), &ctx)
+		return
+	}
+	defer file.Close()
+
+	// Format, from the query or the file extension
+	if fDto.Format == "" { fDto.Format = strings.TrimPrefix(strings.ToLower(filepath.Ext(fHeader.Filename)), ".") }
+
+	rows, err := newBookRows(file, fDto.Format)
+	if err != nil {
+		(*h.response).ResErr(iris.StatusUnprocessableEntity, schema.ErrVal, err.Error(), &ctx)
+		return
+	}
+
+	// Upserting the valid rows in chunks, every chunk in its own transaction
+	report := dto.ImportOut{Errors: make([]dto.ImportRowOut, 0)}
+	books, lines := make([]models.Book, 0, importChunk), make([]int, 0, importChunk)
+
+	upsert := func() error {
+		created, outcomes, err := (*h.service).UpsertBatch(auditCtx(ctx), books)
+		if err != nil { return err }
+
+		for j, o := range outcomes {
+			if o != nil {
+				_, key, detail := batchOutcome(o)
+				report.Failed++
+				report.Errors = append(report.Errors, dto.ImportRowOut{Row: lines[j], Error: key, Detail: detail})
+			} else if created[j] {
+				report.Created++
+			} else {
+				report.Updated++
+			}
+		}
+
+		books, lines = books[:0], lines[:0]
+		return nil
+	}
+
+	for {
+		line, bDto, err := rows.next()
+		if err == io.EOF { break }
+
+		if err == nil { err = ctx.Application().Validate(bDto) }
+		if err != nil && line == 0 {											// Not a row error, the file can't be read
+			(*h.response).ResErr(iris.StatusUnprocessableEntity, schema.ErrVal, err.Error(), &ctx)
+			return
+		} else if err != nil {
+			report.Failed++
+			report.Errors = append(report.Errors, dto.ImportRowOut{Row: line, Error: schema.ErrVal, Detail: err.Error()})
+			continue
+		}
+
+		books, lines = append(books, *mapper.ToBookCreateV(bDto)), append(lines, line)
+		if len(books) == importChunk {
+			if err := upsert(); err != nil {
+				(*h.response).ResFromErr(err, &ctx)
+				return
+			}
+		}
+	}
+
+	if len(books) > 0 {
+		if err := upsert(); err != nil {
+			(*h.response).ResFromErr(err, &ctx)
+			return
+		}
+	}
+
+	(*h.response).ResOKWithData(report, &ctx)
+}
+
 // endregion =============================================================================
 
 // region ======== LOCAL DEPENDENCIES ====================================================
 // endregion =============================================================================
+
+// region ======== HELPERS ===============================================================
+
+// auditCtx get the request context carrying the actor of the request (access token subject and request ID), so
+// the service writes are attributed to it in the audit log (see service.WithAuditActor)
+//
+// - ctx [iris.Context] ~ Iris Request context
+func auditCtx(ctx iris.Context) stdctx.Context {
+	actor := service.AuditActor{RequestId: requestid.Get(ctx)}
+	if claims := middlewares.Claims(ctx); claims != nil { actor.Sub = claims.Claims.Sub }
+
+	return service.WithAuditActor(ctx.Request().Context(), actor)
+}
+
+// listBooks respond a page of books (see getBooks), from the trash or from the regular ones
+//
+// - ctx [iris.Context] ~ Iris Request context
+//
+// - trash [bool] ~ List the books in the trash
+func (h HBook) listBooks(ctx iris.Context, trash bool) {
+	var qDto dto.BookListIn
+
+	if e := ctx.ReadQuery(&qDto); e != nil {
+		(*h.response).ResErr(iris.StatusUnprocessableEntity, schema.ErrVal, e.Error(), &ctx) // 422 ReadQuery do the validation here
+		return
+	}
+
+	// Mapping
+	opts, filter := mapper.ToBookQueryV(&qDto)
+	filter.Deleted = trash
+
+	books, total, err := (*h.service).GetAll(opts, filter)
+
+	// Preparing the response
+	if err != nil {													// e.g. 422 wrong sorting column
+		(*h.response).ResFromErr(err, &ctx)
+	} else {
+		keys := make([]uint, len(books))
+		for i := range books { keys[i] = books[i].Id }
+
+		(*h.response).ResWithDataStatus(iris.StatusOK, mkPage(ctx, books, keys, total, opts), &ctx)
+	}
+}
+
+// mkPage create the response envelope for a page of any listing, with the links to the next and previous pages.
+// The links keep the request query parameters (filters, sorting, limit) and only change the pagination ones.
+//
+// - ctx [iris.Context] ~ Iris Request context
+//
+// - data [interface{}] ~ Page items
+//
+// - keys [[]uint] ~ Ids of the page items, in order. Only needed for keyset pagination, nil otherwise
+//
+// - total [int] ~ Total amount of items matching the filter
+//
+// - opts [*dto.QueryOpts] ~ Pagination options used for getting the page
+func mkPage(ctx iris.Context, data interface{}, keys []uint, total int, opts *dto.QueryOpts) dto.PageOut {
+	page := dto.PageOut{Data: data, Total: total, Limit: opts.Limit}
+
+	link := func(param string, value uint) string {
+		u := *ctx.Request().URL
+		q := u.Query()
+		q.Del("page"); q.Del("after"); q.Del("before")
+		q.Set(param, strconv.FormatUint(uint64(value), 10))
+		u.RawQuery = q.Encode()
+
+		return u.RequestURI()
+	}
+
+	if !opts.IsKeyset() {								// Offset pagination
+		page.Page = opts.Page
+		if int(opts.Page * opts.Limit) < total { page.Next = link("page", opts.Page + 1) }
+		if opts.Page > 1 { page.Prev = link("page", opts.Page - 1) }
+
+		return page
+	}
+
+	// Keyset pagination. A full page means there could be more items in the walking direction, and the cursor
+	// itself is the proof that there are items in the opposite one
+	if len(keys) > 0 {
+		full := uint(len(keys)) == opts.Limit
+		if opts.Before > 0 || full { page.Next = link("after", keys[len(keys) - 1]) }
+		if opts.After > 0 || (opts.Before > 0 && full) { page.Prev = link("before", keys[0]) }
+	}
+
+	return page
+}
+
+// ifMatch check the If-Match precondition (if any) against the current book. It returns the book version the write
+// must be conditioned to (0 when there is no precondition), or false if the request was already responded (412,
+// 404 or 500). The conditioned write catches the modifications done between this check and the write itself
+//
+// - ctx [iris.Context] ~ Iris Request context
+//
+// - id [uint] ~ Book Id
+//
+// - current [*models.Book] ~ Current book, if it was already fetched. Otherwise (nil) it's fetched here
+func (h HBook) ifMatch(ctx iris.Context, id uint, current *models.Book) (uint, bool) {
+	im := ctx.GetHeader("If-Match")
+	if im == "" { return 0, true }
+
+	if current == nil {
+		book, err := (*h.service).GetByID(&id)
+		if err != nil {
+			(*h.response).ResFromErr(err, &ctx)
+			return 0, false
+		}
+		current = &book
+	}
+
+	if !etagMatch(im, bookETag(current), false) {
+		(*h.response).ResFromErr(errs.New(errs.PreconditionFailed, schema.ErrPrecondition, schema.ErrDetPrecondition), &ctx)
+		return 0, false
+	}
+
+	return current.Version, true
+}
+
+// bookETag get the entity tag (a strong one) of a book, its version
+func bookETag(book *models.Book) string {
+	return `"` + strconv.FormatUint(uint64(book.Version), 10) + `"`
+}
+
+// etagMatch tells if an If-Match / If-None-Match header value (an entity tags list or *) matches the entity tag.
+// The weak comparison (If-None-Match) ignores the W/ prefix, the strong one (If-Match) never matches weak tags.
+// See https://tools.ietf.org/html/rfc7232#section-2.3.2
+//
+// - header [string] ~ Header value, e.g. "3", W/"3" or *
+//
+// - etag [string] ~ Current entity tag
+//
+// - weak [bool] ~ Use the weak comparison
+func etagMatch(header string, etag string, weak bool) bool {
+	if strings.TrimSpace(header) == "*" { return true }
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+
+		if strings.HasPrefix(tag, "W/") {
+			if !weak { continue }
+			tag = tag[2:]
+		}
+
+		if tag == etag { return true }
+	}
+
+	return false
+}
+
+// mkBatchResults create the results of a batch operation, validating every item. It returns the results (the
+// invalid items already with their outcome) and the indexes of the valid items
+//
+// - ctx [iris.Context] ~ Iris Request context
+//
+// - n [int] ~ Amount of items
+//
+// - item [func(i int) interface{}] ~ Get the item i to be validated, nil if the items need no validation
+func mkBatchResults(ctx iris.Context, n int, item func(i int) interface{}) ([]dto.BatchItemOut, []int) {
+	results := make([]dto.BatchItemOut, n)
+	idx := make([]int, 0, n)
+
+	for i := range results {
+		results[i].Index = i
+
+		if item != nil {
+			if err := ctx.Application().Validate(item(i)); err != nil {
+				results[i].Status, results[i].Error, results[i].Detail = schema.BatchInvalid, schema.ErrVal, err.Error()
+				continue
+			}
+		}
+
+		idx = append(idx, i)
+	}
+
+	return results, idx
+}
+
+// resBatch complete the batch results with the outcomes of the valid items (see mkBatchResults), and respond them.
+// A batch with no outcomes (nil) is an atomic one that didn't reach the service because of invalid items
+//
+// - ctx [iris.Context] ~ Iris Request context
+//
+// - atomic [bool] ~ All-or-nothing batch
+//
+// - results [[]dto.BatchItemOut] ~ Batch results
+//
+// - idx [[]int] ~ Indexes of the valid items, the ones the outcomes belong to
+//
+// - outcomes [[]error] ~ Outcome of every valid item, nil if it was fine
+//
+// - err [error] ~ Batch error, if any
+//
+// - okStatus [string] ~ Status of the fine items, e.g. schema.BatchCreated
+//
+// - id [func(j int) uint] ~ Get the book Id of the valid item j
+func (h HBook) resBatch(ctx iris.Context, atomic bool, results []dto.BatchItemOut, idx []int, outcomes []error, err error, okStatus string, id func(j int) uint) {
+	if err != nil {
+		(*h.response).ResFromErr(err, &ctx)
+		return
+	}
+
+	failed := len(idx) < len(results)
+	for _, o := range outcomes {
+		if o != nil { failed = true }
+	}
+	applied := !(atomic && failed)
+
+	for j, i := range idx {
+		r := &results[i]
+		r.Id = id(j)
+
+		var o error
+		if outcomes != nil { o = outcomes[j] }
+
+		if o != nil {
+			r.Status, r.Error, r.Detail = batchOutcome(o)
+		} else if !applied {
+			r.Status, r.Detail = schema.BatchRolledBack, schema.ErrDetBatchRolledBack
+			if okStatus == schema.BatchCreated { r.Id = 0 }			// The Id of a rolled back insert is meaningless
+		} else {
+			r.Status = okStatus
+		}
+	}
+
+	status := iris.StatusOK
+	if !applied { status = iris.StatusUnprocessableEntity }
+
+	(*h.response).ResWithDataStatus(status, dto.BatchOut{Applied: applied, Results: results}, &ctx)
+}
+
+// batchOutcome get the status, the i18n error key and the detail of a failed batch item
+func batchOutcome(err error) (string, string, string) {
+	var e *errs.Error
+	if !errors.As(err, &e) { return schema.BatchFailed, schema.ErrGeneric, err.Error() }
+
+	status := schema.BatchFailed
+	switch e.Kind {
+	case errs.Conflict:   status = schema.BatchDuplicate
+	case errs.NotFound:   status = schema.BatchNotFound
+	case errs.Validation: status = schema.BatchInvalid
+	}
+
+	detail := e.Detail
+	if detail == "" { detail = err.Error() }
+
+	return status, e.Key, detail
+}
+
+// endregion =============================================================================
+
+// region ======== FILES (IMPORT / EXPORT) ===============================================
+
+const (
+	fileCsv   = "csv"
+	fileJsonl = "jsonl"
+
+	exportFlushEvery = 500 // Books written between the flushes of the export stream
+	importChunk      = 500 // Books upserted per transaction in the import
+)
+
+// fileMediaTypes the media type of every supported file format
+var fileMediaTypes = map[string]string{
+	fileCsv:   "text/csv; charset=utf-8",
+	fileJsonl: "application/x-ndjson; charset=utf-8",
+}
+
+// bookRows read the books of an import file, row by row. next returns the row line and the book, or io.EOF at
+// the end. A row error (e.g. a wrong number) comes with the row line, an error without line (0) means that the file
+// can't be read anymore
+type bookRows interface {
+	next() (int, *dto.BookCreateIn, error)
+}
+
+// newBookRows create the rows reader for the given file format. For CSV the header is read, it must contain the
+// Name & Items columns (case-insensitive), the rest of the columns are ignored
+//
+// - r [io.Reader] ~ File content
+//
+// - format [string] ~ File format, csv or jsonl
+func newBookRows(r io.Reader, format string) (bookRows, error) {
+	switch format {
+	case fileCsv:
+		lr := &lineReader{r: bufio.NewReader(r)}
+		cr := csv.NewReader(lr)
+		cr.FieldsPerRecord = -1							// Rows with a wrong amount of fields are row errors, see next
+		cr.ReuseRecord = true
+
+		header, err := cr.Read()
+		if err != nil { return nil, errors.New("reading the CSV header: " + err.Error()) }
+
+		rows := &csvBookRows{r: cr, lines: lr, name: -1, items: -1}
+		for i, col := range header {
+			switch strings.ToLower(strings.TrimSpace(col)) {
+			case "name":  rows.name = i
+			case "items": rows.items = i
+			}
+		}
+		if rows.name < 0 || rows.items < 0 { return nil, errors.New("the CSV header must have the Name and Items columns") }
+
+		return rows, nil
+
+	case fileJsonl:
+		sc := bufio.NewScanner(r)
+		sc.Buffer(make([]byte, 64 * 1024), 1024 * 1024)
+
+		return &jsonlBookRows{sc: sc}, nil
+	}
+
+	return nil, errors.New("unknown file format '" + format + "', use csv or jsonl")
+}
+
+// csvBookRows is the CSV rows reader, see newBookRows
+type csvBookRows struct {
+	r     *csv.Reader
+	lines *lineReader // CSV reader source, counting the lines
+	name  int         // Name column index
+	items int         // Items column index
+}
+
+func (c *csvBookRows) next() (int, *dto.BookCreateIn, error) {
+	rec, err := c.r.Read()
+
+	var pErr *csv.ParseError
+	if errors.As(err, &pErr) {
+		return pErr.StartLine, nil, err
+	} else if err != nil {
+		return 0, nil, err
+	}
+
+	// the reader is at the row last line, the row starts as many lines before as line breaks the quoted fields have
+	line := c.lines.line
+	for _, field := range rec { line -= strings.Count(field, "\n") }
+
+	if len(rec) <= c.name || len(rec) <= c.items { return line, nil, errors.New("missing columns") }
+
+	items, err := strconv.ParseUint(strings.TrimSpace(rec[c.items]), 10, 32)
+	if err != nil { return line, nil, errors.New("Items must be a positive integer") }
+
+	return line, &dto.BookCreateIn{Name: mapper.FromCsvCell(rec[c.name]), Items: uint(items)}, nil
+}
+
+// lineReader is a reader counting the lines read. Every Read gives one line at most, so the CSV reader (which reads
+// line by line) never reads ahead of the row it parses, and line is the last line of that row
+type lineReader struct {
+	r    *bufio.Reader
+	rest []byte // Rest of the current line, not yet read
+	line int    // Lines read so far
+}
+
+func (l *lineReader) Read(p []byte) (int, error) {
+	if len(l.rest) == 0 {
+		b, err := l.r.ReadBytes('\n')
+		if len(b) == 0 { return 0, err }
+
+		l.rest = b
+		l.line++
+	}
+
+	n := copy(p, l.rest)
+	l.rest = l.rest[n:]
+
+	return n, nil
+}
+
+// jsonlBookRows is the JSON Lines rows reader, see newBookRows. The blank lines are skipped, and the unknown
+// fields are ignored
+type jsonlBookRows struct {
+	sc   *bufio.Scanner
+	line int
+}
+
+func (j *jsonlBookRows) next() (int, *dto.BookCreateIn, error) {
+	for j.sc.Scan() {
+		j.line++
+		if len(bytes.TrimSpace(j.sc.Bytes())) == 0 { continue }
+
+		var bDto dto.BookCreateIn
+		if err := json.Unmarshal(j.sc.Bytes(), &bDto); err != nil { return j.line, nil, err }
+
+		return j.line, &bDto, nil
+	}
+
+	if err := j.sc.Err(); err != nil { return 0, nil, err }
+	return 0, nil, io.EOF
+}
+
+// endregion =============================================================================
\ No newline at end of file
@@ -0,0 +1,323 @@
+package endpoints
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/iris-contrib/httpexpect/v2"
+	"github.com/kataras/iris/v12"
+	"github.com/kataras/iris/v12/httptest"
+	"github.com/kataras/iris/v12/middleware/requestid"
+
+	"go.api.backend/api/middlewares"
+	"go.api.backend/lib"
+	"go.api.backend/repo/mem"
+	"go.api.backend/schema"
+	"go.api.backend/schema/dto"
+	"go.api.backend/schema/models"
+	"go.api.backend/service"
+	"go.api.backend/service/utils"
+)
+
+var testSigKey = []byte("a-testing-jwt-signature-key-32-bytes")
+
+// testKeys is the HS256 keyring of testSigKey
+var testKeys, _ = lib.LoadKeyring(nil, testSigKey, time.Time{})
+
+// problemJSON the media type of the error responses (see utils.SvcResponse.ResErr)
+var problemJSON = httpexpect.ContentOpts{MediaType: "application/problem+json"}
+
+// newTestBookApp create an iris app with the books and audit log endpoints, backed by in memory repositories. The
+// books are seeded with the given ones (in order, so their Id are 1, 2, ...). The reads are public
+func newTestBookApp(t *testing.T, seed ...models.Book) *httptest.Expect {
+	t.Helper()
+
+	return newTestBookAppConf(t, testConf(schema.ReadAccessPublic, schema.ScopeBooksWrite), seed...)
+}
+
+// testConf create a configuration with the given route protection policy settings
+func testConf(readAccess string, writeScopes string) *utils.SvcConfig {
+	svcC := &utils.SvcConfig{}
+	svcC.ReadAccess, svcC.WriteScopes = readAccess, writeScopes
+
+	return svcC
+}
+
+// newTestBookAppConf create the app the same way than newTestBookApp, with the given configuration
+func newTestBookAppConf(t *testing.T, svcC *utils.SvcConfig, seed ...models.Book) *httptest.Expect {
+	t.Helper()
+
+	app := iris.New()
+	app.Validator = validator.New()
+	app.UseRouter(requestid.New())
+
+	repo, audits := mem.NewRepoMemBook(), mem.NewRepoMemAudit()
+	uow := mem.NewUnitOfWork(&repo, &audits)
+	svc, svcAudit := service.NewSvcBooks(&repo, &uow), service.NewSvcAudit(&audits)
+
+	for i := range seed {
+		if err := svc.Create(context.Background(), &seed[i]); err != nil { t.Fatalf("seeding book %q: %v", seed[i].Name, err) }
+	}
+
+	r := utils.NewSvcResponse(svcC)
+	mdwAuthChecker := middlewares.NewAuthCheckerMiddleware(testKeys, nil)
+	policy, err := middlewares.NewRoutePolicy(r, svcC, &mdwAuthChecker)
+	if err != nil { t.Fatal(err) }
+	NewBookHandler(app, &svc, r, &policy)
+	NewAuditHandler(app, &svcAudit, r, &policy)
+
+	return httptest.New(t, app)
+}
+
+// testToken sign an access token with the given role & scopes, for the Authorization header
+func testToken(t *testing.T, rol string, scope ...string) string {
+	t.Helper()
+
+	tk, err := lib.MkAccessToken(&dto.AccessTokenData{Scope: scope, Claims: dto.Claims{Sub: "tester", Rol: rol}}, testKeys, 5)
+	if err != nil { t.Fatalf("signing the test token: %v", err) }
+
+	return "Bearer " + string(tk)
+}
+
+// bookReq is a books endpoint request test case
+type bookReq struct {
+	name        string
+	method      string
+	path        string
+	query       map[string]string
+	auth        string
+	headers     map[string]string
+	body        interface{}
+	rawBody     string
+	contentType string
+	trashed     []uint
+	wantStatus  int
+	wantTitle   string
+	wantETag    string
+	check       func(e *httptest.Expect, res *httpexpect.Response)
+}
+
+// runBookReqs run every request case against a fresh app, seeded with the same books
+func runBookReqs(t *testing.T, seed []models.Book, tests []bookReq) {
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			books := append([]models.Book(nil), seed...)
+			e := newTestBookApp(t, books...)
+
+			for _, id := range tt.trashed {
+				e.DELETE("/books/{id}", id).WithHeader("Authorization", testToken(t, schema.RolUser, schema.ScopeBooksWrite)).
+					Expect().Status(iris.StatusNoContent)
+			}
+
+			req := e.Request(tt.method, tt.path)
+			if tt.auth != "" { req.WithHeader("Authorization", tt.auth) }
+			for k, v := range tt.headers { req.WithHeader(k, v) }
+			for k, v := range tt.query { req.WithQuery(k, v) }
+
+			if tt.body != nil { req.WithJSON(tt.body) }
+			if tt.rawBody != "" { req.WithBytes([]byte(tt.rawBody)).WithHeader("Content-Type", tt.contentType) }
+
+			res := req.Expect().Status(tt.wantStatus)
+
+			if tt.wantTitle != "" { res.JSON(problemJSON).Object().ValueEqual("title", tt.wantTitle) }
+			if tt.wantETag != "" { res.Header("ETag").Equal(tt.wantETag) }
+			if tt.check != nil { tt.check(e, res) }
+		})
+	}
+}
+
+func TestHBook_Read(t *testing.T) {
+	seed := []models.Book{{Name: "Dune", Items: 30}, {Name: "Emma", Items: 10}, {Name: "Hyperion", Items: 20}}
+
+	runBookReqs(t, seed, []bookReq{
+		{name: "get by Id", method: "GET", path: "/books/1", wantStatus: iris.StatusOK, wantETag: `"1"`},
+		{name: "get a missing book", method: "GET", path: "/books/9", wantStatus: iris.StatusNotFound, wantTitle: schema.ErrNotFound},
+		{name: "get a trashed book", method: "GET", path: "/books/1", trashed: []uint{1}, wantStatus: iris.StatusNotFound},
+		{
+			name: "get with a fresh If-None-Match", method: "GET", path: "/books/1", headers: map[string]string{"If-None-Match": `W/"1"`},
+			wantStatus: iris.StatusNotModified,
+		},
+		{
+			name: "get with a stale If-None-Match", method: "GET", path: "/books/1", headers: map[string]string{"If-None-Match": `"7"`},
+			wantStatus: iris.StatusOK,
+		},
+		{name: "list", method: "GET", path: "/books", query: map[string]string{"limit": "2", "sort": "-items"}, wantStatus: iris.StatusOK, check: func(e *httptest.Expect, res *httpexpect.Response) {
+			obj := res.JSON().Object()
+			obj.ValueEqual("Total", 3)
+			obj.Value("Data").Array().Element(0).Object().ValueEqual("Name", "Dune")
+			obj.Value("Data").Array().Element(1).Object().ValueEqual("Name", "Hyperion")
+		}},
+		{name: "list with an invalid sorting", method: "GET", path: "/books", query: map[string]string{"sort": "pages"}, wantStatus: iris.StatusUnprocessableEntity, wantTitle: schema.ErrVal},
+		{name: "list past the last allowed page", method: "GET", path: "/books", query: map[string]string{"page": "1000001"}, wantStatus: iris.StatusUnprocessableEntity, wantTitle: schema.ErrVal},
+		{name: "search past the last allowed page", method: "GET", path: "/books/search", query: map[string]string{"q": "hyp", "page": "1000001"}, wantStatus: iris.StatusUnprocessableEntity, wantTitle: schema.ErrVal},
+		{name: "search", method: "GET", path: "/books/search", query: map[string]string{"q": "hyp"}, wantStatus: iris.StatusOK, check: func(e *httptest.Expect, res *httpexpect.Response) {
+			obj := res.JSON().Object()
+			obj.ValueEqual("Total", 1)
+			obj.Value("Data").Array().Element(0).Object().ValueEqual("Name", "Hyperion").ValueEqual("Snippet", "<mark>Hyperion</mark>")
+		}},
+		{name: "search without query", method: "GET", path: "/books/search", wantStatus: iris.StatusUnprocessableEntity, wantTitle: schema.ErrVal},
+		{name: "list filtered", method: "GET", path: "/books", query: map[string]string{"name": "MM"}, wantStatus: iris.StatusOK, check: func(e *httptest.Expect, res *httpexpect.Response) {
+			res.JSON().Object().ValueEqual("Total", 1)
+		}},
+	})
+}
+
+func TestHBook_Write(t *testing.T) {
+	seed := []models.Book{{Name: "Dune", Items: 30}, {Name: "Emma", Items: 10}}
+	writer := testToken(t, schema.RolUser, schema.ScopeBooksWrite)
+
+	runBookReqs(t, seed, []bookReq{
+		{name: "create without token", method: "POST", path: "/books", body: dto.BookCreateIn{Name: "Ulysses", Items: 3}, wantStatus: iris.StatusUnauthorized},
+		{
+			name: "create without the write scope", method: "POST", path: "/books", auth: testToken(t, schema.RolUser),
+			body: dto.BookCreateIn{Name: "Ulysses", Items: 3}, wantStatus: iris.StatusForbidden, wantTitle: schema.ErrForbidden,
+		},
+		{name: "create", method: "POST", path: "/books", auth: writer, body: dto.BookCreateIn{Name: "Ulysses", Items: 3}, wantStatus: iris.StatusCreated},
+		{
+			name: "create a duplicated name", method: "POST", path: "/books", auth: writer, body: dto.BookCreateIn{Name: "dune", Items: 3},
+			wantStatus: iris.StatusUnprocessableEntity, wantTitle: schema.ErrDuplicateKey,
+		},
+		{
+			name: "create an invalid book", method: "POST", path: "/books", auth: writer, body: dto.BookCreateIn{Name: "D", Items: 3},
+			wantStatus: iris.StatusUnprocessableEntity, wantTitle: schema.ErrVal,
+		},
+		{
+			name: "update", method: "PUT", path: "/books/1", auth: writer, body: dto.BookCreateIn{Name: "Dune Messiah", Items: 4},
+			wantStatus: iris.StatusOK, wantETag: `"2"`,
+		},
+		{
+			name: "update with a stale If-Match", method: "PUT", path: "/books/1", auth: writer, headers: map[string]string{"If-Match": `"5"`},
+			body: dto.BookCreateIn{Name: "Dune Messiah", Items: 4}, wantStatus: iris.StatusPreconditionFailed, wantTitle: schema.ErrPrecondition,
+		},
+		{
+			name: "update a missing book", method: "PUT", path: "/books/9", auth: writer, body: dto.BookCreateIn{Name: "Dune Messiah", Items: 4},
+			wantStatus: iris.StatusNotFound,
+		},
+		{
+			name: "merge patch", method: "PATCH", path: "/books/2", auth: writer, rawBody: `{"Items": 11}`,
+			contentType: lib.MediaMergePatch, wantStatus: iris.StatusOK, wantETag: `"2"`,
+			check: func(e *httptest.Expect, res *httpexpect.Response) {
+				e.GET("/books/2").Expect().JSON().Object().ValueEqual("Items", 11).ValueEqual("Name", "Emma")
+			},
+		},
+		{
+			name: "json patch", method: "PATCH", path: "/books/2", auth: writer, rawBody: `[{"op": "replace", "path": "/Name", "value": "Emma II"}]`,
+			contentType: lib.MediaJsonPatch, wantStatus: iris.StatusOK,
+		},
+		{
+			name: "patch with an unsupported media type", method: "PATCH", path: "/books/2", auth: writer, rawBody: `{"Items": 11}`,
+			contentType: "application/json", wantStatus: iris.StatusUnsupportedMediaType,
+		},
+		{
+			name: "patch making the book invalid", method: "PATCH", path: "/books/2", auth: writer, rawBody: `{"Items": 500}`,
+			contentType: lib.MediaMergePatch, wantStatus: iris.StatusUnprocessableEntity, wantTitle: schema.ErrVal,
+		},
+		{name: "delete", method: "DELETE", path: "/books/1", auth: writer, wantStatus: iris.StatusNoContent},
+		{name: "delete a missing book", method: "DELETE", path: "/books/9", auth: writer, wantStatus: iris.StatusNotFound},
+		{
+			name: "delete with a matching If-Match", method: "DELETE", path: "/books/1", auth: writer, headers: map[string]string{"If-Match": `"1"`},
+			wantStatus: iris.StatusNoContent,
+		},
+	})
+}
+
+func TestHBook_Trash(t *testing.T) {
+	seed := []models.Book{{Name: "Dune", Items: 30}, {Name: "Emma", Items: 10}}
+	writer := testToken(t, schema.RolUser, schema.ScopeBooksWrite)
+	admin := testToken(t, schema.RolAdmin, schema.ScopeBooksWrite)
+
+	runBookReqs(t, seed, []bookReq{
+		{name: "list the trash", method: "GET", path: "/books/trash", auth: writer, trashed: []uint{1}, wantStatus: iris.StatusOK, check: func(e *httptest.Expect, res *httpexpect.Response) {
+			res.JSON().Object().ValueEqual("Total", 1)
+		}},
+		{name: "restore", method: "POST", path: "/books/1/restore", auth: writer, trashed: []uint{1}, wantStatus: iris.StatusOK},
+		{name: "restore a book not in the trash", method: "POST", path: "/books/2/restore", auth: writer, wantStatus: iris.StatusNotFound},
+		{name: "purge without the admin role", method: "DELETE", path: "/books/trash/1", auth: writer, trashed: []uint{1}, wantStatus: iris.StatusForbidden},
+		{name: "purge", method: "DELETE", path: "/books/trash/1", auth: admin, trashed: []uint{1}, wantStatus: iris.StatusNoContent},
+		{name: "purge the trash", method: "DELETE", path: "/books/trash", auth: admin, trashed: []uint{1, 2}, wantStatus: iris.StatusNoContent},
+	})
+}
+
+func TestHBook_Batch(t *testing.T) {
+	seed := []models.Book{{Name: "Dune", Items: 30}}
+	writer := testToken(t, schema.RolUser, schema.ScopeBooksWrite)
+
+	items := []dto.BookCreateIn{{Name: "Emma", Items: 1}, {Name: "DUNE", Items: 2}, {Name: "x", Items: 3}}
+
+	runBookReqs(t, seed, []bookReq{
+		{
+			name: "best-effort create", method: "POST", path: "/books/batch", auth: writer,
+			body: dto.BookBatchCreateIn{Items: items}, wantStatus: iris.StatusOK,
+			check: func(e *httptest.Expect, res *httpexpect.Response) {
+				e.GET("/books").Expect().JSON().Object().ValueEqual("Total", 2)
+			},
+		},
+		{
+			name: "atomic create", method: "POST", path: "/books/batch", auth: writer,
+			body: dto.BookBatchCreateIn{Atomic: true, Items: items}, wantStatus: iris.StatusUnprocessableEntity,
+			check: func(e *httptest.Expect, res *httpexpect.Response) {
+				e.GET("/books").Expect().JSON().Object().ValueEqual("Total", 1)
+			},
+		},
+		{
+			name: "best-effort delete", method: "DELETE", path: "/books/batch", auth: writer,
+			body: dto.BookBatchDeleteIn{Ids: []uint{1, 9}}, wantStatus: iris.StatusOK,
+		},
+	})
+}
+
+func TestHBook_Policy(t *testing.T) {
+	seed := []models.Book{{Name: "Dune", Items: 30}}
+	reader, writer := testToken(t, schema.RolUser), testToken(t, schema.RolUser, "catalog:write")
+
+	tests := []struct {
+		name       string
+		readAccess string
+		method     string
+		path       string
+		auth       string
+		wantStatus int
+	}{
+		{name: "public read without a token", readAccess: schema.ReadAccessPublic, method: "GET", path: "/books/1", wantStatus: iris.StatusOK},
+		{name: "protected read without a token", readAccess: schema.ReadAccessToken, method: "GET", path: "/books/1", wantStatus: iris.StatusUnauthorized},
+		{name: "protected listing without a token", readAccess: schema.ReadAccessToken, method: "GET", path: "/books", wantStatus: iris.StatusUnauthorized},
+		{name: "protected export without a token", readAccess: schema.ReadAccessToken, method: "GET", path: "/books/export", wantStatus: iris.StatusUnauthorized},
+		{name: "protected read with a token", readAccess: schema.ReadAccessToken, method: "GET", path: "/books/1", auth: reader, wantStatus: iris.StatusOK},
+		{name: "write without a token", readAccess: schema.ReadAccessPublic, method: "DELETE", path: "/books/1", wantStatus: iris.StatusUnauthorized},
+		{name: "write without the configured scope", readAccess: schema.ReadAccessPublic, method: "DELETE", path: "/books/1", auth: reader, wantStatus: iris.StatusForbidden},
+		{name: "write with the configured scope", readAccess: schema.ReadAccessPublic, method: "DELETE", path: "/books/1", auth: writer, wantStatus: iris.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			books := append([]models.Book(nil), seed...)
+			e := newTestBookAppConf(t, testConf(tt.readAccess, "catalog:write"), books...)
+
+			req := e.Request(tt.method, tt.path)
+			if tt.auth != "" { req.WithHeader("Authorization", tt.auth) }
+
+			req.Expect().Status(tt.wantStatus)
+		})
+	}
+}
+
+// The export can be imported back, the formula-like names are escaped, and the import reports the rows by line
+func TestHBook_Files(t *testing.T) {
+	e := newTestBookApp(t, models.Book{Name: "Dune", Items: 30}, models.Book{Name: "=1+2", Items: 10})
+	writer := testToken(t, schema.RolUser, schema.ScopeBooksWrite)
+
+	export := e.GET("/books/export").Expect().Status(iris.StatusOK).Body()
+	export.Contains("\n2,'=1+2,10,")
+
+	e.POST("/books/import").WithHeader("Authorization", writer).WithMultipart().WithFileBytes("file", "books.csv", []byte(export.Raw())).
+		Expect().Status(iris.StatusOK).JSON().Object().ValueEqual("Updated", 2).ValueEqual("Created", 0).ValueEqual("Failed", 0)
+
+	csvFile := "Name,Items\n\"Moby\nDick\",3\n\nBad,x\nEmma,-1\n"
+	report := e.POST("/books/import").WithHeader("Authorization", writer).WithMultipart().WithFileBytes("file", "books.csv", []byte(csvFile)).
+		Expect().Status(iris.StatusOK).JSON().Object()
+	report.ValueEqual("Created", 1).ValueEqual("Failed", 2)
+	report.Value("Errors").Array().Element(0).Object().ValueEqual("Row", 5)
+	report.Value("Errors").Array().Element(1).Object().ValueEqual("Row", 6)
+}
@@ -0,0 +1,72 @@
+package endpoints
+
+import (
+	"github.com/go-pg/pg/v10"
+	"github.com/kataras/iris/v12"
+
+	"go.api.backend/service"
+	"go.api.backend/service/auth"
+	"go.api.backend/service/utils"
+)
+
+type HHealth struct {
+	response *utils.SvcResponse
+	service *service.SvcHealth
+}
+
+// NewHealthHandler create and register the health handlers for the orchestrators probes: /healthz (liveness)
+// and /readyz (readiness).
+//
+// - app [*iris.Application] ~ Iris App instance
+//
+// - dbCtx [*pg.DB] ~ Postgres database instance
+//
+// - svcR [*utils.SvcResponse] ~ Response service instance
+//
+// - svcC [utils.SvcConfig] ~ Configuration service instance
+//
+// - svcA [*auth.SvcAuthentication] ~ Authentication service instance
+func NewHealthHandler(app *iris.Application, dbCtx *pg.DB, svcR *utils.SvcResponse, svcC *utils.SvcConfig, svcA *auth.SvcAuthentication) HHealth {
+
+	// --- VARS SETUP ---
+	healthService := service.NewSvcHealth(dbCtx, svcC, svcA)
+	h := HHealth{svcR, &healthService}
+
+	// --- REGISTERING ENDPOINTS ---
+	app.Get("/healthz", h.liveness)
+	app.Get("/readyz", h.readiness)
+
+	return h
+}
+
+// region ======== ENDPOINT HANDLERS =====================================================
+
+// liveness tells that the process is alive
+// @Summary Liveness probe
+// @Description Tells that the process is alive. It doesn't check any dependency
+// @Tags Health
+// @Produce json
+// @Success 200 {object} dto.HealthOut "OK"
+// @Router /healthz [get]
+func (h HHealth) liveness(ctx iris.Context) {
+	(*h.response).ResOKWithData((*h.service).Liveness(), &ctx)
+}
+
+// readiness tells if the app is ready to serve requests
+// @Summary Readiness probe
+// @Description Check the database, the migrations state and the auth providers reachability, reporting each one. The app is ready only if the critical dependencies (database & migrations) are up
+// @Tags Health
+// @Produce json
+// @Success 200 {object} dto.HealthOut "Ready"
+// @Failure 503 {object} dto.HealthOut "Not ready"
+// @Router /readyz [get]
+func (h HHealth) readiness(ctx iris.Context) {
+	report := (*h.service).Readiness(ctx.Request().Context())
+
+	if report.Status == "up" {
+		(*h.response).ResOKWithData(report, &ctx)
+	} else {
+		(*h.response).ResWithDataStatus(iris.StatusServiceUnavailable, report, &ctx)
+	}
+}
+// endregion =============================================================================
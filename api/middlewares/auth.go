@@ -1,21 +1,79 @@
 package middlewares
 
 import (
+	"github.com/kataras/iris/v12"
 	"github.com/kataras/iris/v12/context"
 	"github.com/kataras/iris/v12/middleware/jwt"
+
+	"go.api.backend/lib"
 	"go.api.backend/schema/dto"
 )
 
-// Bearer Authentication token verification middleware
-func NewAuthCheckerMiddleware(sigKey []byte) context.Handler {
+// The request context keys of the verified access token, see Claims
+const (
+	claimsCtxKey        = "app.auth.claims"
+	verifiedTokenCtxKey = "app.auth.token"
+)
+
+// Bearer Authentication token verification middleware. The token is verified with the keyring key of its kid
+// header, so the signing keys can be rotated. Its claims are available through Claims, and the logout (ctx.Logout)
+// blocks it
+//
+// - keys [*lib.Keyring] ~ Access tokens keyring
+//
+// - blocklist [jwt.Blocklist] ~ Server-side blocked tokens storage (see auth.NewBlocklist)
+func NewAuthCheckerMiddleware(keys *lib.Keyring, blocklist jwt.Blocklist) context.Handler {
+
+	extractors := []jwt.TokenExtractor{jwt.FromHeader, jwt.FromQuery}
+	var validators []jwt.TokenValidator
+	if blocklist != nil { validators = append(validators, blocklist) }		// Enable server-side token block feature (even before its expiration time):
+
+	return func(ctx *context.Context) {
+		var token string
+		for _, extract := range extractors {
+			if token = extract(ctx); token != "" { break }
+		}
+
+		verified, err := keys.Verify([]byte(token), validators...)
+		if err != nil {
+			ctx.StopWithError(iris.StatusUnauthorized, context.PrivateError(err))
+			return
+		}
 
-	checker := jwt.NewVerifier(jwt.HS256, sigKey)
-	checker.WithDefaultBlocklist()							// Enable server-side token block feature (even before its expiration time):
-	// checker.WithDecryption()
+		claims := new(dto.AccessTokenData)
+		if err := verified.Claims(claims); err != nil {
+			ctx.StopWithError(iris.StatusUnauthorized, context.PrivateError(err))
+			return
+		}
 
-	return checker.Verify(func() interface{} {
-		// We can add login here
+		_ = ctx.SetUser(claims)
+		ctx.Values().Set(claimsCtxKey, claims)
+		ctx.Values().Set(verifiedTokenCtxKey, verified)
+		if blocklist != nil { ctx.SetLogoutFunc(invalidate(blocklist)) }
+
+		ctx.Next()
+	}
+}
+
+// invalidate create the logout function, blocking the request token until its expiration
+func invalidate(blocklist jwt.Blocklist) func(ctx *context.Context) {
+	return func(ctx *context.Context) {
+		verified, _ := ctx.Values().Get(verifiedTokenCtxKey).(*jwt.VerifiedToken)
+		if verified == nil { return }
+
+		blocklist.InvalidateToken(verified.Token, verified.StandardClaims)
+		ctx.Values().Remove(claimsCtxKey)
+		ctx.Values().Remove(verifiedTokenCtxKey)
+		_ = ctx.SetUser(nil)
+		ctx.SetLogoutFunc(nil)
+	}
+}
 
-		return new(dto.AccessTokenData)
-	})
+// Claims get the access token claims of the request, verified by the auth checker middleware (see
+// NewAuthCheckerMiddleware). Nil if there is no verified token
+//
+// - ctx [*context.Context] ~ Iris request context
+func Claims(ctx *context.Context) *dto.AccessTokenData {
+	claims, _ := ctx.Values().Get(claimsCtxKey).(*dto.AccessTokenData)
+	return claims
 }
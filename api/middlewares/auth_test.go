@@ -0,0 +1,37 @@
+package middlewares
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kataras/iris/v12"
+	"github.com/kataras/iris/v12/httptest"
+	kjwt "github.com/kataras/jwt"
+
+	"go.api.backend/lib"
+	"go.api.backend/schema/dto"
+)
+
+// The claims are available with Claims, and the logout blocks the token
+func TestNewAuthCheckerMiddleware(t *testing.T) {
+	keys, _ := lib.LoadKeyring(nil, []byte("a-testing-jwt-signature-key-32-bytes"), time.Time{})
+	other, _ := lib.LoadKeyring(nil, []byte("another-testing-jwt-signature-key"), time.Time{})
+
+	app := iris.New()
+	app.Use(NewAuthCheckerMiddleware(keys, kjwt.NewBlocklist(time.Minute)))
+	app.Get("/me", func(ctx iris.Context) { ctx.WriteString(Claims(ctx).Claims.Sub) })
+	app.Get("/logout", func(ctx iris.Context) {
+		if err := ctx.Logout(); err != nil { ctx.StopWithError(iris.StatusInternalServerError, err) }
+	})
+	e := httptest.New(t, app)
+
+	tk, _ := lib.MkAccessToken(&dto.AccessTokenData{Claims: dto.Claims{Sub: "alice"}}, keys, 5)
+	forged, _ := lib.MkAccessToken(&dto.AccessTokenData{Claims: dto.Claims{Sub: "mallory"}}, other, 5)
+
+	e.GET("/me").Expect().Status(iris.StatusUnauthorized)
+	e.GET("/me").WithHeader("Authorization", "Bearer " + string(forged)).Expect().Status(iris.StatusUnauthorized)
+	e.GET("/me").WithHeader("Authorization", "Bearer " + string(tk)).Expect().Status(iris.StatusOK).Body().Equal("alice")
+
+	e.GET("/logout").WithHeader("Authorization", "Bearer " + string(tk)).Expect().Status(iris.StatusOK)
+	e.GET("/me").WithHeader("Authorization", "Bearer " + string(tk)).Expect().Status(iris.StatusUnauthorized)
+}
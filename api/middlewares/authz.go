@@ -0,0 +1,82 @@
+package middlewares
+
+import (
+	"strings"
+
+	"github.com/kataras/iris/v12"
+	"github.com/kataras/iris/v12/context"
+
+	"go.api.backend/schema"
+	"go.api.backend/service/utils"
+)
+
+// NewAuthzMiddleware create an authorization middleware, checking the access token claims against the route
+// requirements. It must be registered after the auth checker middleware (see NewAuthCheckerMiddleware).
+// If the token has no claims then 401, if the requirements are not met then 403 (err.forbidden).
+// E.g.
+//
+//  router.Post("/", MdwAuthChecker, middlewares.NewScopeGuardMiddleware(svcR, "books:write"), h.createBook)
+//
+// - svcR [*utils.SvcResponse] ~ Response service instance
+//
+// - scopes [[]string] ~ Required scopes, the token must have all of them. Empty for no scope requirement
+//
+// - roles [[]string] ~ Allowed roles, the token must have at least one of them. Empty for no role requirement
+func NewAuthzMiddleware(svcR *utils.SvcResponse, scopes []string, roles []string) context.Handler {
+
+	return func(ctx iris.Context) {
+		claims := Claims(ctx)
+		if claims == nil {
+			svcR.ResErr(iris.StatusUnauthorized, schema.ErrUnauthorized, schema.ErrDetNoClaims, &ctx)
+			return
+		}
+
+		if !hasAll(claims.Scope, scopes) || (len(roles) > 0 && !hasAny(strings.Fields(claims.Claims.Rol), roles)) {
+			svcR.ResErr(iris.StatusForbidden, schema.ErrForbidden, schema.ErrDetForbidden, &ctx)
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+// NewScopeGuardMiddleware create an authorization middleware requiring all the given scopes. See NewAuthzMiddleware
+//
+// - svcR [*utils.SvcResponse] ~ Response service instance
+//
+// - scopes [...string] ~ Required scopes
+func NewScopeGuardMiddleware(svcR *utils.SvcResponse, scopes ...string) context.Handler {
+	return NewAuthzMiddleware(svcR, scopes, nil)
+}
+
+// NewRoleGuardMiddleware create an authorization middleware requiring any of the given roles. See NewAuthzMiddleware
+//
+// - svcR [*utils.SvcResponse] ~ Response service instance
+//
+// - roles [...string] ~ Allowed roles
+func NewRoleGuardMiddleware(svcR *utils.SvcResponse, roles ...string) context.Handler {
+	return NewAuthzMiddleware(svcR, nil, roles)
+}
+
+// region ======== HELPERS ===============================================================
+
+// hasAll tells if all the required values are in the granted ones
+func hasAll(granted []string, required []string) bool {
+	for _, r := range required {
+		if !hasAny(granted, []string{r}) { return false }
+	}
+
+	return true
+}
+
+// hasAny tells if any of the allowed values is in the granted ones
+func hasAny(granted []string, allowed []string) bool {
+	for _, g := range granted {
+		for _, a := range allowed {
+			if g == a { return true }
+		}
+	}
+
+	return false
+}
+// endregion =============================================================================
@@ -0,0 +1,70 @@
+package middlewares
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/kataras/iris/v12/context"
+
+	"go.api.backend/schema"
+	"go.api.backend/service/utils"
+)
+
+// RoutePolicy is the route protection policy, it builds the handlers chain of every route according to its kind
+// (read, write or just authenticated). It's set up from the configuration, see the ReadAccess & WriteScopes settings.
+// E.g.
+//
+//  router.Get("/", policy.Read(h.getBooks)...)
+//  router.Post("/", policy.Write(h.createBook)...)
+//  router.Delete("/trash", policy.Authed(NewRoleGuardMiddleware(svcR, "admin"), h.purgeTrash)...)
+type RoutePolicy struct {
+	PublicReads bool     `bool:"The reads don't require an access token"`
+	WriteScopes []string `[]string:"Scopes required by the writes, besides a valid access token"`
+
+	mdwAuthChecker context.Handler
+	mdwWriteGuard  context.Handler
+}
+
+// NewRoutePolicy create the route protection policy from the configuration. The WriteScopes setting must have a
+// scope at least, otherwise the writes would only require a valid access token, so it fails on a blank one
+//
+// - svcR [*utils.SvcResponse] ~ Response service instance
+//
+// - svcC [*utils.SvcConfig] ~ Configuration service instance
+//
+// - MdwAuthChecker [*context.Handler] ~ Authentication checker middleware (see NewAuthCheckerMiddleware)
+func NewRoutePolicy(svcR *utils.SvcResponse, svcC *utils.SvcConfig, MdwAuthChecker *context.Handler) (RoutePolicy, error) {
+	scopes := strings.Fields(svcC.WriteScopes)
+	if len(scopes) == 0 { return RoutePolicy{}, errors.New("route policy: the WriteScopes setting has no scope") }
+
+	return RoutePolicy{
+		PublicReads:    svcC.ReadAccess == schema.ReadAccessPublic,
+		WriteScopes:    scopes,
+		mdwAuthChecker: *MdwAuthChecker,
+		mdwWriteGuard:  NewScopeGuardMiddleware(svcR, scopes...),
+	}, nil
+}
+
+// Read get the handlers chain of a read route: the given handlers, behind the auth checker unless the reads are public
+//
+// - h [...context.Handler] ~ Route handlers, the last one is the endpoint handler
+func (p RoutePolicy) Read(h ...context.Handler) []context.Handler {
+	if p.PublicReads { return h }
+
+	return p.Authed(h...)
+}
+
+// Write get the handlers chain of a write route: the given handlers, behind the auth checker and the write scopes guard
+//
+// - h [...context.Handler] ~ Route handlers, the last one is the endpoint handler
+func (p RoutePolicy) Write(h ...context.Handler) []context.Handler {
+	return p.Authed(append([]context.Handler{p.mdwWriteGuard}, h...)...)
+}
+
+// Authed get the handlers chain of a route requiring just a valid access token: the given handlers, behind the auth
+// checker. Further requirements (e.g. a role guard) go in the given handlers
+//
+// - h [...context.Handler] ~ Route handlers, the last one is the endpoint handler
+func (p RoutePolicy) Authed(h ...context.Handler) []context.Handler {
+	return append([]context.Handler{p.mdwAuthChecker}, h...)
+}
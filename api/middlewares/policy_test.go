@@ -0,0 +1,38 @@
+package middlewares
+
+import (
+	"testing"
+
+	"github.com/kataras/iris/v12/context"
+
+	"go.api.backend/schema"
+	"go.api.backend/service/utils"
+)
+
+func TestNewRoutePolicy(t *testing.T) {
+	tests := []struct {
+		name        string
+		writeScopes string
+		wantScopes  int // Amount of write scopes, -1 for an error
+	}{
+		{name: "one scope", writeScopes: "books:write", wantScopes: 1},
+		{name: "many scopes", writeScopes: " books:write  books:admin ", wantScopes: 2},
+		{name: "empty", writeScopes: "", wantScopes: -1},
+		{name: "whitespace only", writeScopes: " \t ", wantScopes: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svcC := &utils.SvcConfig{}
+			svcC.ReadAccess, svcC.WriteScopes = schema.ReadAccessPublic, tt.writeScopes
+			checker := context.Handler(func(ctx *context.Context) { ctx.Next() })
+
+			policy, err := NewRoutePolicy(utils.NewSvcResponse(svcC), svcC, &checker)
+			if tt.wantScopes < 0 {
+				if err == nil { t.Fatalf("policy = %+v, want an error", policy) }
+				return
+			}
+			if err != nil || len(policy.WriteScopes) != tt.wantScopes { t.Fatalf("policy = %+v, %v, want %d scopes", policy, err, tt.wantScopes) }
+		})
+	}
+}
@@ -28,21 +28,41 @@ var doc = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/auth/protected": {
+        "/.well-known/jwks.json": {
+            "get": {
+                "description": "JSON Web Key Set (RFC 7517) of the keys verifying the access tokens, by key id (kid header of the tokens). The signing key goes first, the rest are the previous keys still verifying the tokens issued before a rotation. Empty while the tokens are signed with the HS256 shared key, which is secret",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Access tokens verification keys",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.JWKSOut"
+                        }
+                    }
+                }
+            }
+        },
+        "/audit": {
             "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "This is a Bearer Token protected sample endpoint",
+                "description": "Get a page of audit log entries, newest first. Every entry tells who (access token subject) did what (action) to which entity, the entity before \u0026 after, and the request ID. It requires the admin role",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Auth"
+                    "Audit"
                 ],
-                "summary": "Sample protected endpoint",
+                "summary": "Get the audit log",
                 "parameters": [
                     {
                         "type": "string",
@@ -51,13 +71,59 @@ var doc = `{
                         "name": "Authorization",
                         "in": "header",
                         "required": true
+                    },
+                    {
+                        "enum": [
+                            "book"
+                        ],
+                        "type": "string",
+                        "description": "Entity type",
+                        "name": "entity",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "format": "uint32",
+                        "description": "Entity Id, all the entities if none",
+                        "name": "id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "format": "uint32",
+                        "description": "Page number, 1000000 at most",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "format": "uint32",
+                        "description": "Page size, 100 at most",
+                        "name": "limit",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK",
+                        "description": "Page of audit entries",
                         "schema": {
-                            "$ref": "#/definitions/dto.AccessTokenData"
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/dto.PageOut"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/models.AuditEntry"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     },
                     "401": {
@@ -66,8 +132,20 @@ var doc = `{
                             "$ref": "#/definitions/dto.ApiError"
                         }
                     },
+                    "403": {
+                        "description": "err.forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "422": {
+                        "description": "err.invalid_data",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
                     "500": {
-                        "description": "err.generic",
+                        "description": "err.repo_ops",
                         "schema": {
                             "$ref": "#/definitions/dto.ApiError"
                         }
@@ -75,33 +153,33 @@ var doc = `{
                 }
             }
         },
-        "/auth/sisec": {
-            "post": {
-                "description": "Try to make the authentication of the user credentials through the SISEC auth provider service",
-                "consumes": [
-                    "multipart/form-data"
+        "/auth/logout": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
                 ],
+                "description": "This endpoint invalidated a previously granted access token, and revokes all the user's refresh tokens (every session must login again)",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "Auth"
                 ],
-                "summary": "Auth the user credential through SISEC",
                 "parameters": [
                     {
-                        "description": "User Login Credential",
-                        "name": "credential",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/dto.UserCredIn"
-                        }
+                        "type": "string",
+                        "default": "Bearer \u003cAdd access token here\u003e",
+                        "description": "Insert access token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
                     }
                 ],
                 "responses": {
-                    "202": {
-                        "description": "Accepted"
+                    "204": {
+                        "description": "OK"
                     },
                     "401": {
                         "description": "err.unauthorized",
@@ -110,13 +188,7 @@ var doc = `{
                         }
                     },
                     "500": {
-                        "description": "err.json_parse",
-                        "schema": {
-                            "$ref": "#/definitions/dto.ApiError"
-                        }
-                    },
-                    "504": {
-                        "description": "err.network",
+                        "description": "err.generic",
                         "schema": {
                             "$ref": "#/definitions/dto.ApiError"
                         }
@@ -124,36 +196,14 @@ var doc = `{
                 }
             }
         },
-        "/books": {
-            "get": {
-                "description": "Get the books in the repository",
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "Books"
-                ],
-                "summary": "Get Books",
-                "responses": {
-                    "200": {
-                        "description": "List of Books",
-                        "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/models.Book"
-                            }
-                        }
-                    },
-                    "500": {
-                        "description": "err.repo_ops",
-                        "schema": {
-                            "$ref": "#/definitions/dto.ApiError"
-                        }
+        "/auth/password": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
                     }
-                }
-            },
-            "post": {
-                "description": "Create a new book from the passed schema",
+                ],
+                "description": "Change the password of the logged user of the default (database) auth provider. All the user's refresh tokens are revoked",
                 "consumes": [
                     "application/json"
                 ],
@@ -161,35 +211,52 @@ var doc = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Books"
+                    "Auth"
                 ],
-                "summary": "Create a new book",
+                "summary": "Change the password",
                 "parameters": [
                     {
-                        "description": "Book Data",
-                        "name": "book",
+                        "type": "string",
+                        "default": "Bearer \u003cAdd access token here\u003e",
+                        "description": "Insert access token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Old and new passwords",
+                        "name": "passwords",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/dto.BookCreateIn"
+                            "$ref": "#/definitions/dto.PasswordChangeIn"
                         }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "OK",
+                    "204": {
+                        "description": "OK"
+                    },
+                    "401": {
+                        "description": "err.unauthorized",
                         "schema": {
-                            "$ref": "#/definitions/models.Book"
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "404": {
+                        "description": "err.not_found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
                         }
                     },
                     "422": {
-                        "description": "err.duplicate_key || Invalid schema",
+                        "description": "err.invalid_data",
                         "schema": {
                             "$ref": "#/definitions/dto.ApiError"
                         }
                     },
                     "500": {
-                        "description": "err.repo_ops || Internal error",
+                        "description": "err.repo_ops",
                         "schema": {
                             "$ref": "#/definitions/dto.ApiError"
                         }
@@ -197,9 +264,9 @@ var doc = `{
                 }
             }
         },
-        "/books/{id}": {
-            "get": {
-                "description": "Get a book through its Id",
+        "/auth/password/forgot": {
+            "post": {
+                "description": "Request a password reset token for an user of the default (database) auth provider. The response is the same whether the user exist or not. Only in debug mode the token is retrieved in the response",
                 "consumes": [
                     "application/json"
                 ],
@@ -207,42 +274,42 @@ var doc = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Books"
+                    "Auth"
                 ],
-                "summary": "Get book by Id",
+                "summary": "Request a password reset",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "format": "uint32",
-                        "description": "Requested Book Id",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Username",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.PasswordForgotIn"
+                        }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "$ref": "#/definitions/models.Book"
-                        }
+                    "202": {
+                        "description": "Accepted"
                     },
-                    "404": {
-                        "description": "err.not_found",
+                    "422": {
+                        "description": "err.invalid_data",
                         "schema": {
                             "$ref": "#/definitions/dto.ApiError"
                         }
                     },
                     "500": {
-                        "description": "Internal error",
+                        "description": "err.repo_ops",
                         "schema": {
                             "$ref": "#/definitions/dto.ApiError"
                         }
                     }
                 }
-            },
-            "put": {
-                "description": "Update the book having the specified Id with the schema passed in the request body",
+            }
+        },
+        "/auth/password/reset": {
+            "post": {
+                "description": "Set a new password for an user of the default (database) auth provider, using a previously requested password reset token. All the user's refresh tokens are revoked",
                 "consumes": [
                     "application/json"
                 ],
@@ -250,193 +317,2314 @@ var doc = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Books"
+                    "Auth"
                 ],
-                "summary": "Update the indicated book",
+                "summary": "Reset the password",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "format": "uint32",
-                        "description": "Book ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "description": "Book Data",
-                        "name": "book",
+                        "description": "Reset data",
+                        "name": "reset",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/dto.BookUpdateIn"
+                            "$ref": "#/definitions/dto.PasswordResetIn"
                         }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "$ref": "#/definitions/models.Book"
-                        }
+                    "204": {
+                        "description": "OK"
                     },
-                    "404": {
-                        "description": "err.not_found",
+                    "401": {
+                        "description": "err.unauthorized",
                         "schema": {
                             "$ref": "#/definitions/dto.ApiError"
                         }
                     },
                     "422": {
-                        "description": "err.duplicate_key || Invalid schema",
+                        "description": "err.invalid_data",
                         "schema": {
                             "$ref": "#/definitions/dto.ApiError"
                         }
                     },
                     "500": {
-                        "description": "err.repo_ops || Internal error",
+                        "description": "err.repo_ops",
                         "schema": {
                             "$ref": "#/definitions/dto.ApiError"
                         }
                     }
                 }
-            },
-            "delete": {
-                "description": "Deletes a Book by its Id",
-                "consumes": [
-                    "application/json"
+            }
+        },
+        "/auth/protected": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
                 ],
+                "description": "This is a Bearer Token protected sample endpoint",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Books"
+                    "Auth"
                 ],
-                "summary": "Delete a Book",
+                "summary": "Sample protected endpoint",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "format": "uint32",
-                        "description": "Book ID",
-                        "name": "id",
-                        "in": "path",
+                        "type": "string",
+                        "default": "Bearer \u003cAdd access token here\u003e",
+                        "description": "Insert access token",
+                        "name": "Authorization",
+                        "in": "header",
                         "required": true
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "No Content"
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.AccessTokenData"
+                        }
                     },
-                    "404": {
-                        "description": "err.not_found",
+                    "401": {
+                        "description": "err.unauthorized",
                         "schema": {
                             "$ref": "#/definitions/dto.ApiError"
                         }
                     },
                     "500": {
-                        "description": "err.repo_ops",
+                        "description": "err.generic",
                         "schema": {
                             "$ref": "#/definitions/dto.ApiError"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "dto.AccessTokenData": {
-            "type": "object",
-            "properties": {
-                "claims": {
-                    "$ref": "#/definitions/dto.Claims"
-                },
-                "scope": {
-                    "type": "array",
-                    "items": {
-                        "type": "string"
-                    }
+        },
+        "/auth/providers": {
+            "get": {
+                "description": "List the enabled auth providers, in the configured order, for the login UIs. The \"password\" flow providers take the user credentials POSTed to their LoginUrl, the \"redirect\" flow ones need the browser sent to their LoginUrl",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "List the auth providers",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/dto.AuthProviderOut"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/refresh": {
+            "post": {
+                "description": "Rotate a refresh token, granting a new access \u0026 refresh tokens pair with the user's current roles \u0026 scopes (default provider users). The refresh tokens are single use, reusing one revokes all the tokens descending from the same login, and they all expire RefreshTkMaxAge hours after the login",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Refresh the access token",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Refresh token",
+                        "name": "refresh_token",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/dto.TokenOut"
+                        }
+                    },
+                    "401": {
+                        "description": "err.unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "500": {
+                        "description": "err.jwt_generation",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/register": {
+            "post": {
+                "description": "Register a new user for the default (database) auth provider. The user can login through /auth/default",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Register a new user",
+                "parameters": [
+                    {
+                        "description": "User Data",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.UserRegisterIn"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.User"
+                        }
+                    },
+                    "422": {
+                        "description": "err.duplicate_key || err.invalid_data",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "500": {
+                        "description": "err.repo_ops",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/users/{username}/scopes": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Replace the scopes of an user of the default (database) auth provider, e.g. granting books:write (the self-registered users have none). The user gets them on its next login. It requires the admin role",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Set the scopes of an user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "Bearer \u003cAdd access token here\u003e",
+                        "description": "Insert access token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Username",
+                        "name": "username",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Granted scopes, empty for revoking them all",
+                        "name": "scopes",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.UserScopesIn"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "OK"
+                    },
+                    "401": {
+                        "description": "err.unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "403": {
+                        "description": "err.forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "404": {
+                        "description": "err.not_found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "422": {
+                        "description": "err.invalid_data",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "500": {
+                        "description": "err.repo_ops",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/{provider}": {
+            "post": {
+                "description": "Intent to grant authentication using the provider user's credentials and the specified  auth provider",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Auth the user credential through a provider",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Auth provider name",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "User Login Credential",
+                        "name": "credential",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.UserCredIn"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/dto.TokenOut"
+                        }
+                    },
+                    "400": {
+                        "description": "err.wrong_auth_provider",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "401": {
+                        "description": "err.unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "500": {
+                        "description": "err.json_parse | err.wrong_type_assertion",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "502": {
+                        "description": "err.http_res_err | err.upstream_config",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "503": {
+                        "description": "err.upstream_unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "504": {
+                        "description": "err.network",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/{provider}/callback": {
+            "get": {
+                "description": "Complete a login started at /auth/{provider}/login, exchanging the authorization code for the user identity (a verified OpenID Connect ID token). The provider redirects the user here",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Login callback of the provider page",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Auth provider name",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Authorization code",
+                        "name": "code",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Login state",
+                        "name": "state",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Login error, e.g. access_denied",
+                        "name": "error",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Login error description",
+                        "name": "error_description",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/dto.TokenOut"
+                        }
+                    },
+                    "400": {
+                        "description": "err.wrong_auth_provider",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "401": {
+                        "description": "err.unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "500": {
+                        "description": "err.json_parse | err.jwt_generation",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "502": {
+                        "description": "err.http_res_err | err.upstream_config",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "503": {
+                        "description": "err.upstream_unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "504": {
+                        "description": "err.network",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/{provider}/login": {
+            "get": {
+                "description": "Redirect the user to the provider login page (OAuth2 authorization code flow with PKCE, e.g. OpenID Connect). Once logged in, the provider redirects the user back to /auth/{provider}/callback. The login must be completed within 10 minutes",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Login through the provider page",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Auth provider name",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "302": {
+                        "description": "Found",
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "Provider login page url"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "err.wrong_auth_provider",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "500": {
+                        "description": "err.json_parse | err.generic",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "502": {
+                        "description": "err.http_res_err | err.upstream_config",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "503": {
+                        "description": "err.upstream_unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "504": {
+                        "description": "err.network",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    }
+                }
+            }
+        },
+        "/books": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get a page of books in the repository. It supports offset (page) or keyset (after / before an Id) pagination, filtering and sorting by any book column. Public unless the ReadAccess conf is token, then it requires an access token",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Books"
+                ],
+                "summary": "Get Books",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "format": "uint32",
+                        "description": "Page number, for offset pagination, 1000000 at most",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "format": "uint32",
+                        "description": "Page size, 100 at most",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "format": "uint32",
+                        "description": "Keyset cursor, books placed after this Id",
+                        "name": "after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "format": "uint32",
+                        "description": "Keyset cursor, books placed before this Id",
+                        "name": "before",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated columns, '-' prefix for descending order. E.g. -items,name",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Case-insensitive name substring",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "format": "uint32",
+                        "description": "Minimum amount of items",
+                        "name": "items_min",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "format": "uint32",
+                        "description": "Maximum amount of items",
+                        "name": "items_max",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Page of Books",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/dto.PageOut"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/models.Book"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "401": {
+                        "description": "err.unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "422": {
+                        "description": "err.invalid_data",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "500": {
+                        "description": "err.repo_ops",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Create a new book from the passed schema. It requires the write scopes (WriteScopes conf, books:write by default)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Books"
+                ],
+                "summary": "Create a new book",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "Bearer \u003cAdd access token here\u003e",
+                        "description": "Insert access token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Book Data",
+                        "name": "book",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.BookCreateIn"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Book"
+                        }
+                    },
+                    "401": {
+                        "description": "err.unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "403": {
+                        "description": "err.forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "422": {
+                        "description": "err.duplicate_key || Invalid schema",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "500": {
+                        "description": "err.repo_ops || Internal error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    }
+                }
+            }
+        },
+        "/books/batch": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Update many books in one transaction, see the batch creation. It requires the write scopes (WriteScopes conf, books:write by default)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Books"
+                ],
+                "summary": "Update books in batch",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "Bearer \u003cAdd access token here\u003e",
+                        "description": "Insert access token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Books Data, 1000 at most",
+                        "name": "batch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.BookBatchUpdateIn"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Outcome per book",
+                        "schema": {
+                            "$ref": "#/definitions/dto.BatchOut"
+                        }
+                    },
+                    "401": {
+                        "description": "err.unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "403": {
+                        "description": "err.forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "422": {
+                        "description": "Atomic batch rolled back || err.invalid_data",
+                        "schema": {
+                            "$ref": "#/definitions/dto.BatchOut"
+                        }
+                    },
+                    "500": {
+                        "description": "err.repo_ops",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Create many books in one transaction. Every item is validated on its own and gets an outcome (created, duplicate, invalid...). If atomic then any failed item rolls back the whole batch (422), otherwise the rest of the items are kept. It requires the write scopes (WriteScopes conf, books:write by default)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Books"
+                ],
+                "summary": "Create books in batch",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "Bearer \u003cAdd access token here\u003e",
+                        "description": "Insert access token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Books Data, 1000 at most",
+                        "name": "batch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.BookBatchCreateIn"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Outcome per book",
+                        "schema": {
+                            "$ref": "#/definitions/dto.BatchOut"
+                        }
+                    },
+                    "401": {
+                        "description": "err.unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "403": {
+                        "description": "err.forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "422": {
+                        "description": "Atomic batch rolled back || err.invalid_data",
+                        "schema": {
+                            "$ref": "#/definitions/dto.BatchOut"
+                        }
+                    },
+                    "500": {
+                        "description": "err.repo_ops",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Delete (move to the trash) many books in one transaction, see the batch creation. It requires the write scopes (WriteScopes conf, books:write by default)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Books"
+                ],
+                "summary": "Delete books in batch",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "Bearer \u003cAdd access token here\u003e",
+                        "description": "Insert access token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Books Ids, 1000 at most",
+                        "name": "batch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.BookBatchDeleteIn"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Outcome per book",
+                        "schema": {
+                            "$ref": "#/definitions/dto.BatchOut"
+                        }
+                    },
+                    "401": {
+                        "description": "err.unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "403": {
+                        "description": "err.forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "422": {
+                        "description": "Atomic batch rolled back || err.invalid_data",
+                        "schema": {
+                            "$ref": "#/definitions/dto.BatchOut"
+                        }
+                    },
+                    "500": {
+                        "description": "err.repo_ops",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    }
+                }
+            }
+        },
+        "/books/export": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Download all the books (excluding the trash) as a CSV or JSON Lines file. The books are streamed, so the catalog size doesn't matter. Public unless the ReadAccess conf is token, then it requires an access token",
+                "produces": [
+                    "text/csv",
+                    "application/x-ndjson"
+                ],
+                "tags": [
+                    "Books"
+                ],
+                "summary": "Export the books",
+                "parameters": [
+                    {
+                        "enum": [
+                            "csv",
+                            "jsonl"
+                        ],
+                        "type": "string",
+                        "description": "File format, csv (default) or jsonl",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Books file",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "401": {
+                        "description": "err.unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "422": {
+                        "description": "err.invalid_data",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "500": {
+                        "description": "err.repo_ops",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    }
+                }
+            }
+        },
+        "/books/import": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Upload a CSV or JSON Lines file with books, they are created or, if there is already a book with the same name, updated (the items). Every row is validated like a new book, the CSV header must have the Name and Items columns (the rest are ignored, so an export can be imported back). The failed rows are reported. It requires the write scopes (WriteScopes conf, books:write by default)",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Books"
+                ],
+                "summary": "Import books",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "Bearer \u003cAdd access token here\u003e",
+                        "description": "Insert access token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "file",
+                        "description": "CSV or JSON Lines file",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "csv",
+                            "jsonl"
+                        ],
+                        "type": "string",
+                        "description": "File format, csv or jsonl. Taken from the file extension if omitted",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Import report",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ImportOut"
+                        }
+                    },
+                    "401": {
+                        "description": "err.unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "403": {
+                        "description": "err.forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "422": {
+                        "description": "err.invalid_data",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "500": {
+                        "description": "err.repo_ops",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    }
+                }
+            }
+        },
+        "/books/search": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Full-text search over the books (the trash excluded). Every word of the query is matched as a prefix (e.g. \"hob\" matches \"Hobbit\") and all of them must match. The hits come most relevant first, with a snippet having the matches between \u003cmark\u003e tags. Public unless the ReadAccess conf is token, then it requires an access token",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Books"
+                ],
+                "summary": "Search Books",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Search query, e.g. the hobb",
+                        "name": "q",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "format": "uint32",
+                        "description": "Page number, 1000000 at most",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "format": "uint32",
+                        "description": "Page size, 100 at most",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Page of hits",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/dto.PageOut"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/models.BookHit"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "401": {
+                        "description": "err.unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "422": {
+                        "description": "err.invalid_data",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "500": {
+                        "description": "err.repo_ops",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    }
+                }
+            }
+        },
+        "/books/trash": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get a page of deleted books, they can be restored until they are purged. It supports the same pagination, filtering and sorting than the books listing. It requires the write scopes (WriteScopes conf, books:write by default)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Books"
+                ],
+                "summary": "Get the trash",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "Bearer \u003cAdd access token here\u003e",
+                        "description": "Insert access token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "format": "uint32",
+                        "description": "Page number, for offset pagination, 1000000 at most",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "format": "uint32",
+                        "description": "Page size, 100 at most",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "format": "uint32",
+                        "description": "Keyset cursor, books placed after this Id",
+                        "name": "after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "format": "uint32",
+                        "description": "Keyset cursor, books placed before this Id",
+                        "name": "before",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated columns, '-' prefix for descending order. E.g. -items,name",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Case-insensitive name substring",
+                        "name": "name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "format": "uint32",
+                        "description": "Minimum amount of items",
+                        "name": "items_min",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "format": "uint32",
+                        "description": "Maximum amount of items",
+                        "name": "items_max",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Page of deleted Books",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/dto.PageOut"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/models.Book"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "401": {
+                        "description": "err.unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "403": {
+                        "description": "err.forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "422": {
+                        "description": "err.invalid_data",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "500": {
+                        "description": "err.repo_ops",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Delete for good all the books in the trash, they can't be restored anymore. It requires the admin role",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Books"
+                ],
+                "summary": "Empty the trash",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "Bearer \u003cAdd access token here\u003e",
+                        "description": "Insert access token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "err.unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "403": {
+                        "description": "err.forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "500": {
+                        "description": "err.repo_ops",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    }
+                }
+            }
+        },
+        "/books/trash/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Delete for good a book in the trash, it can't be restored anymore. It requires the admin role",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Books"
+                ],
+                "summary": "Purge a deleted book",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "Bearer \u003cAdd access token here\u003e",
+                        "description": "Insert access token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "format": "uint32",
+                        "description": "Book ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "err.unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "403": {
+                        "description": "err.forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "404": {
+                        "description": "err.not_found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "500": {
+                        "description": "err.repo_ops",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    }
+                }
+            }
+        },
+        "/books/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Get a book through its Id. The response carries the book ETag (its version), a matching If-None-Match gives a 304. Public unless the ReadAccess conf is token, then it requires an access token",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Books"
+                ],
+                "summary": "Get book by Id",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "format": "uint32",
+                        "description": "Requested Book Id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Book ETag known by the client",
+                        "name": "If-None-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Book"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Book version"
+                            }
+                        }
+                    },
+                    "304": {
+                        "description": "Not Modified"
+                    },
+                    "401": {
+                        "description": "err.unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "404": {
+                        "description": "err.not_found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Update the book having the specified Id with the schema passed in the request body. It requires the write scopes (WriteScopes conf, books:write by default)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Books"
+                ],
+                "summary": "Update the indicated book",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "Bearer \u003cAdd access token here\u003e",
+                        "description": "Insert access token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "format": "uint32",
+                        "description": "Book ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Book ETag, the book is updated only if it wasn't modified meanwhile",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Book Data",
+                        "name": "book",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.BookUpdateIn"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Book"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "New book version"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "err.unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "403": {
+                        "description": "err.forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "404": {
+                        "description": "err.not_found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "412": {
+                        "description": "err.precondition_failed",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "422": {
+                        "description": "err.duplicate_key || Invalid schema",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "500": {
+                        "description": "err.repo_ops || Internal error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Deletes a Book by its Id, moving it to the trash (it can be restored until it is purged). It requires the write scopes (WriteScopes conf, books:write by default)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Books"
+                ],
+                "summary": "Delete a Book",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "Bearer \u003cAdd access token here\u003e",
+                        "description": "Insert access token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "format": "uint32",
+                        "description": "Book ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Book ETag, the book is deleted only if it wasn't modified meanwhile",
+                        "name": "If-Match",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "err.unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "403": {
+                        "description": "err.forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "404": {
+                        "description": "err.not_found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "412": {
+                        "description": "err.precondition_failed",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "500": {
+                        "description": "err.repo_ops",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Partially update a book with a JSON Merge Patch (application/merge-patch+json) or a JSON Patch (application/json-patch+json) over its editable fields, e.g. {\"Items\": 12} or [{\"op\": \"replace\", \"path\": \"/Items\", \"value\": 12}]. Only the changed columns are written. It requires the write scopes (WriteScopes conf, books:write by default)",
+                "consumes": [
+                    "application/merge-patch+json",
+                    "application/json-patch+json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Books"
+                ],
+                "summary": "Patch the indicated book",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "Bearer \u003cAdd access token here\u003e",
+                        "description": "Insert access token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "format": "uint32",
+                        "description": "Book ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Book ETag, the book is patched only if it wasn't modified meanwhile",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Patch document",
+                        "name": "patch",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.BookCreateIn"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Book"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "New book version"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "err.unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "403": {
+                        "description": "err.forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "404": {
+                        "description": "err.not_found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "412": {
+                        "description": "err.precondition_failed",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "415": {
+                        "description": "err.unsupported_media_type",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "422": {
+                        "description": "err.duplicate_key || err.invalid_data",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "500": {
+                        "description": "err.repo_ops || Internal error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    }
+                }
+            }
+        },
+        "/books/{id}/restore": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Take a book out of the trash. It fails if its name was taken meanwhile by another book. It requires the write scopes (WriteScopes conf, books:write by default)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Books"
+                ],
+                "summary": "Restore a deleted book",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "Bearer \u003cAdd access token here\u003e",
+                        "description": "Insert access token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "format": "uint32",
+                        "description": "Book ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Book"
+                        }
+                    },
+                    "401": {
+                        "description": "err.unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "403": {
+                        "description": "err.forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "404": {
+                        "description": "err.not_found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "422": {
+                        "description": "err.duplicate_key",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    },
+                    "500": {
+                        "description": "err.repo_ops",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ApiError"
+                        }
+                    }
+                }
+            }
+        },
+        "/healthz": {
+            "get": {
+                "description": "Tells that the process is alive. It doesn't check any dependency",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Health"
+                ],
+                "summary": "Liveness probe",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.HealthOut"
+                        }
+                    }
+                }
+            }
+        },
+        "/readyz": {
+            "get": {
+                "description": "Check the database, the migrations state and the auth providers reachability, reporting each one. The app is ready only if the critical dependencies (database \u0026 migrations) are up",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Health"
+                ],
+                "summary": "Readiness probe",
+                "responses": {
+                    "200": {
+                        "description": "Ready",
+                        "schema": {
+                            "$ref": "#/definitions/dto.HealthOut"
+                        }
+                    },
+                    "503": {
+                        "description": "Not ready",
+                        "schema": {
+                            "$ref": "#/definitions/dto.HealthOut"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "dto.AccessTokenData": {
+            "type": "object",
+            "properties": {
+                "claims": {
+                    "$ref": "#/definitions/dto.Claims"
+                },
+                "scope": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "dto.ApiError": {
+            "type": "object",
+            "properties": {
+                "detail": {
+                    "type": "string",
+                    "example": "Some error details"
+                },
+                "status": {
+                    "type": "integer",
+                    "example": 503
+                },
+                "title": {
+                    "type": "string",
+                    "example": "err_code"
+                }
+            }
+        },
+        "dto.AuthProviderOut": {
+            "type": "object",
+            "properties": {
+                "flow": {
+                    "description": "password (credentials POSTed to the LoginUrl) | redirect (browser sent to the LoginUrl)",
+                    "type": "string",
+                    "example": "password"
+                },
+                "label": {
+                    "type": "string",
+                    "example": "SISEC"
+                },
+                "loginUrl": {
+                    "type": "string",
+                    "example": "/auth/sisec"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "sisec"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "sisec"
+                }
+            }
+        },
+        "dto.BatchItemOut": {
+            "type": "object",
+            "properties": {
+                "detail": {
+                    "type": "string",
+                    "example": "a unique resource field is duplicated"
+                },
+                "error": {
+                    "type": "string",
+                    "example": "err.duplicate_key"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 24
+                },
+                "index": {
+                    "description": "Item position in the request",
+                    "type": "integer",
+                    "example": 0
+                },
+                "status": {
+                    "description": "created | updated | deleted | duplicate | invalid | not_found | failed | rolled_back",
+                    "type": "string",
+                    "example": "created"
+                }
+            }
+        },
+        "dto.BatchOut": {
+            "type": "object",
+            "properties": {
+                "applied": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.BatchItemOut"
+                    }
+                }
+            }
+        },
+        "dto.BookBatchCreateIn": {
+            "type": "object",
+            "required": [
+                "items"
+            ],
+            "properties": {
+                "atomic": {
+                    "description": "All-or-nothing, otherwise best-effort",
+                    "type": "boolean",
+                    "example": true
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.BookCreateIn"
+                    }
+                }
+            }
+        },
+        "dto.BookBatchDeleteIn": {
+            "type": "object",
+            "required": [
+                "ids"
+            ],
+            "properties": {
+                "atomic": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    },
+                    "example": [
+                        24,
+                        25
+                    ]
+                }
+            }
+        },
+        "dto.BookBatchUpdateIn": {
+            "type": "object",
+            "required": [
+                "items"
+            ],
+            "properties": {
+                "atomic": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.BookUpdateIn"
+                    }
+                }
+            }
+        },
+        "dto.BookCreateIn": {
+            "type": "object",
+            "required": [
+                "items",
+                "name"
+            ],
+            "properties": {
+                "items": {
+                    "type": "integer",
+                    "example": 46
+                },
+                "name": {
+                    "type": "string",
+                    "example": "The Book of Eli"
+                }
+            }
+        },
+        "dto.BookUpdateIn": {
+            "type": "object",
+            "required": [
+                "items",
+                "name"
+            ],
+            "properties": {
+                "id": {
+                    "type": "integer",
+                    "example": 24
+                },
+                "items": {
+                    "type": "integer",
+                    "example": 46
+                },
+                "name": {
+                    "type": "string",
+                    "example": "The Book of Eli"
+                }
+            }
+        },
+        "dto.Claims": {
+            "type": "object",
+            "properties": {
+                "rol": {
+                    "type": "string"
+                },
+                "sub": {
+                    "type": "string"
                 }
             }
         },
-        "dto.ApiError": {
+        "dto.HealthCheckOut": {
             "type": "object",
             "properties": {
+                "critical": {
+                    "description": "A critical dependency down makes the app not ready",
+                    "type": "boolean",
+                    "example": true
+                },
                 "detail": {
                     "type": "string",
-                    "example": "Some error details"
+                    "example": "3 pending migrations"
+                },
+                "latency": {
+                    "type": "string",
+                    "example": "1.2ms"
+                },
+                "status": {
+                    "description": "up | down",
+                    "type": "string",
+                    "example": "up"
+                }
+            }
+        },
+        "dto.HealthOut": {
+            "type": "object",
+            "properties": {
+                "checks": {
+                    "description": "Report per dependency, only for the readiness",
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/dto.HealthCheckOut"
+                    }
                 },
                 "status": {
+                    "description": "up | down",
+                    "type": "string",
+                    "example": "up"
+                }
+            }
+        },
+        "dto.ImportOut": {
+            "type": "object",
+            "properties": {
+                "created": {
                     "type": "integer",
-                    "example": 503
+                    "example": 120
                 },
-                "title": {
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.ImportRowOut"
+                    }
+                },
+                "failed": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "updated": {
+                    "type": "integer",
+                    "example": 8
+                }
+            }
+        },
+        "dto.ImportRowOut": {
+            "type": "object",
+            "properties": {
+                "detail": {
                     "type": "string",
-                    "example": "err_code"
+                    "example": "Key: 'BookCreateIn.Name' Error:Field validation for 'Name' failed on the 'gte' tag"
+                },
+                "error": {
+                    "type": "string",
+                    "example": "err.invalid_data"
+                },
+                "row": {
+                    "description": "Line of the row in the file, 1-based (the CSV header is the line 1)",
+                    "type": "integer",
+                    "example": 14
                 }
             }
         },
-        "dto.BookCreateIn": {
+        "dto.JWK": {
+            "type": "object",
+            "properties": {
+                "alg": {
+                    "type": "string",
+                    "example": "RS256"
+                },
+                "crv": {
+                    "description": "EC / OKP curve",
+                    "type": "string"
+                },
+                "e": {
+                    "description": "RSA exponent",
+                    "type": "string"
+                },
+                "kid": {
+                    "type": "string",
+                    "example": "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"
+                },
+                "kty": {
+                    "type": "string",
+                    "example": "RSA"
+                },
+                "n": {
+                    "description": "RSA modulus",
+                    "type": "string"
+                },
+                "use": {
+                    "type": "string",
+                    "example": "sig"
+                },
+                "x": {
+                    "description": "EC / OKP x coordinate",
+                    "type": "string"
+                },
+                "y": {
+                    "description": "EC y coordinate",
+                    "type": "string"
+                }
+            }
+        },
+        "dto.JWKSOut": {
+            "type": "object",
+            "properties": {
+                "keys": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.JWK"
+                    }
+                }
+            }
+        },
+        "dto.PageOut": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "object"
+                },
+                "limit": {
+                    "type": "integer",
+                    "example": 20
+                },
+                "next": {
+                    "type": "string",
+                    "example": "/books?limit=20\u0026page=3"
+                },
+                "page": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "prev": {
+                    "type": "string",
+                    "example": "/books?limit=20\u0026page=1"
+                },
+                "total": {
+                    "type": "integer",
+                    "example": 120
+                }
+            }
+        },
+        "dto.PasswordChangeIn": {
             "type": "object",
             "required": [
-                "items",
-                "name"
+                "newPassword",
+                "oldPassword"
             ],
             "properties": {
-                "items": {
-                    "type": "integer",
-                    "example": 46
+                "newPassword": {
+                    "type": "string",
+                    "example": "my.new.secret.pass"
                 },
-                "name": {
+                "oldPassword": {
                     "type": "string",
-                    "example": "The Book of Eli"
+                    "example": "my.secret.pass"
                 }
             }
         },
-        "dto.BookUpdateIn": {
+        "dto.PasswordForgotIn": {
             "type": "object",
             "required": [
-                "items",
-                "name"
+                "username"
             ],
             "properties": {
-                "id": {
-                    "type": "integer",
-                    "example": 24
+                "username": {
+                    "type": "string",
+                    "example": "mynickname"
+                }
+            }
+        },
+        "dto.PasswordResetIn": {
+            "type": "object",
+            "required": [
+                "newPassword",
+                "token",
+                "username"
+            ],
+            "properties": {
+                "newPassword": {
+                    "type": "string",
+                    "example": "my.new.secret.pass"
                 },
-                "items": {
-                    "type": "integer",
-                    "example": 46
+                "token": {
+                    "type": "string",
+                    "example": "3q2-7wEAbgnYCLf8fDSVYA3TW4A4PSAk"
                 },
-                "name": {
+                "username": {
                     "type": "string",
-                    "example": "The Book of Eli"
+                    "example": "mynickname"
                 }
             }
         },
-        "dto.Claims": {
+        "dto.TokenOut": {
             "type": "object",
             "properties": {
-                "rol": {
-                    "type": "string"
+                "accessToken": {
+                    "type": "string",
+                    "example": "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."
                 },
-                "sub": {
-                    "type": "string"
+                "expiresIn": {
+                    "description": "Access token lifetime, in seconds",
+                    "type": "integer",
+                    "example": 1500
+                },
+                "refreshToken": {
+                    "type": "string",
+                    "example": "3q2-7wEAbgnYCLf8fDSVYA3TW4A4PSAkr1Gk8nvSDQ0"
+                },
+                "tokenType": {
+                    "type": "string",
+                    "example": "Bearer"
                 }
             }
         },
         "dto.UserCredIn": {
             "type": "object",
             "required": [
+                "domain",
                 "password",
-                "scope",
                 "username"
             ],
             "properties": {
+                "domain": {
+                    "type": "string",
+                    "example": "web"
+                },
                 "password": {
                     "type": "string",
                     "example": "secret"
                 },
-                "scope": {
+                "username": {
                     "type": "string",
-                    "example": "web"
+                    "example": "mynickname"
+                }
+            }
+        },
+        "dto.UserRegisterIn": {
+            "type": "object",
+            "required": [
+                "password",
+                "username"
+            ],
+            "properties": {
+                "password": {
+                    "type": "string",
+                    "example": "my.secret.pass"
                 },
                 "username": {
                     "type": "string",
@@ -444,6 +2632,62 @@ var doc = `{
                 }
             }
         },
+        "dto.UserScopesIn": {
+            "type": "object",
+            "properties": {
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "books:write"
+                    ]
+                }
+            }
+        },
+        "models.AuditEntry": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "description": "create, update, delete, restore or purge",
+                    "type": "string",
+                    "example": "update"
+                },
+                "actor": {
+                    "description": "Access token subject (Sub claim), or \"system\"",
+                    "type": "string",
+                    "example": "mynickname"
+                },
+                "after": {
+                    "type": "object"
+                },
+                "before": {
+                    "type": "object"
+                },
+                "createdAt": {
+                    "type": "string",
+                    "example": "2021-03-12T02:11:03.292442-05:00"
+                },
+                "entity": {
+                    "type": "string",
+                    "example": "book"
+                },
+                "entityId": {
+                    "type": "integer",
+                    "example": 24
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 1024
+                },
+                "requestId": {
+                    "description": "X-Request-ID of the request doing the mutation",
+                    "type": "string",
+                    "example": "5b7e3a4c-53e4-4a5b-b1b8-6f9c8a7f2d1e"
+                }
+            }
+        },
         "models.Book": {
             "type": "object",
             "properties": {
@@ -451,6 +2695,44 @@ var doc = `{
                     "type": "string",
                     "example": "2021-03-12T02:11:03.292442-05:00"
                 },
+                "deletedAt": {
+                    "type": "string",
+                    "example": "0001-01-01T00:00:00Z"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 24
+                },
+                "items": {
+                    "type": "integer",
+                    "example": 46
+                },
+                "name": {
+                    "type": "string",
+                    "example": "The Book of Eli"
+                },
+                "updatedAt": {
+                    "type": "string",
+                    "example": "0001-01-01T00:00:00Z"
+                },
+                "version": {
+                    "description": "Incremented on every update, it's the book ETag",
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "models.BookHit": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string",
+                    "example": "2021-03-12T02:11:03.292442-05:00"
+                },
+                "deletedAt": {
+                    "type": "string",
+                    "example": "0001-01-01T00:00:00Z"
+                },
                 "id": {
                     "type": "integer",
                     "example": 24
@@ -463,12 +2745,71 @@ var doc = `{
                     "type": "string",
                     "example": "The Book of Eli"
                 },
+                "rank": {
+                    "type": "number",
+                    "example": 0.0607927
+                },
+                "snippet": {
+                    "type": "string",
+                    "example": "The \u003cmark\u003eBook\u003c/mark\u003e of Eli"
+                },
+                "updatedAt": {
+                    "type": "string",
+                    "example": "0001-01-01T00:00:00Z"
+                },
+                "version": {
+                    "description": "Incremented on every update, it's the book ETag",
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "models.User": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string",
+                    "example": "2021-03-12T02:11:03.292442-05:00"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "roles": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "user"
+                    ]
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "books:write"
+                    ]
+                },
                 "updatedAt": {
                     "type": "string",
                     "example": "0001-01-01T00:00:00Z"
+                },
+                "username": {
+                    "type": "string",
+                    "example": "mynickname"
                 }
             }
         }
+    },
+    "securityDefinitions": {
+        "ApiKeyAuth": {
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
+        }
     }
 }`
 
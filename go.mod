@@ -5,16 +5,21 @@ go 1.15
 require (
 	github.com/alecthomas/template v0.0.0-20190718012654-fb15b899a751
 	github.com/cpuguy83/go-md2man/v2 v2.0.0 // indirect
+	github.com/evanphx/json-patch v4.11.0+incompatible
 	github.com/go-openapi/spec v0.20.3 // indirect
 	github.com/go-pg/pg/v10 v10.8.0
 	github.com/go-playground/validator/v10 v10.4.1
+	github.com/iris-contrib/httpexpect/v2 v2.0.5
 	github.com/iris-contrib/swagger/v12 v12.2.0-alpha
 	github.com/json-iterator/go v1.1.10
+	github.com/kataras/golog v0.1.7
 	github.com/kataras/iris/v12 v12.2.0-alpha2.0.20210304161013-7272c76847eb
+	github.com/kataras/jwt v0.1.2
 	github.com/lib/pq v1.10.0
 	github.com/rubenv/sql-migrate v0.0.0-20210215143335-f84234893558
 	github.com/swaggo/swag v1.7.0
 	github.com/tkanos/gonfig v0.0.0-20210106201359-53e13348de2f
+	golang.org/x/crypto v0.0.0-20210220033148-5ea612d1eb83
 	golang.org/x/net v0.0.0-20210226172049-e18ecbb05110 // indirect
 	golang.org/x/sys v0.0.0-20210305230114-8fe3ee5dd75b // indirect
 	golang.org/x/tools v0.1.0 // indirect
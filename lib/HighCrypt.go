@@ -1,18 +1,56 @@
 package lib
 
 import (
-	"github.com/kataras/iris/v12/middleware/jwt"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"golang.org/x/crypto/bcrypt"
 	"time"
 
 	"go.api.backend/schema/dto"
 )
 
-// MkAccessToken create a signed JTW token with the specified data
-func MkAccessToken(data *dto.AccessTokenData, sigKey []byte, TkAge uint8) ([]byte, error) {
+// MkAccessToken create a signed JTW token with the specified data, with the keyring signing key
+//
+// - data [*dto.AccessTokenData] ~ Data to be tokenized
+//
+// - keys [*Keyring] ~ Access tokens keyring
+//
+// - TkAge [uint8] ~ Token lifetime, in minutes
+func MkAccessToken(data *dto.AccessTokenData, keys *Keyring, TkAge uint8) ([]byte, error) {
 
 	// https://github.com/kataras/iris/blob/master/_examples/auth/jwt/middleware/main.go | https://github.com/iris-contrib/examples/blob/master/auth/jwt/basic/main.go
-	tk, err := jwt.Sign(jwt.HS256, sigKey, data, jwt.MaxAge(time.Duration(TkAge)*time.Minute))
+	tk, err := keys.Sign(data, time.Duration(TkAge)*time.Minute)
 	if err != nil { return nil, err }
 
 	return tk, err
 }
+
+// MkPasswordHash create a bcrypt hash of the given password, ready to be stored
+func MkPasswordHash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil { return "", err }
+
+	return string(hash), nil
+}
+
+// CheckPasswordHash tells if the password match the given bcrypt hash
+func CheckPasswordHash(hash string, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// MkRandomToken create an opaque, url safe and cryptographically secure random token of n bytes
+func MkRandomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil { return "", err }
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// MkTokenHash create the SHA-256 (hex) hash of an opaque token. It's used for storing the tokens, so a database
+// leak doesn't compromise them. Being random tokens with high entropy, there is no need for a slow hash.
+func MkTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
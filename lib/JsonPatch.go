@@ -0,0 +1,39 @@
+package lib
+
+import (
+	"errors"
+
+	"github.com/evanphx/json-patch"
+)
+
+// Patch documents media types
+const (
+	MediaMergePatch = "application/merge-patch+json" // JSON Merge Patch, https://tools.ietf.org/html/rfc7396
+	MediaJsonPatch  = "application/json-patch+json"  // JSON Patch, https://tools.ietf.org/html/rfc6902
+)
+
+// ErrPatchMediaType is retrieved when the patch media type isn't one of the supported
+var ErrPatchMediaType = errors.New("unsupported patch media type, use " + MediaMergePatch + " or " + MediaJsonPatch)
+
+// ApplyPatch apply a patch document to a JSON document, according to the patch media type. It returns the patched
+// document, or an error if the patch is malformed, it can't be applied (e.g. a failed JSON Patch test operation) or
+// if its media type isn't supported (ErrPatchMediaType)
+//
+// - doc [[]byte] ~ JSON document to be patched
+//
+// - patch [[]byte] ~ Patch document
+//
+// - mediaType [string] ~ Patch media type, MediaMergePatch or MediaJsonPatch
+func ApplyPatch(doc []byte, patch []byte, mediaType string) ([]byte, error) {
+	switch mediaType {
+	case MediaMergePatch:
+		return jsonpatch.MergePatch(doc, patch)
+	case MediaJsonPatch:
+		p, err := jsonpatch.DecodePatch(patch)
+		if err != nil { return nil, err }
+
+		return p.Apply(doc)
+	}
+
+	return nil, ErrPatchMediaType
+}
@@ -0,0 +1,148 @@
+package lib
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"math/big"
+
+	"github.com/kataras/jwt"
+
+	"go.api.backend/schema/dto"
+)
+
+// jwkCurves the supported EC curves, with their signing algorithm
+var jwkCurves = map[string]struct {
+	curve elliptic.Curve
+	alg   jwt.Alg
+}{"P-256": {elliptic.P256(), jwt.ES256}, "P-384": {elliptic.P384(), jwt.ES384}, "P-521": {elliptic.P521(), jwt.ES512}}
+
+// jwkAlgs the supported signing algorithms, by their JWA name
+var jwkAlgs = map[string]jwt.Alg{
+	"RS256": jwt.RS256, "RS384": jwt.RS384, "RS512": jwt.RS512, "PS256": jwt.PS256, "PS384": jwt.PS384, "PS512": jwt.PS512,
+	"ES256": jwt.ES256, "ES384": jwt.ES384, "ES512": jwt.ES512, "EdDSA": jwt.EdDSA,
+}
+
+// ParseJWK get the public key of a JSON Web Key and its signing algorithm: the declared one (alg member), or the key
+// type default (RS256 for RSA, the curve one for EC, EdDSA for Ed25519). Only the RSA, EC & OKP (Ed25519) keys are
+// supported
+//
+// - k [*dto.JWK] ~ JSON Web Key
+func ParseJWK(k *dto.JWK) (jwt.Alg, jwt.PublicKey, error) {
+	var alg jwt.Alg
+	var pub jwt.PublicKey
+
+	switch k.Kty {
+	case "RSA":
+		n, err := b64Int(k.N)
+		if err != nil { return nil, nil, err }
+		e, err := b64Int(k.E)
+		if err != nil || !e.IsInt64() || e.Int64() > 1 << 31 - 1 { return nil, nil, errors.New("jwk: invalid RSA exponent") }
+
+		alg, pub = jwt.RS256, &rsa.PublicKey{N: n, E: int(e.Int64())}
+	case "EC":
+		c, ok := jwkCurves[k.Crv]
+		if !ok { return nil, nil, errors.New("jwk: unsupported EC curve " + k.Crv) }
+		x, err := b64Int(k.X)
+		if err != nil { return nil, nil, err }
+		y, err := b64Int(k.Y)
+		if err != nil { return nil, nil, err }
+		if !c.curve.IsOnCurve(x, y) { return nil, nil, errors.New("jwk: the EC point isn't on the " + k.Crv + " curve") }
+
+		alg, pub = c.alg, &ecdsa.PublicKey{Curve: c.curve, X: x, Y: y}
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || k.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize { return nil, nil, errors.New("jwk: invalid or unsupported OKP key") }
+
+		alg, pub = jwt.EdDSA, ed25519.PublicKey(x)
+	default:
+		return nil, nil, errors.New("jwk: unsupported key type " + k.Kty)
+	}
+
+	if k.Alg != "" {
+		a, ok := jwkAlgs[k.Alg]
+		if !ok { return nil, nil, errors.New("jwk: unsupported algorithm " + k.Alg) }
+		alg = a
+	}
+
+	return alg, pub, nil
+}
+
+// KeysFromJWKS get the verification keys of a JSON Web Key Set, by key id. The encryption keys (use enc), the keys
+// without id and the unsupported ones are skipped, a set without any usable key is an error
+//
+// - set [*dto.JWKSIn] ~ JSON Web Key Set
+func KeysFromJWKS(set *dto.JWKSIn) (jwt.Keys, error) {
+	keys := jwt.Keys{}
+
+	for i := range set.Keys {
+		k := &set.Keys[i]
+		if k.Kid == "" || (k.Use != "" && k.Use != "sig") { continue }
+
+		alg, pub, err := ParseJWK(k)
+		if err != nil { continue }
+		keys.Register(alg, k.Kid, pub, nil)
+	}
+
+	if len(keys) == 0 { return nil, errors.New("jwk: the key set has no usable signing keys") }
+
+	return keys, nil
+}
+
+// ToJWK get the JSON Web Key of a public key, for publishing it in a JWKS. It's the ParseJWK reverse
+//
+// - kid [string] ~ Key id
+//
+// - alg [jwt.Alg] ~ Signing algorithm of the key
+//
+// - pub [jwt.PublicKey] ~ RSA, EC or Ed25519 public key
+func ToJWK(kid string, alg jwt.Alg, pub jwt.PublicKey) (*dto.JWK, error) {
+	b64 := base64.RawURLEncoding.EncodeToString
+	k := &dto.JWK{Kid: kid, Use: "sig", Alg: alg.Name()}
+
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		k.Kty, k.N, k.E = "RSA", b64(pub.N.Bytes()), b64(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8				// the coordinates are fixed size (RFC 7518 6.2.1.2)
+		x, y := make([]byte, size), make([]byte, size)
+		k.Kty, k.Crv, k.X, k.Y = "EC", pub.Curve.Params().Name, b64(pub.X.FillBytes(x)), b64(pub.Y.FillBytes(y))
+	case ed25519.PublicKey:
+		k.Kty, k.Crv, k.X = "OKP", "Ed25519", b64(pub)
+	default:
+		return nil, errors.New("jwk: unsupported public key type")
+	}
+
+	return k, nil
+}
+
+// Thumbprint get the RFC 7638 thumbprint (SHA-256, base64url) of a JSON Web Key, a stable key id
+//
+// - k [*dto.JWK] ~ JSON Web Key
+func Thumbprint(k *dto.JWK) string {
+	// the required members only, in lexicographic order and without whitespaces
+	var members string
+	switch k.Kty {
+	case "RSA":
+		members = `{"e":"` + k.E + `","kty":"RSA","n":"` + k.N + `"}`
+	case "EC":
+		members = `{"crv":"` + k.Crv + `","kty":"EC","x":"` + k.X + `","y":"` + k.Y + `"}`
+	default:
+		members = `{"crv":"` + k.Crv + `","kty":"` + k.Kty + `","x":"` + k.X + `"}`
+	}
+
+	sum := sha256.Sum256([]byte(members))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// b64Int decode a base64url (unpadded) big endian unsigned integer, e.g. an RSA modulus
+func b64Int(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 { return nil, errors.New("jwk: invalid base64url integer") }
+
+	return new(big.Int).SetBytes(b), nil
+}
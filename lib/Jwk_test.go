@@ -0,0 +1,111 @@
+package lib
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"testing"
+
+	"github.com/kataras/jwt"
+
+	"go.api.backend/schema/dto"
+)
+
+func TestParseJWK(t *testing.T) {
+	b64 := base64.RawURLEncoding.EncodeToString
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	edPub, _, _ := ed25519.GenerateKey(rand.Reader)
+
+	rsaJWK := dto.JWK{Kty: "RSA", N: b64(rsaKey.N.Bytes()), E: b64(big.NewInt(int64(rsaKey.E)).Bytes())}
+	ecJWK := dto.JWK{Kty: "EC", Crv: "P-256", X: b64(ecKey.X.Bytes()), Y: b64(ecKey.Y.Bytes())}
+
+	tests := []struct {
+		name    string
+		jwk     dto.JWK
+		wantAlg jwt.Alg
+	}{
+		{name: "RSA", jwk: rsaJWK, wantAlg: jwt.RS256},
+		{name: "RSA declared alg", jwk: func() dto.JWK { k := rsaJWK; k.Alg = "PS256"; return k }(), wantAlg: jwt.PS256},
+		{name: "EC", jwk: ecJWK, wantAlg: jwt.ES256},
+		{name: "Ed25519", jwk: dto.JWK{Kty: "OKP", Crv: "Ed25519", X: b64(edPub)}, wantAlg: jwt.EdDSA},
+		{name: "EC point off the curve", jwk: func() dto.JWK { k := ecJWK; k.Y = k.X; return k }()},
+		{name: "unsupported curve", jwk: dto.JWK{Kty: "OKP", Crv: "X25519", X: b64(edPub)}},
+		{name: "unsupported type", jwk: dto.JWK{Kty: "oct"}},
+		{name: "unsupported alg", jwk: func() dto.JWK { k := rsaJWK; k.Alg = "HS256"; return k }()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			alg, pub, err := ParseJWK(&tt.jwk)
+
+			if tt.wantAlg == nil {
+				if err == nil { t.Fatalf("alg = %v, want an error", alg) }
+				return
+			}
+			if err != nil || alg != tt.wantAlg || pub == nil { t.Fatalf("alg, err = %v, %v, want %s", alg, err, tt.wantAlg.Name()) }
+		})
+	}
+}
+
+// The encryption keys and the keys without id are skipped
+func TestKeysFromJWKS(t *testing.T) {
+	_, err := KeysFromJWKS(&dto.JWKSIn{Keys: []dto.JWK{{Kty: "oct", Kid: "a"}}})
+	if err == nil { t.Fatal("a set without usable keys must fail") }
+
+	edPub, _, _ := ed25519.GenerateKey(rand.Reader)
+	x := base64.RawURLEncoding.EncodeToString(edPub)
+	keys, err := KeysFromJWKS(&dto.JWKSIn{Keys: []dto.JWK{
+		{Kty: "OKP", Crv: "Ed25519", X: x, Kid: "sig"},
+		{Kty: "OKP", Crv: "Ed25519", X: x, Kid: "enc", Use: "enc"},
+		{Kty: "OKP", Crv: "Ed25519", X: x},
+	}})
+
+	if err != nil || len(keys) != 1 || keys["sig"] == nil { t.Fatalf("keys = %v, %v, want only the sig one", keys, err) }
+}
+
+// The keys go through ToJWK & ParseJWK unchanged
+func TestToJWK(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	edPub, _, _ := ed25519.GenerateKey(rand.Reader)
+
+	tests := []struct {
+		name string
+		alg  jwt.Alg
+		pub  jwt.PublicKey
+	}{
+		{name: "RSA", alg: jwt.RS256, pub: &rsaKey.PublicKey},
+		{name: "EC", alg: jwt.ES512, pub: &ecKey.PublicKey},
+		{name: "Ed25519", alg: jwt.EdDSA, pub: edPub},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jwk, err := ToJWK("kid", tt.alg, tt.pub)
+			if err != nil { t.Fatal(err) }
+			if jwk.Kid != "kid" || jwk.Use != "sig" || jwk.Alg != tt.alg.Name() { t.Fatalf("jwk = %+v", jwk) }
+
+			alg, pub, err := ParseJWK(jwk)
+			if err != nil || alg != tt.alg { t.Fatalf("parsed alg, err = %v, %v, want %s", alg, err, tt.alg.Name()) }
+			if !pub.(interface{ Equal(crypto.PublicKey) bool }).Equal(tt.pub) { t.Fatal("parsed key differs") }
+		})
+	}
+
+	if _, err := ToJWK("kid", jwt.HS256, []byte("secret")); err == nil { t.Fatal("a secret key must not be published") }
+}
+
+// The RFC 7638 (3.1) example
+func TestThumbprint(t *testing.T) {
+	jwk := &dto.JWK{
+		Kty: "RSA", E: "AQAB", Alg: "RS256", Kid: "2011-04-29",
+		N: "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+	}
+
+	if got := Thumbprint(jwk); got != "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs" { t.Fatalf("thumbprint = %s", got) }
+}
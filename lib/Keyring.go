@@ -0,0 +1,180 @@
+package lib
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/kataras/jwt"
+
+	"go.api.backend/schema/dto"
+)
+
+// minRSABits is the minimum size of the RSA keys
+const minRSABits = 2048
+
+// Keyring holds the access tokens keys: the signing one and the verification ones, by key id (kid header). The signing
+// key is either an asymmetric key (RS256 or EdDSA), so the services verifying the tokens can't mint them, or the shared
+// HS256 key (legacy). It's read only once created, so it's safe for concurrent use
+type Keyring struct {
+	kid         string    // Signing key id, empty for the HS256 key
+	keys        jwt.Keys  // Verification keys, by kid. The HS256 one has no kid
+	legacyUntil time.Time // With a signing key, the HS256 tokens are rejected after it
+}
+
+// LoadKeyring create the access tokens keyring. Without key files, the tokens are signed & verified with the shared
+// HS256 key, without kid. Otherwise they're signed with the first key file and verified with any of them, so the
+// previous keys can still verify the tokens issued before a rotation. The key ids are the RFC 7638 thumbprints of the
+// public keys, the same across the replicas
+//
+// - files [[]string] ~ PEM key files. The first one is the signing (private) key, the rest can be public keys
+//
+// - hmacKey [[]byte] ~ HS256 shared key, 32 bytes at least. Required without key files, otherwise it only verifies
+// the HS256 tokens issued before switching to the key files, until legacyUntil (optional, drop it once they expired)
+//
+// - legacyUntil [time.Time] ~ Required by the HS256 key with key files: its tokens are rejected after it
+func LoadKeyring(files []string, hmacKey []byte, legacyUntil time.Time) (*Keyring, error) {
+	k := &Keyring{keys: jwt.Keys{}}
+
+	if len(hmacKey) > 0 {
+		if len(hmacKey) < 32 { return nil, errors.New("keyring: the HS256 key must have 32 bytes at least") }
+		k.keys.Register(jwt.HS256, "", hmacKey, hmacKey)
+	}
+	if len(files) == 0 {
+		if len(hmacKey) == 0 { return nil, errors.New("keyring: no signing key, neither key files nor HS256 key") }
+		return k, nil
+	}
+	if len(hmacKey) > 0 {
+		if legacyUntil.IsZero() { return nil, errors.New("keyring: the HS256 key along the key files requires an expiry (legacy until)") }
+		k.legacyUntil = legacyUntil
+	}
+
+	for i, file := range files {
+		raw, err := ioutil.ReadFile(file)
+		if err != nil { return nil, fmt.Errorf("keyring: %w", err) }
+
+		alg, pub, priv, err := ParseKeyPEM(raw)
+		if err != nil { return nil, fmt.Errorf("keyring: %s: %w", file, err) }
+		if i == 0 && priv == nil { return nil, fmt.Errorf("keyring: %s: the signing key must be a private key", file) }
+
+		jwk, err := ToJWK("", alg, pub)
+		if err != nil { return nil, fmt.Errorf("keyring: %s: %w", file, err) }
+		kid := Thumbprint(jwk)
+		if _, dup := k.keys[kid]; dup { return nil, fmt.Errorf("keyring: %s: the key is declared twice", file) }
+
+		if i == 0 {
+			k.kid = kid
+			k.keys.Register(alg, kid, pub, priv)
+		} else {
+			k.keys.Register(alg, kid, pub, nil)						// verification only
+		}
+	}
+
+	return k, nil
+}
+
+// LegacyUntil get the time after which the HS256 tokens are rejected, zero if they're not verified along the key files
+func (k *Keyring) LegacyUntil() time.Time {
+	return k.legacyUntil
+}
+
+// Sign create a signed JWT token with the signing key, with its kid header (none for the HS256 key)
+//
+// - claims [interface{}] ~ Data to be tokenized
+//
+// - maxAge [time.Duration] ~ Token lifetime
+func (k *Keyring) Sign(claims interface{}, maxAge time.Duration) ([]byte, error) {
+	if k.kid == "" { return jwt.Sign(jwt.HS256, k.keys[""].Private, claims, jwt.MaxAge(maxAge)) }
+
+	return k.keys.SignToken(k.kid, claims, jwt.MaxAge(maxAge))
+}
+
+// Verify verify a JWT token with the key of its kid header, which must have the key algorithm
+//
+// - token [[]byte] ~ JWT token
+//
+// - validators [...jwt.TokenValidator] ~ Extra validations, e.g. a blocklist
+func (k *Keyring) Verify(token []byte, validators ...jwt.TokenValidator) (*jwt.VerifiedToken, error) {
+	return jwt.VerifyWithHeaderValidator(nil, nil, token, k.validateHeader, validators...)
+}
+
+// validateHeader get the verification key of the token kid, checking the algorithm. The token algorithm must be the
+// key one, so a public key is never used as an HS256 secret (algorithm confusion). Along a signing key, the HS256
+// tokens are rejected once the legacy period is over
+func (k *Keyring) validateHeader(_ string, header []byte) (jwt.Alg, jwt.PublicKey, error) {
+	var h jwt.HeaderWithKid
+	if err := jwt.Unmarshal(header, &h); err != nil { return nil, nil, err }
+
+	key, ok := k.keys[h.Kid]
+	if !ok { return nil, nil, jwt.ErrUnknownKid }
+	if h.Kid == "" && k.kid != "" && time.Now().After(k.legacyUntil) { return nil, nil, jwt.ErrUnknownKid }
+	if h.Alg != key.Alg.Name() { return nil, nil, jwt.ErrTokenAlg }
+
+	return key.Alg, key.Public, nil
+}
+
+// JWKS get the JSON Web Key Set of the verification keys, the signing one first. The HS256 key is secret, so never
+// published
+func (k *Keyring) JWKS() *dto.JWKSOut {
+	kids := make([]string, 0, len(k.keys))
+	for kid := range k.keys {
+		if kid != "" && kid != k.kid { kids = append(kids, kid) }
+	}
+	sort.Strings(kids)
+	if k.kid != "" { kids = append([]string{k.kid}, kids...) }
+
+	set := &dto.JWKSOut{Keys: make([]dto.JWK, 0, len(kids))}
+	for _, kid := range kids {
+		jwk, _ := ToJWK(kid, k.keys[kid].Alg, k.keys[kid].Public)		// the keys were checked on loading
+		set.Keys = append(set.Keys, *jwk)
+	}
+
+	return set
+}
+
+// ParseKeyPEM get the signing algorithm and the keys of a PEM encoded RSA (RS256, 2048 bits at least) or Ed25519
+// (EdDSA) key. A private key (PKCS #8, or PKCS #1 for RSA) gives both keys, a public one (PKIX, or PKCS #1 for RSA)
+// only the public key
+//
+// - raw [[]byte] ~ PEM content
+func ParseKeyPEM(raw []byte) (jwt.Alg, jwt.PublicKey, jwt.PrivateKey, error) {
+	block, _ := pem.Decode(raw)
+	if block == nil { return nil, nil, nil, errors.New("no PEM block found") }
+
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, nil, nil, errors.New("unsupported PEM block " + block.Type)
+	}
+	if err != nil { return nil, nil, nil, err }
+
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		if key.N.BitLen() < minRSABits { return nil, nil, nil, fmt.Errorf("the RSA key must have %d bits at least", minRSABits) }
+		return jwt.RS256, &key.PublicKey, key, nil
+	case *rsa.PublicKey:
+		if key.N.BitLen() < minRSABits { return nil, nil, nil, fmt.Errorf("the RSA key must have %d bits at least", minRSABits) }
+		return jwt.RS256, key, nil, nil
+	case ed25519.PrivateKey:
+		return jwt.EdDSA, key.Public(), key, nil
+	case ed25519.PublicKey:
+		return jwt.EdDSA, key, nil, nil
+	}
+
+	return nil, nil, nil, errors.New("unsupported key type, only RSA & Ed25519 keys are supported")
+}
@@ -0,0 +1,147 @@
+package lib
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kataras/jwt"
+
+	"go.api.backend/schema/dto"
+)
+
+var testHMACKey = []byte("a-testing-jwt-signature-key-32-bytes")
+
+// writeKeyPEM write a PEM key file in the test temp dir, getting its path
+func writeKeyPEM(t *testing.T, name string, typ string, der []byte) string {
+	t.Helper()
+
+	file := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600); err != nil { t.Fatal(err) }
+
+	return file
+}
+
+// testKeyFiles create an RSA & an Ed25519 private key files, and the RSA public key one
+func testKeyFiles(t *testing.T) (rsaFile string, edFile string, rsaPubFile string) {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil { t.Fatal(err) }
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	edDER, _ := x509.MarshalPKCS8PrivateKey(edKey)
+	pubDER, _ := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+
+	return writeKeyPEM(t, "rsa.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey)),
+		writeKeyPEM(t, "ed.pem", "PRIVATE KEY", edDER),
+		writeKeyPEM(t, "rsa.pub.pem", "PUBLIC KEY", pubDER)
+}
+
+func TestLoadKeyring(t *testing.T) {
+	rsaFile, edFile, rsaPubFile := testKeyFiles(t)
+	smallKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ecDER, _ := x509.MarshalPKCS8PrivateKey(ecKey)
+	garbage := filepath.Join(t.TempDir(), "garbage.pem")
+	if err := ioutil.WriteFile(garbage, []byte("not a key"), 0600); err != nil { t.Fatal(err) }
+
+	tests := []struct {
+		name    string
+		files   []string
+		hmacKey []byte
+		legacyUntil time.Time
+		wantAlg string // Signing algorithm, empty for an error
+		wantErr string
+	}{
+		{name: "HS256", hmacKey: testHMACKey, wantAlg: "HS256"},
+		{name: "RS256", files: []string{rsaFile}, wantAlg: "RS256"},
+		{name: "EdDSA with a previous RSA key", files: []string{edFile, rsaPubFile}, wantAlg: "EdDSA"},
+		{name: "RS256 with the legacy HS256 key", files: []string{rsaFile}, hmacKey: testHMACKey, legacyUntil: time.Now().Add(time.Hour), wantAlg: "RS256"},
+		{name: "legacy HS256 key without expiry", files: []string{rsaFile}, hmacKey: testHMACKey, wantErr: "requires an expiry"},
+		{name: "no key", wantErr: "no signing key"},
+		{name: "short HS256 key", hmacKey: []byte("short"), wantErr: "32 bytes"},
+		{name: "public signing key", files: []string{rsaPubFile}, wantErr: "must be a private key"},
+		{name: "same key twice", files: []string{rsaFile, rsaPubFile}, wantErr: "declared twice"},
+		{name: "missing file", files: []string{filepath.Join(t.TempDir(), "nope.pem")}, wantErr: "no such file"},
+		{name: "not a PEM", files: []string{garbage}, wantErr: "no PEM block"},
+		{name: "small RSA key", files: []string{writeKeyPEM(t, "small.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(smallKey))}, wantErr: "2048 bits"},
+		{name: "EC key", files: []string{writeKeyPEM(t, "ec.pem", "PRIVATE KEY", ecDER)}, wantErr: "only RSA & Ed25519"},
+		{name: "certificate", files: []string{writeKeyPEM(t, "cert.pem", "CERTIFICATE", []byte{0})}, wantErr: "unsupported PEM block"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := LoadKeyring(tt.files, tt.hmacKey, tt.legacyUntil)
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) { t.Fatalf("err = %v, want it containing %q", err, tt.wantErr) }
+				return
+			}
+			if err != nil { t.Fatal(err) }
+
+			tk, err := keys.Sign(dto.Claims{Sub: "alice"}, time.Minute)
+			if err != nil { t.Fatal(err) }
+			verified, err := keys.Verify(tk)
+			if err != nil { t.Fatalf("verifying its own token: %v", err) }
+
+			var h jwt.HeaderWithKid
+			_ = jwt.Unmarshal(verified.Header, &h)
+			if h.Alg != tt.wantAlg || (h.Kid == "") != (tt.wantAlg == "HS256") { t.Fatalf("header = %s, want the %s alg (kid unless HS256)", verified.Header, tt.wantAlg) }
+		})
+	}
+}
+
+// After a rotation, the tokens signed with the previous key are still verified, the ones of unknown keys aren't. The
+// legacy HS256 tokens are verified until the legacy period is over
+func TestKeyring_Rotation(t *testing.T) {
+	rsaFile, edFile, rsaPubFile := testKeyFiles(t)
+
+	before, err := LoadKeyring([]string{rsaFile}, testHMACKey, time.Now().Add(time.Hour))
+	if err != nil { t.Fatal(err) }
+	after, err := LoadKeyring([]string{edFile, rsaPubFile}, nil, time.Time{})
+	if err != nil { t.Fatal(err) }
+	expired, err := LoadKeyring([]string{rsaFile}, testHMACKey, time.Now().Add(-time.Minute))
+	if err != nil { t.Fatal(err) }
+	legacy, _ := LoadKeyring(nil, testHMACKey, time.Time{})
+
+	oldTk, _ := before.Sign(dto.Claims{Sub: "alice"}, time.Minute)
+	newTk, _ := after.Sign(dto.Claims{Sub: "alice"}, time.Minute)
+	legacyTk, _ := legacy.Sign(dto.Claims{Sub: "alice"}, time.Minute)
+
+	if _, err := after.Verify(oldTk); err != nil { t.Fatalf("previous key token = %v", err) }
+	if _, err := before.Verify(legacyTk); err != nil { t.Fatalf("legacy HS256 token = %v", err) }
+	if _, err := expired.Verify(legacyTk); err != jwt.ErrUnknownKid { t.Fatalf("legacy HS256 token after its expiry = %v, want %v", err, jwt.ErrUnknownKid) }
+	if _, err := legacy.Verify(legacyTk); err != nil { t.Fatalf("HS256 token with only the HS256 key = %v", err) }
+	if _, err := before.Verify(newTk); err != jwt.ErrUnknownKid { t.Fatalf("unknown key token = %v, want %v", err, jwt.ErrUnknownKid) }
+	if _, err := after.Verify(legacyTk); err != jwt.ErrUnknownKid { t.Fatalf("HS256 token without the HS256 key = %v, want %v", err, jwt.ErrUnknownKid) }
+
+	// the signing key is published first, and the HS256 one never
+	set := before.JWKS()
+	if len(set.Keys) != 1 || set.Keys[0].Kty != "RSA" { t.Fatalf("jwks = %+v, want only the RSA key", set) }
+	set = after.JWKS()
+	if len(set.Keys) != 2 || set.Keys[0].Kty != "OKP" || set.Keys[1].Kid != before.kid { t.Fatalf("jwks = %+v, want the Ed25519 then the RSA key", set) }
+	if len(legacy.JWKS().Keys) != 0 { t.Fatal("the HS256 key must not be published") }
+}
+
+// A token can't pick another algorithm than its key one, e.g. an HS256 token signed with the RSA public key
+func TestKeyring_AlgConfusion(t *testing.T) {
+	rsaFile, _, rsaPubFile := testKeyFiles(t)
+	keys, err := LoadKeyring([]string{rsaFile}, nil, time.Time{})
+	if err != nil { t.Fatal(err) }
+
+	pubPEM, _ := ioutil.ReadFile(rsaPubFile)
+	forged, err := jwt.SignWithHeader(jwt.HS256, pubPEM, dto.Claims{Sub: "mallory"}, jwt.HeaderWithKid{Kid: keys.kid, Alg: "HS256"})
+	if err != nil { t.Fatal(err) }
+
+	if _, err := keys.Verify(forged); err != jwt.ErrTokenAlg { t.Fatalf("forged token = %v, want %v", err, jwt.ErrTokenAlg) }
+}
@@ -0,0 +1,234 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned by OutboundClient.Do when the upstream is considered down (its circuit breaker is open),
+// without hitting it
+var ErrCircuitOpen = errors.New("the upstream is down (circuit breaker open), try again later")
+
+// outboundVars the outbound clients metrics, published by expvar (/debug/vars) as "outbound", one map per upstream
+var outboundVars = expvar.NewMap("outbound")
+
+// outboundTransport is the transport shared by all the outbound clients, so they share the connection pool
+var outboundTransport = &http.Transport{
+	Proxy:                 http.ProxyFromEnvironment,
+	DialContext:           (&net.Dialer{Timeout: 5 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+	TLSHandshakeTimeout:   5 * time.Second,
+	ResponseHeaderTimeout: 30 * time.Second,
+	MaxIdleConns:          100,
+	MaxIdleConnsPerHost:   10,
+	IdleConnTimeout:       90 * time.Second,
+}
+
+// latencyBuckets the upper bounds of the latency histogram buckets, the last bucket has no bound
+var latencyBuckets = []struct {
+	name  string
+	bound time.Duration
+}{{"le_100ms", 100 * time.Millisecond}, {"le_500ms", 500 * time.Millisecond}, {"le_1s", time.Second}, {"le_5s", 5 * time.Second}}
+
+// idempotentKey the context key marking a request as safe to be retried, see WithIdempotent
+type idempotentKey struct{}
+
+// checkKey the context key marking a request as a reachability check, see WithCheck
+type checkKey struct{}
+
+// OutboundOpts are the settings of an OutboundClient. The zero values get defaults
+type OutboundOpts struct {
+	Name             string        // Upstream name, for the metrics. Required
+	Timeout          time.Duration // Per attempt timeout, including the response body reading. 10s by default
+	MaxAttempts      int           // Attempts of a retryable request, 3 by default
+	Backoff          time.Duration // Base backoff between attempts, doubled every attempt and jittered. 100ms by default
+	BreakerThreshold int           // Consecutive failures opening the circuit breaker, 5 by default
+	BreakerCooldown  time.Duration // Time the circuit stays open before letting a probe request through, 30s by default
+}
+
+// OutboundClient is an HTTP client for the upstream services (e.g. the auth providers), one per upstream. It adds to
+// the plain client a per attempt timeout, retries with jittered exponential backoff, a circuit breaker and expvar
+// metrics. A failure is a network error or a 5xx response; only the idempotent requests are retried (see Do).
+// It's safe for concurrent use
+type OutboundClient struct {
+	opts   OutboundOpts
+	client *http.Client
+	vars   *expvar.Map
+
+	mu        sync.Mutex
+	failures  int       // Consecutive failures
+	openUntil time.Time // Open circuit deadline, zero if closed
+	probing   bool      // A probe request is in flight (half-open circuit)
+}
+
+// NewOutboundClient create an outbound client for an upstream, publishing its metrics as outbound.<name>
+//
+// - opts [OutboundOpts] ~ Client settings
+func NewOutboundClient(opts OutboundOpts) *OutboundClient {
+	if opts.Timeout <= 0 { opts.Timeout = 10 * time.Second }
+	if opts.MaxAttempts <= 0 { opts.MaxAttempts = 3 }
+	if opts.Backoff <= 0 { opts.Backoff = 100 * time.Millisecond }
+	if opts.BreakerThreshold <= 0 { opts.BreakerThreshold = 5 }
+	if opts.BreakerCooldown <= 0 { opts.BreakerCooldown = 30 * time.Second }
+
+	vars := new(expvar.Map).Init()
+	outboundVars.Set(opts.Name, vars)					// replacing the previous client of the same upstream, if any
+
+	return &OutboundClient{opts: opts, client: &http.Client{Transport: outboundTransport, Timeout: opts.Timeout}, vars: vars}
+}
+
+// WithIdempotent get a copy of the context marking the requests made with it as idempotent, so they can be retried
+// even if their method isn't (e.g. a POST lookup without side effects, or carrying an idempotency key the upstream
+// deduplicates). Never mark a request consuming a credential or a one-time code, like an OAuth2 password or
+// authorization code grant: a retried attempt may have been processed upstream already
+//
+// - ctx [context.Context] ~ Parent context
+func WithIdempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentKey{}, true)
+}
+
+// WithCheck get a copy of the context marking the requests made with it as reachability checks (e.g. the readiness
+// ones). A check is sent once and bypasses the circuit breaker: it's never short-circuited and its outcome doesn't
+// count, so the checks can't open (or close) the circuit of the real traffic
+//
+// - ctx [context.Context] ~ Parent context
+func WithCheck(ctx context.Context) context.Context {
+	return context.WithValue(ctx, checkKey{}, true)
+}
+
+// Do send the request, retrying the failed attempts if the request is idempotent (GET, HEAD, OPTIONS, PUT, DELETE or
+// marked with WithIdempotent) and its body can be replayed. The last response is returned as is, even a 5xx one, so
+// the caller can interpret it. If the circuit breaker is open then ErrCircuitOpen is returned right away. A canceled
+// (or timed out) request context isn't an upstream failure, it's neither retried nor counted by the circuit breaker
+//
+// - req [*http.Request] ~ Request to be sent, built with http.NewRequest (so its body can be replayed)
+func (c *OutboundClient) Do(req *http.Request) (*http.Response, error) {
+	if check, _ := req.Context().Value(checkKey{}).(bool); check {
+		c.vars.Add("checks", 1)
+		return c.client.Do(req)
+	}
+
+	retryable := isIdempotent(req) && (req.Body == nil || req.GetBody != nil)
+
+	for attempt := 1; ; attempt++ {
+		res, err := c.attempt(req, attempt)
+		if err == ErrCircuitOpen || req.Context().Err() != nil || !isFailure(res, err) || !retryable || attempt == c.opts.MaxAttempts {
+			return res, err
+		}
+
+		if res != nil {										// releasing the connection of the discarded response
+			_, _ = io.Copy(ioutil.Discard, io.LimitReader(res.Body, 4 << 10))
+			res.Body.Close()
+		}
+		c.vars.Add("retries", 1)
+
+		// Exponential backoff, with full jitter so the clients don't retry in lockstep
+		wait := time.Duration(rand.Int63n(int64(c.opts.Backoff << (attempt - 1)) + 1))
+		select {
+		case <-req.Context().Done(): return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// attempt send the request once, through the circuit breaker, recording the metrics
+func (c *OutboundClient) attempt(req *http.Request, n int) (*http.Response, error) {
+	allowed, probe := c.allow()
+	if !allowed {
+		c.vars.Add("short_circuits", 1)
+		return nil, ErrCircuitOpen
+	}
+	if probe { defer c.endProbe() }						// whatever the outcome, even without sending it
+
+	r := req
+	if n > 1 && req.GetBody != nil {						// a fresh body for every retry
+		body, err := req.GetBody()
+		if err != nil { return nil, err }
+		r = req.Clone(req.Context())
+		r.Body = body
+	}
+
+	start := time.Now()
+	res, err := c.client.Do(r)
+	if err != nil && req.Context().Err() != nil {			// given up by the caller, it tells nothing about the upstream
+		c.vars.Add("canceled", 1)
+		return res, err
+	}
+	c.observe(time.Since(start), res, err)
+
+	return res, err
+}
+
+// allow tells if a request can go through the circuit breaker, and if it's the probe one. When the open circuit cools
+// down, a single probe request is let through (half-open): its outcome closes or reopens the circuit. The probe must
+// be ended with endProbe
+func (c *OutboundClient) allow() (bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.openUntil.IsZero() { return true, false }
+	if c.probing || time.Now().Before(c.openUntil) { return false, false }
+
+	c.probing = true
+	return true, true
+}
+
+// endProbe let another probe request through, once the in flight one ended. If it wasn't observed (e.g. its body
+// couldn't be replayed or it was canceled) the circuit is still open, so the next request is a probe again
+func (c *OutboundClient) endProbe() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.probing = false
+}
+
+// observe record the outcome of an attempt on the circuit breaker & the metrics
+func (c *OutboundClient) observe(latency time.Duration, res *http.Response, err error) {
+	failed := isFailure(res, err)
+
+	c.vars.Add("requests", 1)
+	c.vars.Add("latency_us_sum", latency.Microseconds())
+	bucket := "gt_5s"
+	for _, b := range latencyBuckets {
+		if latency <= b.bound { bucket = b.name; break }
+	}
+	c.vars.Add("latency_" + bucket, 1)
+	if failed { c.vars.Add("failures", 1) }
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !failed {
+		c.failures, c.openUntil = 0, time.Time{}
+		return
+	}
+
+	c.failures++
+	if c.failures >= c.opts.BreakerThreshold || !c.openUntil.IsZero() {			// a failed probe reopens the circuit
+		if c.openUntil.IsZero() { c.vars.Add("breaker_opens", 1) }
+		c.openUntil = time.Now().Add(c.opts.BreakerCooldown)
+	}
+}
+
+// isFailure tells if an attempt failed: a network error or an upstream failure (5xx)
+func isFailure(res *http.Response, err error) bool {
+	return err != nil || res.StatusCode >= http.StatusInternalServerError
+}
+
+// isIdempotent tells if a request can be safely retried, by its method or by its context (see WithIdempotent)
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	marked, _ := req.Context().Value(idempotentKey{}).(bool)
+	return marked
+}
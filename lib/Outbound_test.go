@@ -0,0 +1,148 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestUpstream start an upstream answering with the statuses in order (the last one for good), counting the calls
+func newTestUpstream(t *testing.T, statuses ...int) (*httptest.Server, *int32) {
+	t.Helper()
+
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(&calls, 1))
+		if n > len(statuses) { n = len(statuses) }
+		w.WriteHeader(statuses[n - 1])
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, &calls
+}
+
+func TestOutboundClient_Retries(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		idempotent bool
+		statuses   []int
+		wantStatus int
+		wantCalls  int32
+	}{
+		{name: "GET recovered", method: http.MethodGet, statuses: []int{503, 502, 200}, wantStatus: 200, wantCalls: 3},
+		{name: "GET exhausted", method: http.MethodGet, statuses: []int{503}, wantStatus: 503, wantCalls: 3},
+		{name: "POST not retried", method: http.MethodPost, statuses: []int{503, 200}, wantStatus: 503, wantCalls: 1},
+		{name: "idempotent POST retried", method: http.MethodPost, idempotent: true, statuses: []int{503, 200}, wantStatus: 200, wantCalls: 2},
+		{name: "client errors not retried", method: http.MethodGet, statuses: []int{404, 200}, wantStatus: 404, wantCalls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, calls := newTestUpstream(t, tt.statuses...)
+			c := NewOutboundClient(OutboundOpts{Name: "test", Backoff: time.Millisecond})
+
+			ctx := context.Background()
+			if tt.idempotent { ctx = WithIdempotent(ctx) }
+			req, _ := http.NewRequestWithContext(ctx, tt.method, srv.URL, strings.NewReader("body"))
+
+			res, err := c.Do(req)
+			if err != nil { t.Fatal(err) }
+			res.Body.Close()
+
+			if res.StatusCode != tt.wantStatus || *calls != tt.wantCalls {
+				t.Fatalf("status, calls = %d, %d, want %d, %d", res.StatusCode, *calls, tt.wantStatus, tt.wantCalls)
+			}
+			if got := c.vars.Get("requests").String(); got != strconv.Itoa(int(tt.wantCalls)) { t.Fatalf("requests metric = %s, want %d", got, tt.wantCalls) }
+		})
+	}
+}
+
+// The circuit opens after the threshold, short-circuits meanwhile, and a successful probe closes it after the cooldown
+func TestOutboundClient_CircuitBreaker(t *testing.T) {
+	srv, calls := newTestUpstream(t, 500, 500, 200)
+	c := NewOutboundClient(OutboundOpts{Name: "test", MaxAttempts: 1, BreakerThreshold: 2, BreakerCooldown: 50 * time.Millisecond})
+
+	get := func() (int, error) {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		res, err := c.Do(req)
+		if err != nil { return 0, err }
+		res.Body.Close()
+		return res.StatusCode, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		if status, err := get(); status != 500 || err != nil { t.Fatalf("failure %d = %d, %v", i, status, err) }
+	}
+	if _, err := get(); err != ErrCircuitOpen { t.Fatalf("open circuit error = %v, want ErrCircuitOpen", err) }
+	if *calls != 2 { t.Fatalf("upstream calls with open circuit = %d, want 2", *calls) }
+
+	time.Sleep(60 * time.Millisecond)
+	if status, err := get(); status != 200 || err != nil { t.Fatalf("probe = %d, %v, want 200", status, err) }
+	if status, err := get(); status != 200 || err != nil { t.Fatalf("closed circuit = %d, %v, want 200", status, err) }
+
+	if got := c.vars.Get("short_circuits").String(); got != "1" { t.Fatalf("short_circuits metric = %s, want 1", got) }
+	if got := c.vars.Get("breaker_opens").String(); got != "1" { t.Fatalf("breaker_opens metric = %s, want 1", got) }
+}
+
+// A probe that couldn't be sent (its body can't be replayed) ends anyway, so the circuit doesn't stay open for good
+func TestOutboundClient_UnsentProbe(t *testing.T) {
+	srv, _ := newTestUpstream(t, 500, 200)
+	c := NewOutboundClient(OutboundOpts{Name: "test", Backoff: time.Millisecond, BreakerThreshold: 1, BreakerCooldown: time.Nanosecond})
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, strings.NewReader("body"))
+	req.GetBody = func() (io.ReadCloser, error) { return nil, errors.New("no replay") }
+	if _, err := c.Do(req); err == nil || err.Error() != "no replay" { t.Fatalf("retry without body = %v, want the GetBody error", err) }
+
+	req, _ = http.NewRequest(http.MethodGet, srv.URL, nil)
+	res, err := c.Do(req)
+	if err != nil { t.Fatalf("next probe = %v, want it let through", err) }
+	res.Body.Close()
+	if res.StatusCode != 200 { t.Fatalf("next probe status = %d, want 200", res.StatusCode) }
+}
+
+// The requests given up by the caller (canceled or timed out context) aren't upstream failures
+func TestOutboundClient_Canceled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	t.Cleanup(srv.Close)
+	c := NewOutboundClient(OutboundOpts{Name: "test", Backoff: time.Millisecond, BreakerThreshold: 1})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20 * time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	if _, err := c.Do(req); err == nil { t.Fatal("timed out request = nil error") }
+
+	if !c.openUntil.IsZero() || c.failures != 0 { t.Fatalf("breaker failures = %d, open until %v, want it closed", c.failures, c.openUntil) }
+	if got := c.vars.Get("canceled").String(); got != "1" { t.Fatalf("canceled metric = %s, want 1 (no retries)", got) }
+}
+
+// The reachability checks go through an open circuit, and don't close it
+func TestOutboundClient_Check(t *testing.T) {
+	srv, calls := newTestUpstream(t, 500, 200)
+	c := NewOutboundClient(OutboundOpts{Name: "test", MaxAttempts: 1, BreakerThreshold: 1, BreakerCooldown: time.Hour})
+
+	do := func(ctx context.Context) (int, error) {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodHead, srv.URL, nil)
+		res, err := c.Do(req)
+		if err != nil { return 0, err }
+		res.Body.Close()
+		return res.StatusCode, nil
+	}
+
+	if status, _ := do(context.Background()); status != 500 { t.Fatalf("failure = %d, want 500", status) }
+	if status, err := do(WithCheck(context.Background())); status != 200 || err != nil { t.Fatalf("check = %d, %v, want 200", status, err) }
+	if _, err := do(context.Background()); err != ErrCircuitOpen { t.Fatalf("request after the check = %v, want ErrCircuitOpen", err) }
+	if *calls != 2 { t.Fatalf("upstream calls = %d, want 2", *calls) }
+}
@@ -0,0 +1,48 @@
+package lib
+
+import (
+	"regexp"
+	"strings"
+)
+
+// SearchWord a searchable word, any run of letters & digits. Everything else (punctuation, tsquery operators...)
+// is a separator. It's the search tokenizer of every books repository, so they all split the words the same way
+var SearchWord = regexp.MustCompile(`[\pL\pN]+`)
+
+// Highlighted matches delimiters, for the search snippets
+const (
+	HighlightStart = "<mark>"
+	HighlightStop  = "</mark>"
+)
+
+// SearchTerms split a free text search query in its searchable words, lower cased. The duplicated words are
+// removed, and there are at most max of them (0 means no limit)
+//
+// - q [string] ~ Free text search query, e.g. "the hobb"
+//
+// - max [int] ~ Max amount of terms
+func SearchTerms(q string, max int) []string {
+	terms := make([]string, 0)
+	seen := make(map[string]bool)
+
+	for _, w := range SearchWord.FindAllString(strings.ToLower(q), -1) {
+		if seen[w] { continue }
+		seen[w] = true
+
+		terms = append(terms, w)
+		if max > 0 && len(terms) == max { break }
+	}
+
+	return terms
+}
+
+// MkPrefixTsQuery create a Postgres tsquery (to_tsquery syntax) matching all the terms as prefixes, e.g.
+// [the hobb] => "the:* & hobb:*". The terms must come from SearchTerms, so they carry no tsquery operators
+//
+// - terms [[]string] ~ Search terms
+func MkPrefixTsQuery(terms []string) string {
+	parts := make([]string, len(terms))
+	for i, t := range terms { parts[i] = t + ":*" }
+
+	return strings.Join(parts, " & ")
+}
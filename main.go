@@ -1,9 +1,17 @@
 package main
 
 import (
+	"context"
+	"expvar"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
 	"github.com/kataras/iris/v12"
 	"github.com/kataras/iris/v12/middleware/logger"
 	"github.com/kataras/iris/v12/middleware/recover"
+	"github.com/kataras/iris/v12/middleware/requestid"
 
 	"github.com/iris-contrib/swagger/v12"              			// swagger middleware for Iris
 	"github.com/iris-contrib/swagger/v12/swaggerFiles" 			// swagger embed files
@@ -16,7 +24,12 @@ import (
 	_ "go.api.backend/docs"
 
 	"go.api.backend/api/middlewares"
+	"go.api.backend/lib"
+	"go.api.backend/repo/db"
+	"go.api.backend/schema"
 	"go.api.backend/schema/database"
+	"go.api.backend/service"
+	"go.api.backend/service/auth"
 	"go.api.backend/service/utils"
 )
 
@@ -28,7 +41,9 @@ import (
 // @contact.url http://contact.sample/text
 // @contact.email sample@mail.io
 
-// @authorizationurl https://example.com/oauth/authorize
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name Authorization
 
 // @host localhost:8080
 // @BasePath /
@@ -41,7 +56,30 @@ func main() {
 	app.Validator = v		// Register validation on the iris app
 
 	// Services
-	svcC := utils.NewSvcConfig("D:\\Source\\Go\\src\\go.api.backend\\conf.dev.yaml") 			// Creating Configuration Service
+	svcC, err := utils.NewSvcConfig(os.Args[1:]) 							// Creating Configuration Service (file, env & flags)
+	if err == flag.ErrHelp {
+		os.Exit(0)
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(2)
+	}
+
+	// Subcommands, e.g. app -dbpass=xxx migrate up (❗ the flags go before the subcommand)
+	if len(svcC.Args) > 0 {
+		if svcC.Args[0] != "migrate" {
+			fmt.Fprintln(os.Stderr, "unknown command " + svcC.Args[0])
+			os.Exit(2)
+		}
+
+		if err := database.RunMigrateCmd(svcC, svcC.Args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	for _, w := range svcC.Warnings { app.Logger().Warn(w) }			// e.g. deprecated settings
+
 	svcR := utils.NewSvcResponse(svcC)                                               				// Creating Response Service
 	// endregion =============================================================================
 
@@ -50,23 +88,78 @@ func main() {
 	// built-ins
 	app.Use(logger.New())
 	app.UseRouter(recover.New()) // Recovery middleware recovers from any panics and writes a 500 if there was one.
-
-	// customs
-	MdwAuthChecker := middlewares.NewAuthCheckerMiddleware([]byte(svcC.JWTSignKey))					// TODO get the JWTSignKey from OS env
+	app.UseRouter(requestid.New()) // X-Request-ID, the given one or a new one. It's recorded in the audit log
 
 	// endregion =============================================================================
 
 	// region ======== DATABASE BOOTSTRAPPING ================================================
 
 	pgdb := database.Bootstrap(svcC) // Starting the database and creating the engine
-	// database.CreateSchema(pgdb, false) 				// Table creation method
-	// database.MkMigrations(svcC)						// Making migrations
+	bgCtx, stopBg := context.WithCancel(context.Background())		// Background jobs context, canceled on shutdown
+
+	// Table creation & migrations, under an advisory lock so the replicas don't race (opt-in, see AutoMigrate conf)
+	if svcC.AutoMigrate {
+		n, err := database.AutoMigrate(svcC, pgdb)
+		if err != nil { panic(err) }
+		app.Logger().Infof("applied %d migrations", n)
+	}
+	// endregion =============================================================================
+
+	// region ======== AUTH MIDDLEWARES ======================================================
+
+	var legacyUntil time.Time																		// zero if unset
+	if svcC.JWTLegacyUntil != "" {
+		if legacyUntil, err = time.Parse(time.RFC3339, svcC.JWTLegacyUntil); err != nil {
+			fmt.Fprintln(os.Stderr, "JWTLegacyUntil (APP_JWTLEGACYUNTIL): " + err.Error())
+			os.Exit(2)
+		}
+	}
+	keys, err := lib.LoadKeyring(svcC.JWTKeyFiles, []byte(svcC.JWTSignKey), legacyUntil)			// Access tokens keys (JWTKeyFiles, JWTSignKey & JWTLegacyUntil conf)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(2)
+	}
+	if until := keys.LegacyUntil(); !until.IsZero() {
+		app.Logger().Warnf("the legacy HS256 tokens (JWTSignKey) are accepted until %s, drop JWTSignKey afterwards", until.Format(time.RFC3339))
+	}
+
+	blocklist := auth.NewBlocklist(bgCtx, svcC, pgdb)												// Logged out tokens storage
+	MdwAuthChecker := middlewares.NewAuthCheckerMiddleware(keys, blocklist)
+	policy, err := middlewares.NewRoutePolicy(svcR, svcC, &MdwAuthChecker)							// Reads & writes protection (ReadAccess & WriteScopes conf)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(2)
+	}
 	// endregion =============================================================================
 
 	// region ======== ENDPOINT REGISTRATIONS ================================================
 
-	endpoints.NewBookHandler(app, pgdb, svcR)
-	endpoints.NewAuthHandler(app, &MdwAuthChecker, svcR, svcC)
+	// TIP As an alternative, we may not use a pointer and leave the cleaning job to the GO garbage collector
+	bookRepo := db.NewRepoDbBook(pgdb)																// Instantiating repo
+	auditRepo := db.NewRepoDbAudit(pgdb)
+	uow := db.NewUnitOfWork(pgdb)																	// Instantiating unit of work, for the multi-repo ops
+	svcBook := service.NewSvcBooks(&bookRepo, &uow)													// Instantiating service
+	svcAudit := service.NewSvcAudit(&auditRepo)
+	userRepo := db.NewRepoDbUser(pgdb)
+	tokenRepo := db.NewRepoDbRefreshToken(pgdb)
+	svcUser := service.NewSvcUsers(&userRepo, &tokenRepo)
+
+	// auth providers, the enabled ones of the AuthProviders setting
+	svcA, err := auth.NewSvcAuthentication(svcC, &userRepo)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(2)
+	}
+
+	svcToken := auth.NewSvcToken(&tokenRepo, svcC, keys, svcA)
+
+	endpoints.NewBookHandler(app, &svcBook, svcR, &policy)
+	endpoints.NewAuditHandler(app, &svcAudit, svcR, &policy)
+	endpoints.NewAuthHandler(app, &MdwAuthChecker, svcR, svcC, &svcUser, &svcToken, svcA)
+	endpoints.NewHealthHandler(app, pgdb, svcR, svcC, svcA)
+
+	// expvar metrics, e.g. the auth providers outbound clients ones (outbound.<provider>). Admins only, they carry the command line
+	app.Get("/debug/vars", policy.Authed(middlewares.NewRoleGuardMiddleware(svcR, schema.RolAdmin), iris.FromStd(expvar.Handler()))...)
 	// endregion =============================================================================
 
 	// region ======== SWAGGER REGISTRATION ==================================================
@@ -83,6 +176,36 @@ func main() {
 	app.Get("/swagger/{any:path}", swagger.CustomWrapHandler(sc, swaggerFiles.Handler))
 	// endregion =============================================================================
 
-	app.Run(iris.Addr(":8080"))
+	// region ======== GRACEFUL SHUTDOWN ===================================================
+
+	// On SIGINT / SIGTERM the server stops accepting requests and drains the in-flight ones (ShutdownTimeout at most).
+	// ❗ Run returns as soon as the listener is closed, so we wait for the draining before releasing the resources
+	drained := make(chan struct{})
+	iris.RegisterOnInterrupt(func() {
+		defer close(drained)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(svcC.ShutdownTimeout) * time.Second)
+		defer cancel()
+
+		app.Logger().Info("shutting down, draining in-flight requests")
+		if err := app.Shutdown(ctx); err != nil { app.Logger().Error("shutdown: ", err) }
+	})
+	// endregion =============================================================================
+
+	err = app.Run(iris.Addr(svcC.ListenAddr), iris.WithoutInterruptHandler, iris.WithoutServerError(iris.ErrServerClosed))
 	//  app.Listen(":5000", iris.WithOptimizations) see https://github.com/kataras/iris/issues/1739, check if it related to the context.go 2307 line
+	if err == nil { <-drained }
+
+	// Releasing the resources
+	stopBg()
+	if e := pgdb.Close(); e != nil { app.Logger().Error("closing the database: ", e) }
+	app.Logger().Info("bye")
+	flushLogs(app)
+
+	if err != nil { os.Exit(1) }
+}
+
+// flushLogs flush the app logger output, if it supports it (e.g. a file)
+func flushLogs(app *iris.Application) {
+	if out, ok := app.Logger().Printer.Output.(interface{ Sync() error }); ok { _ = out.Sync() }
 }
@@ -0,0 +1,48 @@
+package db
+
+import (
+	"github.com/go-pg/pg/v10/orm"
+	"go.api.backend/schema/dto"
+	"go.api.backend/schema/models"
+)
+
+type RepoDbAudit interface {
+	Add(ent *models.AuditEntry) error
+	GetAll(list *[]models.AuditEntry, filter *dto.AuditFilter, opts *dto.QueryOpts) (int, error)
+}
+
+type dbAudits struct {
+	Pgdb orm.DB `orm.DB:"Database connection object, or a transaction (*pg.Tx)"`
+}
+
+// NewRepoDbAudit creates a new audit log Database Repository instance. The entries must be added through the
+// transaction of the audited mutation (see UnitOfWork)
+//
+// - dbCtx [orm.DB] ~ Database connection (*pg.DB), or a transaction (*pg.Tx)
+func NewRepoDbAudit(dbCtx orm.DB) RepoDbAudit {
+	return &dbAudits{dbCtx}
+}
+
+// Add an entry to the audit log. The entry gets its Id & creation time set
+//
+// - ent [*models.AuditEntry] ~ New audit entry
+func (r *dbAudits) Add(ent *models.AuditEntry) error {
+	_, err := r.Pgdb.Model(ent).Insert()
+	return translateErr(err)
+}
+
+// GetAll get a page of audit entries matching the filter, newest first, and set the result in the referenced
+// (pointer) list (slice). It returns the total amount of matching entries
+//
+// - list [*[]models.AuditEntry] ~ A pointer to a slice for storing the query result
+//
+// - filter [*dto.AuditFilter] ~ Filtering criteria
+//
+// - opts [*dto.QueryOpts] ~ Offset pagination options, the sorting is always newest first
+func (r *dbAudits) GetAll(list *[]models.AuditEntry, filter *dto.AuditFilter, opts *dto.QueryOpts) (int, error) {
+	q := r.Pgdb.Model(list).Where("entity = ?", filter.Entity)
+	if filter.EntityId > 0 { q.Where("entity_id = ?", filter.EntityId) }
+
+	total, err := q.Order("id DESC").Limit(int(opts.Limit)).Offset(int((opts.Page - 1) * opts.Limit)).SelectAndCount()
+	return total, translateErr(err)
+}
@@ -0,0 +1,91 @@
+package db
+
+import (
+	"time"
+
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/kataras/iris/v12/middleware/jwt"
+
+	"go.api.backend/lib"
+	"go.api.backend/schema/models"
+)
+
+// RepoDbBlocklist is a JWT blocklist (see jwt.Blocklist) stored in the database, so the blocked tokens survive the
+// restarts and are shared between the app replicas
+type RepoDbBlocklist interface {
+	jwt.Blocklist
+	Purge() (uint, error)
+}
+
+type dbBlocklist struct {
+	Pgdb orm.DB `orm.DB:"Database connection object, or a transaction (*pg.Tx)"`
+}
+
+// NewRepoDbBlocklist creates a new JWT blocklist Database Repository instance
+func NewRepoDbBlocklist(dbCtx orm.DB) RepoDbBlocklist {
+	return &dbBlocklist{dbCtx}
+}
+
+// ValidateToken completes the jwt.TokenValidator interface. It returns jwt.ErrBlocked if the token is blocked.
+// A previous validation error (e.g. expired token) is respected.
+func (r *dbBlocklist) ValidateToken(token []byte, c jwt.Claims, err error) error {
+	if err != nil { return err }
+
+	if has, e := r.Has(blockKey(token, c)); e != nil {
+		return e
+	} else if has {
+		return jwt.ErrBlocked
+	}
+
+	return nil
+}
+
+// InvalidateToken block a verified token until its expiration. Blocking an already blocked token is a no-op
+//
+// - token [[]byte] ~ Verified token
+//
+// - c [jwt.Claims] ~ Token standard claims
+func (r *dbBlocklist) InvalidateToken(token []byte, c jwt.Claims) error {
+	if len(token) == 0 { return jwt.ErrMissing }
+
+	ent := models.BlockedToken{Key: blockKey(token, c)}
+	if c.Expiry > 0 { ent.ExpiresAt = time.Unix(c.Expiry, 0) }
+
+	_, err := r.Pgdb.Model(&ent).OnConflict("(key) DO NOTHING").Insert()
+	return err
+}
+
+// Del remove a token from the blocklist, by its key
+func (r *dbBlocklist) Del(key string) error {
+	_, err := r.Pgdb.Model((*models.BlockedToken)(nil)).Where("key = ?", key).Delete()
+	return err
+}
+
+// Has tells if the token with the given key is blocked
+func (r *dbBlocklist) Has(key string) (bool, error) {
+	if key == "" { return false, jwt.ErrMissing }
+
+	return r.Pgdb.Model((*models.BlockedToken)(nil)).Where("key = ?", key).Exists()
+}
+
+// Count return the total amount of blocked tokens
+func (r *dbBlocklist) Count() (int64, error) {
+	n, err := r.Pgdb.Model((*models.BlockedToken)(nil)).Count()
+	return int64(n), err
+}
+
+// Purge delete the blocked tokens that are already expired, they are rejected by the verification anyway.
+// Return the amount of purged tokens
+func (r *dbBlocklist) Purge() (uint, error) {
+	res, err := r.Pgdb.Model((*models.BlockedToken)(nil)).Where("expires_at < ?", time.Now()).Delete()
+	if err != nil || res == nil { return 0, err }
+
+	return uint(res.RowsAffected()), nil
+}
+
+// blockKey get the blocklist key of a token, its "jti" claim if any or the token hash otherwise
+func blockKey(token []byte, c jwt.Claims) string {
+	if c.ID != "" { return c.ID }
+
+	return lib.MkTokenHash(string(token))
+}
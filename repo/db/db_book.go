@@ -1,98 +1,356 @@
 package db
 
 import (
-	"errors"
 	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"go.api.backend/lib"
 	"go.api.backend/schema"
+	"go.api.backend/schema/dto"
+	"go.api.backend/schema/errs"
 	"go.api.backend/schema/models"
+	"reflect"
+	"strings"
 	"time"
 )
 
 type RepoDbBook interface {
-	GetAll(list *[]models.Book) error
+	GetAll(list *[]models.Book, opts *dto.QueryOpts, filter *dto.BookFilter) (int, error)
+	Search(list *[]models.BookHit, q string, opts *dto.QueryOpts) (int, error)
 	GetByID(ent *models.Book) error
-	DelByID(Id *uint) (uint, error)
+	GetByIDForUpdate(ent *models.Book) error
+	GetTrashedByID(ent *models.Book) error
+	DelByID(Id *uint, version uint) (uint, error)
 	Add(ent *models.Book) error
-	Update(ent *models.Book) (uint, error)
+	Update(ent *models.Book, columns ...string) (uint, error)
+	Restore(ent *models.Book) error
+	PurgeByID(Id *uint) (uint, error)
+	PurgeTrash(purged *[]models.Book, limit uint) (uint, error)
+	ForEach(fn func(book *models.Book) error) error
+	Upsert(ent *models.Book, before *models.Book) (bool, error)
 }
 
 type dbBooks struct {
-	Pgdb *pg.DB `*pg.DB:"Database connection object"`
+	Pgdb orm.DB `orm.DB:"Database connection object, or a transaction (*pg.Tx)"`
 }
 
 // NewRepoDbBook creates a new Temporal Database Repository instance
-func NewRepoDbBook(dbCtx *pg.DB) RepoDbBook {
+//
+// - dbCtx [orm.DB] ~ Database connection (*pg.DB), or a transaction (*pg.Tx, see UnitOfWork)
+func NewRepoDbBook(dbCtx orm.DB) RepoDbBook {
 	return &dbBooks{dbCtx}
 }
 
-// GetAll get all record for a specific entity and set the result in the referenced (pointer) list (slice).
+// GetAll get a page of records for a specific entity and set the result in the referenced (pointer) list (slice).
+// It returns the total amount of records matching the filter (regardless the pagination). The books in the trash
+// are excluded, unless the filter asks for them (and only them). If a sorting column
+// doesn't exist, or the keyset pagination is combined with a non Id sorting, then err is an errs.Validation
 //
 // - list [*[]models.Book] ~ A pointer to a slice for storing the query result
-func (r *dbBooks) GetAll(list *[]models.Book) error {
+//
+// - opts [*dto.QueryOpts] ~ Pagination & sorting options
+//
+// - filter [*dto.BookFilter] ~ Filtering criteria
+func (r *dbBooks) GetAll(list *[]models.Book, opts *dto.QueryOpts, filter *dto.BookFilter) (int, error) {
+
+	total, err := r.Pgdb.Model((*models.Book)(nil)).Apply(bookFilter(filter)).Count()
+	if err != nil { return 0, translateErr(err) }
+
+	q := r.Pgdb.Model(list).Apply(bookFilter(filter)).Limit(int(opts.Limit))
+
+	if opts.IsKeyset() {							// Keyset pagination, only the Id column is allowed for sorting
+		desc := false
+		for _, s := range opts.Sort {
+			if s.Column != "id" { return 0, errs.New(errs.Validation, schema.ErrVal, schema.ErrDetInvalidSort) }
+			desc = s.Desc
+		}
+
+		// Fetching backward (Before) means walking the Id in the opposite direction, then reversing the page
+		backward, cursor := opts.Before > 0, opts.After
+		if backward { cursor = opts.Before }
+
+		if backward == desc {
+			q.Where("id > ?", cursor).Order("id ASC")
+		} else {
+			q.Where("id < ?", cursor).Order("id DESC")
+		}
+
+		if err := q.Select(); err != nil { return 0, translateErr(err) }
+		if backward { reverseBooks(*list) }
 
-	return r.Pgdb.Model(list).Select()
+		return total, nil
+	}
+
+	// Offset pagination
+	for _, s := range opts.Sort {
+		if !bookTable.HasField(s.Column) { return 0, errs.New(errs.Validation, schema.ErrVal, schema.ErrDetInvalidSort) }
+
+		if s.Desc {
+			q.Order(s.Column + " DESC")
+		} else {
+			q.Order(s.Column + " ASC")
+		}
+	}
+	q.Order("id ASC").Offset(int((opts.Page - 1) * opts.Limit))		// Id as tie breaker for a stable pagination
+
+	return total, translateErr(q.Select())
 	// _, err := r.Pgdb.Query(list, "SELECT * FROM list") hard coded query sample, allow placeholder see the docs (https://pg.uptrace.dev/placeholders/)
-	// return err, this is another alternative 'cause the Pgdb method return the error
 }
 
-// GetByID get an entity by Id. If no entity found then err != nil.
+// Search find the books (excluding the trash) matching all the words of a free text query, every word as a prefix
+// (e.g. "hob" matches "Hobbit"). It sets a page of hits in the referenced list, most relevant first, and returns the
+// total amount of matching books. If the query has no searchable words then err is an errs.Validation
+//
+// - list [*[]models.BookHit] ~ A pointer to a slice for storing the hits
+//
+// - q [string] ~ Free text search query
+//
+// - opts [*dto.QueryOpts] ~ Offset pagination options, the sorting is always by relevance
+func (r *dbBooks) Search(list *[]models.BookHit, q string, opts *dto.QueryOpts) (int, error) {
+	terms := lib.SearchTerms(q, schema.SearchMaxTerms)
+	if len(terms) == 0 { return 0, errs.New(errs.Validation, schema.ErrVal, schema.ErrDetInvalidSearch) }
+	tsq := lib.MkPrefixTsQuery(terms)
+
+	var total int
+	if _, err := r.Pgdb.QueryOne(pg.Scan(&total), `
+		SELECT count(*) FROM books, to_tsquery('simple', ?) query
+		WHERE deleted_at IS NULL AND search @@ query`, tsq); err != nil {
+		return 0, translateErr(err)
+	}
+
+	// The search column has a GIN index (see the migrations), ts_headline only runs over the page hits
+	_, err := r.Pgdb.Query(list, `
+		SELECT b.id, b.name, b.items, b.version, b.created_at, b.updated_at, b.deleted_at,
+			ts_rank(b.search, query) AS rank,
+			ts_headline('simple', b.name, query, ?) AS snippet
+		FROM books b, to_tsquery('simple', ?) query
+		WHERE b.deleted_at IS NULL AND b.search @@ query
+		ORDER BY rank DESC, b.id ASC
+		LIMIT ? OFFSET ?`,
+		"StartSel=" + lib.HighlightStart + ", StopSel=" + lib.HighlightStop + ", HighlightAll=true",
+		tsq, opts.Limit, (opts.Page - 1) * opts.Limit)
+
+	return total, translateErr(err)
+}
+
+// GetByID get an entity by Id. If no entity found then err is an errs.NotFound
 //
 // - ent [*models.Book] ~ A pointer to the holder entity struct to be found
 func (r *dbBooks) GetByID(ent *models.Book) error {
-	return r.Pgdb.Model(ent).WherePK().Select() 			// I'm not using & 'cause the param is already a pointer
+	return translateErr(r.Pgdb.Model(ent).WherePK().Select()) 			// I'm not using & 'cause the param is already a pointer
+}
+
+// GetByIDForUpdate get a book by Id, the same way than GetByID, locking its row (SELECT ... FOR UPDATE) until the
+// transaction ends. Outside a transaction there is nothing to hold the lock, so it's only meant for a unit of work
+//
+// - ent [*models.Book] ~ A pointer to the holder entity struct to be found
+func (r *dbBooks) GetByIDForUpdate(ent *models.Book) error {
+	return translateErr(r.Pgdb.Model(ent).WherePK().For("UPDATE").Select())
+}
+
+// GetTrashedByID get a book in the trash by Id. If the book isn't in the trash then err is an errs.NotFound
+//
+// - ent [*models.Book] ~ A pointer to the holder entity struct to be found
+func (r *dbBooks) GetTrashedByID(ent *models.Book) error {
+	return translateErr(r.Pgdb.Model(ent).Deleted().WherePK().Select())
 }
 
-// DelByID delete an entity by Id. If no entity found then err != nil.
-// uint > 0 if any record was deleted, otherwise if 0 and no error then 404.
+// DelByID delete an entity by Id, moving it to the trash (soft delete). If no entity found then err != nil.
+// uint > 0 if any record was deleted, otherwise if 0 and no error then 404. If a version is given and the book has
+// another one (it was modified meanwhile) then err is an errs.PreconditionFailed
+//
 // - Id [*uint] ~ Id of the entity to be deleted
-func (r *dbBooks) DelByID(Id *uint) (uint, error) {
+//
+// - version [uint] ~ Expected book version, 0 for deleting whatever the version is
+func (r *dbBooks) DelByID(Id *uint, version uint) (uint, error) {
 	b := models.Book{Id: *Id}
 
-	if res, err := r.Pgdb.Model(&b).WherePK().Delete(); res != nil {
-		return uint(res.RowsAffected()), err
-	} else {
-		return 0, err
-	}
+	q := r.Pgdb.Model(&b).WherePK()
+	if version > 0 { q.Where("version = ?", version) }
+
+	res, err := q.Delete()
+	if err != nil || res == nil { return 0, translateErr(err) }
+
+	if res.RowsAffected() == 0 && version > 0 { return 0, r.versionMismatch(*Id) }
+
+	return uint(res.RowsAffected()), nil
 }
 
 
-// Add a Book to the repository. If the book name already exist then err != nil.
-// If something occurs during the ops also err != nil.
+// Add a Book to the repository. If the book name already exist (case-insensitive, excluding the trash) then err is
+// an errs.Conflict. If something occurs during the ops also err != nil.
+// The check & insert is a single statement (ON CONFLICT, backed by the book_name_idx unique index), so two
+// concurrent adds of the same name can't both succeed.
+//
 // - ent [*models.Book] ~ New book to be added to the repo
 func (r *dbBooks) Add(ent *models.Book) error {
+	res, err := r.Pgdb.Model(ent).OnConflict("DO NOTHING").Insert()     // I'm not using & 'cause the param is already a pointer
 
-	isExist, e1 := r.Pgdb.Model(ent).Where("name = ?", ent.Name).Exists()
-	if isExist && e1 == nil {
-		return errors.New(schema.ErrDuplicateKey)
-	} else if e1 != nil {
-		return e1								// Something happen
-	} else {
-		_, e2 := r.Pgdb.Model(ent).Insert()     // I'm not using & 'cause the param is already a pointer
-		return e2
+	if err != nil {
+		return translateErr(err)								// Something happen
+	} else if res == nil || res.RowsAffected() == 0 {
+		return errs.New(errs.Conflict, schema.ErrDuplicateKey, schema.ErrDetDuplicateKey)
 	}
+
+	return nil
 }
 
-// Update update a book with the giving schema
-func (r *dbBooks) Update(ent *models.Book) (uint, error) {
+// Update update a book with the giving schema. Only the specified columns (besides updated_at) are written, all
+// the editable ones (name & items) if none is specified. The book version is incremented, and if the entity
+// carries a version the update is conditioned to it (optimistic concurrency). If the book doesn't exist then err
+// is an errs.NotFound, if the version doesn't match then err is an errs.PreconditionFailed, and if the new name is
+// already taken then err is an errs.Conflict
+//
+// - ent [*models.Book] ~ Book data to be updated, found by its Id. Its version is set to the new one
+//
+// - columns [...string] ~ Columns to be updated
+func (r *dbBooks) Update(ent *models.Book, columns ...string) (uint, error) {
+	if len(columns) == 0 { columns = []string{"name", "items"} }
 
+	expected := ent.Version
 	ent.UpdatedAt = time.Now()
-	res, err := r.Pgdb.Model(ent).WherePK().Column("name", "items", "updated_at").Update()
 
-	if err != nil {			// Something Occurs
+	q := r.Pgdb.Model(ent).WherePK().
+		Column(append(columns, "updated_at", "version")...).
+		Value("version", "version + 1").
+		Returning("version")
+	if expected > 0 { q.Where("version = ?", expected) }
 
-		if err.Error()[7:12] == schema.StrPgDuplicateKey {
-			return 0, errors.New(schema.ErrDuplicateKey) // Duplicated unique key field (name in this case)
-		}
+	res, err := q.Update()
 
-		return 0, err
+	if err != nil {			// Something Occurs, e.g. duplicated unique key field (name in this case)
+
+		return 0, translateErr(err)
 
 	} else {				// All good
 
 		if res != nil && res.RowsAffected() > 0 {		// Find & updated
 			return  1, nil								// TIP maybe you want to use FIND to return the complete entity here
 		} else {
-			return 0, errors.New(schema.ErrNotFound) 	// 404
+			if expected > 0 { return 0, r.versionMismatch(ent.Id) }
+			return 0, errs.New(errs.NotFound, schema.ErrNotFound, schema.ErrDetNotFound) 	// 404
 		}
 
 	}
-}
\ No newline at end of file
+}
+
+// Restore take a book out of the trash, setting the entity with the restored book. If the book isn't in the trash
+// then err is an errs.NotFound, and if its name was taken meanwhile by another book then err is an errs.Conflict
+//
+// - ent [*models.Book] ~ A pointer to the holder entity struct, with the Id of the book to be restored
+func (r *dbBooks) Restore(ent *models.Book) error {
+	res, err := r.Pgdb.Model(ent).Deleted().WherePK().Set("deleted_at = NULL").Returning("*").Update()
+
+	if err != nil {
+		return translateErr(err)
+	} else if res == nil || res.RowsAffected() == 0 {
+		return errs.New(errs.NotFound, schema.ErrNotFound, schema.ErrDetNotFound)
+	}
+
+	return nil
+}
+
+// PurgeByID delete for good a book in the trash. uint > 0 if the book was purged, otherwise if 0 and no error
+// then it isn't in the trash (404)
+//
+// - Id [*uint] ~ Id of the book to be purged
+func (r *dbBooks) PurgeByID(Id *uint) (uint, error) {
+	res, err := r.Pgdb.Model(&models.Book{Id: *Id}).WherePK().ForceDelete()	// ForceDelete only touches the trash (deleted_at IS NOT NULL)
+	if err != nil || res == nil { return 0, translateErr(err) }
+
+	return uint(res.RowsAffected()), nil
+}
+
+// PurgeTrash delete for good up to limit books of the trash, the oldest Ids first, in one statement. The purged books
+// are appended to purged. Return the amount of purged books, less than the limit once the trash is empty
+//
+// - purged [*[]models.Book] ~ A pointer to the slice getting the purged books
+//
+// - limit [uint] ~ Maximum amount of books to be purged
+func (r *dbBooks) PurgeTrash(purged *[]models.Book, limit uint) (uint, error) {
+	oldest := r.Pgdb.Model((*models.Book)(nil)).Deleted().Column("id").Order("id ASC").Limit(int(limit)).For("UPDATE")
+
+	res, err := r.Pgdb.Model(purged).Where("id IN (?)", oldest).Returning("*").ForceDelete()
+	if err != nil || res == nil { return 0, translateErr(err) }
+
+	return uint(res.RowsAffected()), nil
+}
+
+// ForEach run fn for every book (excluding the trash) in Id order. The rows are streamed from the database one by
+// one, so the whole catalog is never held in memory. An fn error stops the iteration and it's returned
+//
+// - fn [func(book *models.Book) error] ~ Function receiving every book. ❗ Don't keep the book pointer around
+func (r *dbBooks) ForEach(fn func(book *models.Book) error) error {
+	return translateErr(r.Pgdb.Model((*models.Book)(nil)).Order("id ASC").ForEach(fn))
+}
+
+// Upsert create a book or, if there is already a book with the same name (case-insensitive, excluding the trash),
+// update its items. It tells if the book was created (true) or updated, and set the entity Id & version. Inside a
+// transaction the existing book is locked until the transaction ends
+//
+// - ent [*models.Book] ~ Book to be created or updated
+//
+// - before [*models.Book] ~ If not nil, it's set with the existing book as it was before the update
+func (r *dbBooks) Upsert(ent *models.Book, before *models.Book) (bool, error) {
+	var created bool
+
+	if before != nil {
+		err := r.Pgdb.Model(before).Where("lower(name) = lower(?)", ent.Name).For("UPDATE").Select()
+		if err != nil && err != pg.ErrNoRows { return false, translateErr(err) }
+	}
+
+	// xmax is 0 for the freshly inserted rows, and the locking transaction Id for the updated ones
+	_, err := r.Pgdb.QueryOne(pg.Scan(&ent.Id, &ent.Version, &created), `
+		INSERT INTO books (name, items) VALUES (?, ?)
+		ON CONFLICT (lower(name)) WHERE deleted_at IS NULL
+		DO UPDATE SET items = EXCLUDED.items, updated_at = now(), version = books.version + 1
+		RETURNING id, version, (xmax = 0)`, ent.Name, ent.Items)
+
+	return created, translateErr(err)
+}
+
+// region ======== HELPERS ===============================================================
+
+// bookTable go-pg table metadata for the books, used for checking the sorting columns
+var bookTable = orm.GetTable(reflect.TypeOf(models.Book{}))
+
+// likeEscaper escape the LIKE wildcards, so the user input is used as a literal substring
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// bookFilter create a go-pg query modifier (see orm.Query.Apply) with the given books filtering criteria
+//
+// - filter [*dto.BookFilter] ~ Filtering criteria
+func bookFilter(filter *dto.BookFilter) func(q *orm.Query) (*orm.Query, error) {
+	return func(q *orm.Query) (*orm.Query, error) {
+		if filter == nil { return q, nil }
+
+		if filter.Deleted { q.Deleted() }
+
+		if filter.Name != "" { q.Where("name ILIKE ?", "%" + likeEscaper.Replace(filter.Name) + "%") }
+		if filter.ItemsMin != nil { q.Where("items >= ?", *filter.ItemsMin) }
+		if filter.ItemsMax != nil { q.Where("items <= ?", *filter.ItemsMax) }
+
+		return q, nil
+	}
+}
+
+// versionMismatch get the error for a conditioned (by version) write that affected no rows. It's an
+// errs.PreconditionFailed if the book exist (so its version is another one), errs.NotFound otherwise
+func (r *dbBooks) versionMismatch(id uint) error {
+	exists, err := r.Pgdb.Model((*models.Book)(nil)).Where("id = ?", id).Exists()
+
+	if err != nil {
+		return translateErr(err)
+	} else if exists {
+		return errs.New(errs.PreconditionFailed, schema.ErrPrecondition, schema.ErrDetPrecondition)
+	}
+
+	return errs.New(errs.NotFound, schema.ErrNotFound, schema.ErrDetNotFound)
+}
+
+// reverseBooks reverse in place the given books slice
+func reverseBooks(list []models.Book) {
+	for i, j := 0, len(list) - 1; i < j; i, j = i + 1, j - 1 {
+		list[i], list[j] = list[j], list[i]
+	}
+}
+// endregion =============================================================================
\ No newline at end of file
@@ -0,0 +1,41 @@
+package db
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/go-pg/pg/v10"
+
+	"go.api.backend/schema"
+	"go.api.backend/schema/errs"
+)
+
+// translateErr translate the go-pg errors into domain errors (see errs package), so the upper layers don't depend on
+// the database driver errors or messages. pg.ErrNoRows is NotFound, a unique violation is Conflict, and the rest of
+// the integrity / data errors are Validation. Anything else is Internal. The original error is always wrapped.
+//
+// - err [error] ~ go-pg error, nil is retrieved as is
+func translateErr(err error) error {
+	if err == nil { return nil }
+
+	var dErr *errs.Error
+	if errors.As(err, &dErr) { return err }					// Already translated
+
+	if errors.Is(err, pg.ErrNoRows) {
+		return &errs.Error{Kind: errs.NotFound, Key: schema.ErrNotFound, Detail: schema.ErrDetNotFound, Err: err}
+	}
+
+	var pgErr pg.Error
+	if errors.As(err, &pgErr) {
+		code := pgErr.Field('C')							// SQLSTATE, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+
+		switch {
+		case code == schema.StrPgDuplicateKey:
+			return &errs.Error{Kind: errs.Conflict, Key: schema.ErrDuplicateKey, Detail: schema.ErrDetDuplicateKey, Err: err}
+		case pgErr.IntegrityViolation(), strings.HasPrefix(code, "22"):		// e.g. not null, foreign key, check, too long values
+			return errs.Wrap(errs.Validation, schema.ErrVal, err)
+		}
+	}
+
+	return errs.Wrap(errs.Internal, schema.ErrRepositoryOps, err)
+}
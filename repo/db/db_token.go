@@ -0,0 +1,79 @@
+package db
+
+import (
+	"github.com/go-pg/pg/v10/orm"
+	"go.api.backend/schema/models"
+	"time"
+)
+
+type RepoDbRefreshToken interface {
+	GetByHash(ent *models.RefreshToken) error
+	Add(ent *models.RefreshToken) error
+	MarkUsed(ent *models.RefreshToken) (uint, error)
+	RevokeFamily(familyId string) (uint, error)
+	RevokeBySub(sub string) (uint, error)
+}
+
+type dbRefreshTokens struct {
+	Pgdb orm.DB `orm.DB:"Database connection object, or a transaction (*pg.Tx)"`
+}
+
+// NewRepoDbRefreshToken creates a new refresh tokens Database Repository instance
+func NewRepoDbRefreshToken(dbCtx orm.DB) RepoDbRefreshToken {
+	return &dbRefreshTokens{dbCtx}
+}
+
+// GetByHash get a refresh token by its hash. If no token found then err is an errs.NotFound
+//
+// - ent [*models.RefreshToken] ~ A pointer to the holder entity struct, with the token hash to be found
+func (r *dbRefreshTokens) GetByHash(ent *models.RefreshToken) error {
+	return translateErr(r.Pgdb.Model(ent).Where("token_hash = ?", ent.TokenHash).Select())
+}
+
+// Add a refresh token to the repository.
+//
+// - ent [*models.RefreshToken] ~ New refresh token to be added to the repo
+func (r *dbRefreshTokens) Add(ent *models.RefreshToken) error {
+	_, err := r.Pgdb.Model(ent).Insert()
+	return translateErr(err)
+}
+
+// MarkUsed set the token as used (rotated). The update is conditioned to the token being still unused and not
+// revoked, so uint == 0 means that the token was already used (e.g. a concurrent reuse), otherwise uint > 0.
+//
+// - ent [*models.RefreshToken] ~ Token to be marked, found by its Id
+func (r *dbRefreshTokens) MarkUsed(ent *models.RefreshToken) (uint, error) {
+	ent.UsedAt = time.Now()
+
+	res, err := r.Pgdb.Model(ent).WherePK().Where("used_at IS NULL AND revoked_at IS NULL").Column("used_at").Update()
+	if err != nil || res == nil { return 0, translateErr(err) }
+
+	return uint(res.RowsAffected()), nil
+}
+
+// RevokeFamily revoke all the not yet revoked tokens of a family. Return the amount of revoked tokens
+//
+// - familyId [string] ~ Token family identifier
+func (r *dbRefreshTokens) RevokeFamily(familyId string) (uint, error) {
+	res, err := r.Pgdb.Model((*models.RefreshToken)(nil)).
+		Set("revoked_at = ?", time.Now()).
+		Where("family_id = ? AND revoked_at IS NULL", familyId).
+		Update()
+	if err != nil || res == nil { return 0, translateErr(err) }
+
+	return uint(res.RowsAffected()), nil
+}
+
+// RevokeBySub revoke all the not yet revoked tokens of a user, from every login (family). Return the amount of
+// revoked tokens
+//
+// - sub [string] ~ Token subject, the user identifier
+func (r *dbRefreshTokens) RevokeBySub(sub string) (uint, error) {
+	res, err := r.Pgdb.Model((*models.RefreshToken)(nil)).
+		Set("revoked_at = ?", time.Now()).
+		Where("sub = ? AND revoked_at IS NULL", sub).
+		Update()
+	if err != nil || res == nil { return 0, translateErr(err) }
+
+	return uint(res.RowsAffected()), nil
+}
@@ -0,0 +1,116 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+
+	"go.api.backend/schema"
+)
+
+// uowMaxAttempts is the amount of times a unit of work is tried when it fails by a serialization failure or a
+// deadlock, that are expected in concurrent transactions and fixed by just running them again
+const uowMaxAttempts = 3
+
+// UnitOfWork run several repository operations atomically, in one database transaction
+type UnitOfWork interface {
+	Do(ctx context.Context, fn func(tx RepoTx) error) error
+}
+
+// RepoTx gives the repositories bound to the transaction of a unit of work
+type RepoTx interface {
+	Books() RepoDbBook
+	Audits() RepoDbAudit
+	Savepoint(fn func() error) error
+}
+
+type dbUnitOfWork struct {
+	Pgdb *pg.DB `*pg.DB:"Database connection object"`
+}
+
+type dbRepoTx struct {
+	tx *pg.Tx
+}
+
+// NewUnitOfWork creates a new unit of work, beginning its transactions in the given database
+//
+// - dbCtx [*pg.DB] ~ Database connection
+func NewUnitOfWork(dbCtx *pg.DB) UnitOfWork {
+	return &dbUnitOfWork{dbCtx}
+}
+
+// Do run fn inside a database transaction, passing it the repositories bound to the transaction. The transaction is
+// committed if fn returns nil, and rolled back if it returns an error or panics (the panic goes on after the
+// rollback). If the transaction fails by a serialization failure or a deadlock then it's retried from the start,
+// up to uowMaxAttempts times, so fn may run more than once and must not have side effects outside the transaction.
+// The fn error is returned as is
+//
+// - ctx [context.Context] ~ Context for the transaction, e.g. with a timeout
+//
+// - fn [func(tx RepoTx) error] ~ Operations to be run atomically
+func (u *dbUnitOfWork) Do(ctx context.Context, fn func(tx RepoTx) error) error {
+	for attempt := 1; ; attempt++ {
+		err := u.run(ctx, fn)
+		if err == nil || attempt == uowMaxAttempts || !isRetryable(err) { return err }
+
+		// Small jittered backoff, so the competing transactions don't collide again
+		select {
+		case <-ctx.Done(): return err
+		case <-time.After(time.Duration(attempt * 10 + rand.Intn(10)) * time.Millisecond):
+		}
+	}
+}
+
+// run a single attempt of the unit of work, see Do
+func (u *dbUnitOfWork) run(ctx context.Context, fn func(tx RepoTx) error) error {
+	tx, err := u.Pgdb.BeginContext(ctx)
+	if err != nil { return translateErr(err) }
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.RollbackContext(ctx)
+			panic(p)
+		}
+	}()
+
+	if err := fn(&dbRepoTx{tx}); err != nil {
+		_ = tx.RollbackContext(ctx)
+		return err
+	}
+
+	return translateErr(tx.CommitContext(ctx))
+}
+
+// Books get the books repository bound to the transaction
+func (t *dbRepoTx) Books() RepoDbBook { return NewRepoDbBook(t.tx) }
+
+// Audits get the audit log repository bound to the transaction
+func (t *dbRepoTx) Audits() RepoDbAudit { return NewRepoDbAudit(t.tx) }
+
+// Savepoint run fn inside a savepoint of the transaction, so if fn fails only its changes are rolled back and the
+// transaction can go on (a failed statement aborts the whole Postgres transaction otherwise). It returns the fn error
+//
+// - fn [func() error] ~ Operations to be run, using the repositories of this same transaction
+func (t *dbRepoTx) Savepoint(fn func() error) error {
+	if _, err := t.tx.Exec("SAVEPOINT uow_sp"); err != nil { return translateErr(err) }
+
+	if err := fn(); err != nil {
+		if _, e := t.tx.Exec("ROLLBACK TO SAVEPOINT uow_sp"); e != nil { return translateErr(e) }
+		return err
+	}
+
+	_, err := t.tx.Exec("RELEASE SAVEPOINT uow_sp")
+	return translateErr(err)
+}
+
+// isRetryable tells if the error is a Postgres serialization failure or deadlock, so the transaction can be retried
+func isRetryable(err error) bool {
+	var pgErr pg.Error
+	if !errors.As(err, &pgErr) { return false }
+
+	code := pgErr.Field('C')
+	return code == schema.StrPgSerializationFailure || code == schema.StrPgDeadlock
+}
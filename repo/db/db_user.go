@@ -0,0 +1,67 @@
+package db
+
+import (
+	"github.com/go-pg/pg/v10/orm"
+	"go.api.backend/schema"
+	"go.api.backend/schema/errs"
+	"go.api.backend/schema/models"
+	"time"
+)
+
+type RepoDbUser interface {
+	GetByUsername(ent *models.User) error
+	Add(ent *models.User) error
+	Update(ent *models.User, columns ...string) (uint, error)
+}
+
+type dbUsers struct {
+	Pgdb orm.DB `orm.DB:"Database connection object, or a transaction (*pg.Tx)"`
+}
+
+// NewRepoDbUser creates a new users Database Repository instance
+func NewRepoDbUser(dbCtx orm.DB) RepoDbUser {
+	return &dbUsers{dbCtx}
+}
+
+// GetByUsername get an user by its username (case-insensitive). If no user found then err is an errs.NotFound
+//
+// - ent [*models.User] ~ A pointer to the holder entity struct, with the username to be found
+func (r *dbUsers) GetByUsername(ent *models.User) error {
+	return translateErr(r.Pgdb.Model(ent).Where("lower(username) = lower(?)", ent.Username).Select())
+}
+
+// Add an user to the repository. If the username already exist then err is an errs.Conflict.
+// If something occurs during the ops also err != nil.
+//
+// - ent [*models.User] ~ New user to be added to the repo
+func (r *dbUsers) Add(ent *models.User) error {
+	res, err := r.Pgdb.Model(ent).OnConflict("DO NOTHING").Insert()		// Single statement, so no race between check & insert
+
+	if err != nil {
+		return translateErr(err)								// Something happen
+	} else if res == nil || res.RowsAffected() == 0 {
+		return errs.New(errs.Conflict, schema.ErrDuplicateKey, schema.ErrDetDuplicateKey)
+	}
+
+	return nil
+}
+
+// Update the specified columns of the user (besides updated_at). The user is found by its Id.
+// If the user doesn't exist then err is an errs.NotFound
+//
+// - ent [*models.User] ~ User data to be updated
+//
+// - columns [...string] ~ Columns to be updated
+func (r *dbUsers) Update(ent *models.User, columns ...string) (uint, error) {
+
+	ent.UpdatedAt = time.Now()
+	res, err := r.Pgdb.Model(ent).WherePK().Column(append(columns, "updated_at")...).Update()
+
+	if err != nil {
+		return 0, translateErr(err)
+	} else if res != nil && res.RowsAffected() > 0 {
+		return 1, nil
+	} else {
+		return 0, errs.New(errs.NotFound, schema.ErrNotFound, schema.ErrDetNotFound) 	// 404
+	}
+}
@@ -0,0 +1,65 @@
+package mem
+
+import (
+	"sync"
+	"time"
+
+	"go.api.backend/repo/db"
+	"go.api.backend/schema/dto"
+	"go.api.backend/schema/models"
+)
+
+type memAudits struct {
+	mu    *sync.RWMutex `*sync.RWMutex:"State guard, nil inside a unit of work (it holds the lock)"`
+	state *auditState   `*auditState:"Stored audit entries"`
+}
+
+// auditState the stored audit entries, in insertion (Id) order
+type auditState struct {
+	entries []models.AuditEntry
+}
+
+// NewRepoMemAudit creates a new in memory audit log Repository instance, see db.RepoDbAudit. It's safe for
+// concurrent use
+func NewRepoMemAudit() db.RepoDbAudit {
+	return &memAudits{&sync.RWMutex{}, &auditState{}}
+}
+
+// Add an entry to the audit log. The entry gets its Id & creation time set
+//
+// - ent [*models.AuditEntry] ~ New audit entry
+func (r *memAudits) Add(ent *models.AuditEntry) error {
+	defer lock(r.mu)()
+
+	ent.Id, ent.CreatedAt = uint(len(r.state.entries)) + 1, time.Now()
+	r.state.entries = append(r.state.entries, *ent)
+
+	return nil
+}
+
+// GetAll get a page of audit entries matching the filter, newest first, see db.RepoDbAudit.GetAll
+//
+// - list [*[]models.AuditEntry] ~ A pointer to a slice for storing the query result
+//
+// - filter [*dto.AuditFilter] ~ Filtering criteria
+//
+// - opts [*dto.QueryOpts] ~ Offset pagination options, the sorting is always newest first
+func (r *memAudits) GetAll(list *[]models.AuditEntry, filter *dto.AuditFilter, opts *dto.QueryOpts) (int, error) {
+	defer rLock(r.mu)()
+
+	found := make([]models.AuditEntry, 0)
+	for i := len(r.state.entries) - 1; i >= 0; i-- {
+		e := r.state.entries[i]
+		if e.Entity == filter.Entity && (filter.EntityId == 0 || e.EntityId == filter.EntityId) { found = append(found, e) }
+	}
+
+	from, to := pageBounds(len(found), opts)
+	*list = found[from:to]
+
+	return len(found), nil
+}
+
+// clone copy the state, so it can be changed without touching the original
+func (s *auditState) clone() *auditState {
+	return &auditState{append([]models.AuditEntry(nil), s.entries...)}
+}
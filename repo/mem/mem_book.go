@@ -0,0 +1,457 @@
+package mem
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.api.backend/lib"
+	"go.api.backend/repo/db"
+	"go.api.backend/schema"
+	"go.api.backend/schema/dto"
+	"go.api.backend/schema/errs"
+	"go.api.backend/schema/models"
+)
+
+type memBooks struct {
+	mu    *sync.RWMutex `*sync.RWMutex:"State guard, nil inside a unit of work (it holds the lock)"`
+	state *bookState    `*bookState:"Stored books"`
+}
+
+// bookState the stored books, by Id, and the last given Id (the books sequence)
+type bookState struct {
+	books  map[uint]models.Book
+	lastId uint
+}
+
+// NewRepoMemBook creates a new in memory books Repository instance. It's safe for concurrent use and it reproduces
+// the semantics of the database one (see db.RepoDbBook): not found, duplicated names, versions, trash, etc. Handy
+// for testing and for running without a database
+func NewRepoMemBook() db.RepoDbBook {
+	return &memBooks{&sync.RWMutex{}, &bookState{books: make(map[uint]models.Book)}}
+}
+
+// GetAll get a page of books and set the result in the referenced (pointer) list (slice), see db.RepoDbBook.GetAll
+//
+// - list [*[]models.Book] ~ A pointer to a slice for storing the query result
+//
+// - opts [*dto.QueryOpts] ~ Pagination & sorting options
+//
+// - filter [*dto.BookFilter] ~ Filtering criteria
+func (r *memBooks) GetAll(list *[]models.Book, opts *dto.QueryOpts, filter *dto.BookFilter) (int, error) {
+	defer rLock(r.mu)()
+
+	found := make([]models.Book, 0)
+	for _, b := range r.state.books {
+		if matchBook(&b, filter) { found = append(found, b) }
+	}
+	total := len(found)
+
+	if opts.IsKeyset() {							// Keyset pagination, only the Id column is allowed for sorting
+		desc := false
+		for _, s := range opts.Sort {
+			if s.Column != "id" { return 0, errs.New(errs.Validation, schema.ErrVal, schema.ErrDetInvalidSort) }
+			desc = s.Desc
+		}
+
+		// Fetching backward (Before) means walking the Id in the opposite direction, then reversing the page
+		backward, cursor := opts.Before > 0, opts.After
+		if backward { cursor = opts.Before }
+		asc := backward == desc
+
+		page := make([]models.Book, 0)
+		for _, b := range found {
+			if (asc && b.Id > cursor) || (!asc && b.Id < cursor) { page = append(page, b) }
+		}
+		sort.Slice(page, func(i, j int) bool { return (page[i].Id < page[j].Id) == asc })
+
+		page = limitBooks(page, 0, opts.Limit)
+		if backward { reverseBooks(page) }
+
+		*list = page
+		return total, nil
+	}
+
+	// Offset pagination
+	for _, s := range opts.Sort {
+		if _, ok := bookColumns[s.Column]; !ok { return 0, errs.New(errs.Validation, schema.ErrVal, schema.ErrDetInvalidSort) }
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		for _, s := range opts.Sort {
+			c := bookColumns[s.Column](&found[i], &found[j])
+			if c == 0 { continue }
+			return (c < 0) != s.Desc
+		}
+		return found[i].Id < found[j].Id				// Id as tie breaker for a stable pagination
+	})
+
+	page := uint(1)
+	if opts.Page > 1 { page = opts.Page }
+
+	*list = limitBooks(found, (page - 1) * opts.Limit, opts.Limit)
+	return total, nil
+}
+
+// Search find the books matching all the words of a free text query, every word as a prefix, see
+// db.RepoDbBook.Search. The rank is the share of the name words that match
+//
+// - list [*[]models.BookHit] ~ A pointer to a slice for storing the hits
+//
+// - q [string] ~ Free text search query
+//
+// - opts [*dto.QueryOpts] ~ Offset pagination options, the sorting is always by relevance
+func (r *memBooks) Search(list *[]models.BookHit, q string, opts *dto.QueryOpts) (int, error) {
+	terms := lib.SearchTerms(q, schema.SearchMaxTerms)
+	if len(terms) == 0 { return 0, errs.New(errs.Validation, schema.ErrVal, schema.ErrDetInvalidSearch) }
+
+	defer rLock(r.mu)()
+
+	hits := make([]models.BookHit, 0)
+	for _, b := range r.state.books {
+		if !b.DeletedAt.IsZero() { continue }
+		if hit, ok := searchBook(&b, terms); ok { hits = append(hits, hit) }
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Rank != hits[j].Rank { return hits[i].Rank > hits[j].Rank }
+		return hits[i].Id < hits[j].Id
+	})
+
+	from, to := pageBounds(len(hits), opts)
+	*list = hits[from:to]
+	return len(hits), nil
+}
+
+// GetByID get a book by Id. If no book found (or it's in the trash) then err is an errs.NotFound
+//
+// - ent [*models.Book] ~ A pointer to the holder entity struct to be found
+func (r *memBooks) GetByID(ent *models.Book) error {
+	defer rLock(r.mu)()
+
+	b, ok := r.state.books[ent.Id]
+	if !ok || !b.DeletedAt.IsZero() { return errNotFound() }
+
+	*ent = b
+	return nil
+}
+
+// GetByIDForUpdate get a book by Id, see db.RepoDbBook.GetByIDForUpdate. The unit of work holds the repository lock
+// already, so it's the same than GetByID
+//
+// - ent [*models.Book] ~ A pointer to the holder entity struct to be found
+func (r *memBooks) GetByIDForUpdate(ent *models.Book) error {
+	return r.GetByID(ent)
+}
+
+// GetTrashedByID get a book in the trash by Id. If the book isn't in the trash then err is an errs.NotFound
+//
+// - ent [*models.Book] ~ A pointer to the holder entity struct to be found
+func (r *memBooks) GetTrashedByID(ent *models.Book) error {
+	defer rLock(r.mu)()
+
+	b, ok := r.state.books[ent.Id]
+	if !ok || b.DeletedAt.IsZero() { return errNotFound() }
+
+	*ent = b
+	return nil
+}
+
+// DelByID delete a book by Id, moving it to the trash, see db.RepoDbBook.DelByID
+//
+// - Id [*uint] ~ Id of the book to be deleted
+//
+// - version [uint] ~ Expected book version, 0 for deleting whatever the version is
+func (r *memBooks) DelByID(Id *uint, version uint) (uint, error) {
+	defer lock(r.mu)()
+
+	b, ok := r.state.books[*Id]
+	if !ok || !b.DeletedAt.IsZero() {
+		if version > 0 { return 0, errNotFound() }
+		return 0, nil
+	}
+	if version > 0 && b.Version != version { return 0, errPrecondition() }
+
+	b.DeletedAt = time.Now()
+	r.state.books[b.Id] = b
+
+	return 1, nil
+}
+
+// Add a book to the repository. If the book name already exist (case-insensitive, excluding the trash) then err is
+// an errs.Conflict. The book gets its Id, version & creation time set
+//
+// - ent [*models.Book] ~ New book to be added to the repo
+func (r *memBooks) Add(ent *models.Book) error {
+	defer lock(r.mu)()
+
+	if r.nameTaken(ent.Name, 0) { return errConflict() }
+
+	r.insert(ent)
+	return nil
+}
+
+// Update update a book with the giving schema, see db.RepoDbBook.Update
+//
+// - ent [*models.Book] ~ Book data to be updated, found by its Id. Its version is set to the new one
+//
+// - columns [...string] ~ Columns to be updated, name & items if none
+func (r *memBooks) Update(ent *models.Book, columns ...string) (uint, error) {
+	defer lock(r.mu)()
+
+	if len(columns) == 0 { columns = []string{"name", "items"} }
+
+	b, ok := r.state.books[ent.Id]
+	if !ok || !b.DeletedAt.IsZero() { return 0, errNotFound() }
+	if ent.Version > 0 && b.Version != ent.Version { return 0, errPrecondition() }
+
+	for _, c := range columns {
+		switch c {
+		case "name":
+			if r.nameTaken(ent.Name, b.Id) { return 0, errConflict() }
+			b.Name = ent.Name
+		case "items":
+			b.Items = ent.Items
+		default:
+			return 0, errs.New(errs.Internal, schema.ErrRepositoryOps, "unknown column " + c)
+		}
+	}
+
+	b.Version++
+	b.UpdatedAt = time.Now()
+	r.state.books[b.Id] = b
+
+	ent.Version, ent.UpdatedAt = b.Version, b.UpdatedAt
+	return 1, nil
+}
+
+// Restore take a book out of the trash, setting the entity with the restored book, see db.RepoDbBook.Restore
+//
+// - ent [*models.Book] ~ A pointer to the holder entity struct, with the Id of the book to be restored
+func (r *memBooks) Restore(ent *models.Book) error {
+	defer lock(r.mu)()
+
+	b, ok := r.state.books[ent.Id]
+	if !ok || b.DeletedAt.IsZero() { return errNotFound() }
+	if r.nameTaken(b.Name, b.Id) { return errConflict() }
+
+	b.DeletedAt = time.Time{}
+	r.state.books[b.Id] = b
+
+	*ent = b
+	return nil
+}
+
+// PurgeByID delete for good a book in the trash. uint > 0 if the book was purged, otherwise if 0 and no error
+// then it isn't in the trash (404)
+//
+// - Id [*uint] ~ Id of the book to be purged
+func (r *memBooks) PurgeByID(Id *uint) (uint, error) {
+	defer lock(r.mu)()
+
+	b, ok := r.state.books[*Id]
+	if !ok || b.DeletedAt.IsZero() { return 0, nil }
+
+	delete(r.state.books, b.Id)
+	return 1, nil
+}
+
+// PurgeTrash delete for good up to limit books of the trash, the oldest Ids first, see db.RepoDbBook.PurgeTrash
+//
+// - purged [*[]models.Book] ~ A pointer to the slice getting the purged books
+//
+// - limit [uint] ~ Maximum amount of books to be purged
+func (r *memBooks) PurgeTrash(purged *[]models.Book, limit uint) (uint, error) {
+	defer lock(r.mu)()
+
+	ids := make([]uint, 0)
+	for id, b := range r.state.books {
+		if !b.DeletedAt.IsZero() { ids = append(ids, id) }
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	if uint(len(ids)) > limit { ids = ids[:limit] }
+
+	for _, id := range ids {
+		*purged = append(*purged, r.state.books[id])
+		delete(r.state.books, id)
+	}
+
+	return uint(len(ids)), nil
+}
+
+// ForEach run fn for every book (excluding the trash) in Id order. fn gets a snapshot, so it can use the
+// repository. An fn error stops the iteration and it's returned
+//
+// - fn [func(book *models.Book) error] ~ Function receiving every book
+func (r *memBooks) ForEach(fn func(book *models.Book) error) error {
+	list := make([]models.Book, 0)
+	_, err := r.GetAll(&list, &dto.QueryOpts{Limit: 0}, nil)
+	if err != nil { return err }
+
+	for i := range list {
+		if err := fn(&list[i]); err != nil { return err }
+	}
+
+	return nil
+}
+
+// Upsert create a book or, if there is already a book with the same name (case-insensitive, excluding the trash),
+// update its items. It tells if the book was created (true) or updated, and set the entity Id & version
+//
+// - ent [*models.Book] ~ Book to be created or updated
+//
+// - before [*models.Book] ~ If not nil, it's set with the existing book as it was before the update
+func (r *memBooks) Upsert(ent *models.Book, before *models.Book) (bool, error) {
+	defer lock(r.mu)()
+
+	for _, b := range r.state.books {
+		if !b.DeletedAt.IsZero() || !strings.EqualFold(b.Name, ent.Name) { continue }
+		if before != nil { *before = b }
+
+		b.Items, b.Version, b.UpdatedAt = ent.Items, b.Version + 1, time.Now()
+		r.state.books[b.Id] = b
+
+		ent.Id, ent.Version = b.Id, b.Version
+		return false, nil
+	}
+
+	r.insert(ent)
+	return true, nil
+}
+
+// region ======== HELPERS ===============================================================
+
+// bookColumns the sortable books columns, with their comparison function (-1, 0, 1)
+var bookColumns = map[string]func(a, b *models.Book) int {
+	"id":         func(a, b *models.Book) int { return cmpUint(a.Id, b.Id) },
+	"name":       func(a, b *models.Book) int { return strings.Compare(a.Name, b.Name) },
+	"items":      func(a, b *models.Book) int { return cmpUint(a.Items, b.Items) },
+	"version":    func(a, b *models.Book) int { return cmpUint(a.Version, b.Version) },
+	"created_at": func(a, b *models.Book) int { return cmpTime(a.CreatedAt, b.CreatedAt) },
+	"updated_at": func(a, b *models.Book) int { return cmpTime(a.UpdatedAt, b.UpdatedAt) },
+	"deleted_at": func(a, b *models.Book) int { return cmpTime(a.DeletedAt, b.DeletedAt) },
+}
+
+// searchBook tells if every term is a prefix of some of the book name words, and if so it makes the book hit, with
+// the matching words highlighted
+func searchBook(b *models.Book, terms []string) (models.BookHit, bool) {
+	words := lib.SearchTerms(b.Name, 0)
+	matched := make(map[string]bool)
+
+	for _, t := range terms {
+		found := false
+		for _, w := range words {
+			if strings.HasPrefix(w, t) { matched[w], found = true, true }
+		}
+		if !found { return models.BookHit{}, false }
+	}
+
+	snippet := lib.SearchWord.ReplaceAllStringFunc(b.Name, func(w string) string {
+		if matched[strings.ToLower(w)] { return lib.HighlightStart + w + lib.HighlightStop }
+		return w
+	})
+
+	return models.BookHit{Book: *b, Rank: float32(len(matched)) / float32(len(words)), Snippet: snippet}, true
+}
+
+// lock take the write lock, returning the function releasing it. Inside a unit of work (nil lock) it's a no-op
+func lock(mu *sync.RWMutex) func() {
+	if mu == nil { return func() {} }
+
+	mu.Lock()
+	return mu.Unlock
+}
+
+// rLock take the read lock, returning the function releasing it. Inside a unit of work (nil lock) it's a no-op
+func rLock(mu *sync.RWMutex) func() {
+	if mu == nil { return func() {} }
+
+	mu.RLock()
+	return mu.RUnlock
+}
+
+// pageBounds get the [from:to) bounds of the page of a n items listing, for the offset pagination options
+func pageBounds(n int, opts *dto.QueryOpts) (int, int) {
+	page := uint(1)
+	if opts.Page > 1 { page = opts.Page }
+
+	from, to := int((page - 1) * opts.Limit), int(page * opts.Limit)
+	if from > n { from = n }
+	if to > n { to = n }
+
+	return from, to
+}
+
+// nameTaken tells if a book, other than the given one, is using the name (case-insensitive, excluding the trash)
+func (r *memBooks) nameTaken(name string, except uint) bool {
+	for _, b := range r.state.books {
+		if b.Id != except && b.DeletedAt.IsZero() && strings.EqualFold(b.Name, name) { return true }
+	}
+
+	return false
+}
+
+// insert store a new book, giving it the next Id, the first version & the creation time
+func (r *memBooks) insert(ent *models.Book) {
+	r.state.lastId++
+	ent.Id, ent.Version, ent.CreatedAt = r.state.lastId, 1, time.Now()
+	ent.UpdatedAt, ent.DeletedAt = time.Time{}, time.Time{}
+
+	r.state.books[ent.Id] = *ent
+}
+
+// clone copy the state, so it can be changed without touching the original
+func (s *bookState) clone() *bookState {
+	c := &bookState{books: make(map[uint]models.Book, len(s.books)), lastId: s.lastId}
+	for id, b := range s.books { c.books[id] = b }
+
+	return c
+}
+
+// matchBook tells if the book fulfill the filtering criteria. Only the books in the trash are matched if the filter
+// asks for them, otherwise they are excluded
+func matchBook(b *models.Book, filter *dto.BookFilter) bool {
+	if filter == nil { return b.DeletedAt.IsZero() }
+	if filter.Deleted == b.DeletedAt.IsZero() { return false }
+
+	if filter.Name != "" && !strings.Contains(strings.ToLower(b.Name), strings.ToLower(filter.Name)) { return false }
+	if filter.ItemsMin != nil && b.Items < *filter.ItemsMin { return false }
+	if filter.ItemsMax != nil && b.Items > *filter.ItemsMax { return false }
+
+	return true
+}
+
+// limitBooks get the books page starting at offset, up to limit books (0 means no limit)
+func limitBooks(list []models.Book, offset uint, limit uint) []models.Book {
+	if offset >= uint(len(list)) { return make([]models.Book, 0) }
+
+	list = list[offset:]
+	if limit > 0 && limit < uint(len(list)) { list = list[:limit] }
+
+	return list
+}
+
+// reverseBooks reverse in place the given books slice
+func reverseBooks(list []models.Book) {
+	for i, j := 0, len(list) - 1; i < j; i, j = i + 1, j - 1 {
+		list[i], list[j] = list[j], list[i]
+	}
+}
+
+func cmpUint(a, b uint) int {
+	if a < b { return -1 } else if a > b { return 1 }
+	return 0
+}
+
+func cmpTime(a, b time.Time) int {
+	if a.Before(b) { return -1 } else if a.After(b) { return 1 }
+	return 0
+}
+
+func errNotFound() error { return errs.New(errs.NotFound, schema.ErrNotFound, schema.ErrDetNotFound) }
+func errConflict() error { return errs.New(errs.Conflict, schema.ErrDuplicateKey, schema.ErrDetDuplicateKey) }
+func errPrecondition() error {
+	return errs.New(errs.PreconditionFailed, schema.ErrPrecondition, schema.ErrDetPrecondition)
+}
+// endregion =============================================================================
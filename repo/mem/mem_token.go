@@ -0,0 +1,107 @@
+package mem
+
+import (
+	"sync"
+	"time"
+
+	"go.api.backend/repo/db"
+	"go.api.backend/schema/models"
+)
+
+type memRefreshTokens struct {
+	mu     *sync.RWMutex          `*sync.RWMutex:"Tokens guard"`
+	tokens []models.RefreshToken `[]models.RefreshToken:"Stored tokens, in insertion (Id) order"`
+}
+
+// NewRepoMemRefreshToken creates a new in memory refresh tokens Repository instance, see db.RepoDbRefreshToken.
+// It's safe for concurrent use
+func NewRepoMemRefreshToken() db.RepoDbRefreshToken {
+	return &memRefreshTokens{mu: &sync.RWMutex{}}
+}
+
+// GetByHash get a refresh token by its hash. If no token found then err is an errs.NotFound
+//
+// - ent [*models.RefreshToken] ~ A pointer to the holder entity struct, with the token hash to be found
+func (r *memRefreshTokens) GetByHash(ent *models.RefreshToken) error {
+	defer rLock(r.mu)()
+
+	for _, tk := range r.tokens {
+		if tk.TokenHash == ent.TokenHash {
+			*ent = tk
+			ent.Scope = append([]string(nil), tk.Scope...)
+			return nil
+		}
+	}
+
+	return errNotFound()
+}
+
+// Add a refresh token to the repository. A duplicated hash is an errs.Conflict
+//
+// - ent [*models.RefreshToken] ~ New refresh token to be added to the repo
+func (r *memRefreshTokens) Add(ent *models.RefreshToken) error {
+	defer lock(r.mu)()
+
+	for _, tk := range r.tokens {
+		if tk.TokenHash == ent.TokenHash { return errConflict() }
+	}
+
+	ent.Id, ent.CreatedAt = uint(len(r.tokens)) + 1, time.Now()
+	tk := *ent
+	tk.Scope = append([]string(nil), ent.Scope...)
+	r.tokens = append(r.tokens, tk)
+
+	return nil
+}
+
+// MarkUsed set the token as used (rotated), see db.RepoDbRefreshToken.MarkUsed
+//
+// - ent [*models.RefreshToken] ~ Token to be marked, found by its Id
+func (r *memRefreshTokens) MarkUsed(ent *models.RefreshToken) (uint, error) {
+	defer lock(r.mu)()
+
+	ent.UsedAt = time.Now()
+	for i := range r.tokens {
+		if tk := &r.tokens[i]; tk.Id == ent.Id && tk.UsedAt.IsZero() && tk.RevokedAt.IsZero() {
+			tk.UsedAt = ent.UsedAt
+			return 1, nil
+		}
+	}
+
+	return 0, nil
+}
+
+// RevokeFamily revoke all the not yet revoked tokens of a family. Return the amount of revoked tokens
+//
+// - familyId [string] ~ Token family identifier
+func (r *memRefreshTokens) RevokeFamily(familyId string) (uint, error) {
+	defer lock(r.mu)()
+
+	var n uint
+	for i := range r.tokens {
+		if tk := &r.tokens[i]; tk.FamilyId == familyId && tk.RevokedAt.IsZero() {
+			tk.RevokedAt = time.Now()
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// RevokeBySub revoke all the not yet revoked tokens of a user, from every login (family). Return the amount of
+// revoked tokens
+//
+// - sub [string] ~ Token subject, the user identifier
+func (r *memRefreshTokens) RevokeBySub(sub string) (uint, error) {
+	defer lock(r.mu)()
+
+	var n uint
+	for i := range r.tokens {
+		if tk := &r.tokens[i]; tk.Sub == sub && tk.RevokedAt.IsZero() {
+			tk.RevokedAt = time.Now()
+			n++
+		}
+	}
+
+	return n, nil
+}
@@ -0,0 +1,68 @@
+package mem
+
+import (
+	"context"
+
+	"go.api.backend/repo/db"
+)
+
+type memUnitOfWork struct {
+	books  *memBooks
+	audits *memAudits
+}
+
+type memRepoTx struct {
+	books  *memBooks
+	audits *memAudits
+}
+
+// NewUnitOfWork creates a new in memory unit of work (see db.UnitOfWork) over the given in memory repositories.
+// The units of work are serialized (they hold the repositories locks) and run over a copy of the data, that replace
+// the original one only if the unit of work succeed. It panics if the repositories aren't in memory ones
+//
+// - pBooks [*db.RepoDbBook] ~ In memory books repository (see NewRepoMemBook) instance pointer
+//
+// - pAudits [*db.RepoDbAudit] ~ In memory audit log repository (see NewRepoMemAudit) instance pointer
+func NewUnitOfWork(pBooks *db.RepoDbBook, pAudits *db.RepoDbAudit) db.UnitOfWork {
+	return &memUnitOfWork{(*pBooks).(*memBooks), (*pAudits).(*memAudits)}
+}
+
+// Do run fn over a copy of the data, committing it if fn returns nil. An fn error or panic leaves the data
+// untouched. There are no serialization failures in memory, so fn runs once
+//
+// - ctx [context.Context] ~ Context for the unit of work, a done one fails before running fn
+//
+// - fn [func(tx db.RepoTx) error] ~ Operations to be run atomically
+func (u *memUnitOfWork) Do(ctx context.Context, fn func(tx db.RepoTx) error) error {
+	if err := ctx.Err(); err != nil { return err }
+
+	defer lock(u.books.mu)()						// Always in the same order, so there are no deadlocks
+	defer lock(u.audits.mu)()
+
+	// No locks inside, they are already held
+	tx := &memRepoTx{&memBooks{state: u.books.state.clone()}, &memAudits{state: u.audits.state.clone()}}
+	if err := fn(tx); err != nil { return err }
+
+	u.books.state, u.audits.state = tx.books.state, tx.audits.state
+	return nil
+}
+
+// Books get the books repository bound to the unit of work
+func (t *memRepoTx) Books() db.RepoDbBook { return t.books }
+
+// Audits get the audit log repository bound to the unit of work
+func (t *memRepoTx) Audits() db.RepoDbAudit { return t.audits }
+
+// Savepoint run fn, restoring the data as it was before if it fails. It returns the fn error
+//
+// - fn [func() error] ~ Operations to be run, using the repositories of this same unit of work
+func (t *memRepoTx) Savepoint(fn func() error) error {
+	books, audits := t.books.state.clone(), t.audits.state.clone()
+
+	if err := fn(); err != nil {
+		*t.books.state, *t.audits.state = *books, *audits
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package mem
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"go.api.backend/repo/db"
+	"go.api.backend/schema/models"
+)
+
+type memUsers struct {
+	mu    *sync.RWMutex  `*sync.RWMutex:"Users guard"`
+	users []models.User `[]models.User:"Stored users, in insertion (Id) order"`
+}
+
+// NewRepoMemUser creates a new in memory users Repository instance, see db.RepoDbUser. It's safe for concurrent use
+func NewRepoMemUser() db.RepoDbUser {
+	return &memUsers{mu: &sync.RWMutex{}}
+}
+
+// GetByUsername get an user by its username (case-insensitive). If no user found then err is an errs.NotFound
+//
+// - ent [*models.User] ~ A pointer to the holder entity struct, with the username to be found
+func (r *memUsers) GetByUsername(ent *models.User) error {
+	defer rLock(r.mu)()
+
+	for _, u := range r.users {
+		if strings.EqualFold(u.Username, ent.Username) {
+			*ent = copyUser(u)
+			return nil
+		}
+	}
+
+	return errNotFound()
+}
+
+// Add an user to the repository. If the username already exist then err is an errs.Conflict
+//
+// - ent [*models.User] ~ New user to be added to the repo
+func (r *memUsers) Add(ent *models.User) error {
+	defer lock(r.mu)()
+
+	for _, u := range r.users {
+		if strings.EqualFold(u.Username, ent.Username) { return errConflict() }
+	}
+
+	ent.Id, ent.CreatedAt = uint(len(r.users)) + 1, time.Now()
+	r.users = append(r.users, copyUser(*ent))
+
+	return nil
+}
+
+// Update the specified columns of the user (besides updated_at). The user is found by its Id.
+// If the user doesn't exist then err is an errs.NotFound
+//
+// - ent [*models.User] ~ User data to be updated
+//
+// - columns [...string] ~ Columns to be updated
+func (r *memUsers) Update(ent *models.User, columns ...string) (uint, error) {
+	defer lock(r.mu)()
+
+	for i := range r.users {
+		u := &r.users[i]
+		if u.Id != ent.Id { continue }
+
+		src := copyUser(*ent)
+		for _, c := range columns {
+			switch c {
+			case "password_hash":
+				u.PasswordHash = src.PasswordHash
+			case "reset_token_hash":
+				u.ResetTokenHash = src.ResetTokenHash
+			case "reset_token_exp":
+				u.ResetTokenExp = src.ResetTokenExp
+			case "roles":
+				u.Roles = src.Roles
+			case "scopes":
+				u.Scopes = src.Scopes
+			}
+		}
+		ent.UpdatedAt = time.Now()
+		u.UpdatedAt = ent.UpdatedAt
+
+		return 1, nil
+	}
+
+	return 0, errNotFound()
+}
+
+// copyUser copy an user, without sharing the roles & scopes arrays
+func copyUser(u models.User) models.User {
+	u.Roles = append([]string(nil), u.Roles...)
+	u.Scopes = append([]string(nil), u.Scopes...)
+
+	return u
+}
@@ -14,7 +14,12 @@ const (
 	ErrJwtGen = "err.jwt_generation"
 	ErrWrongAuthProvider = "err.wrong_auth_provider"
 	ErrUnauthorized = "err.unauthorized"
+	ErrForbidden = "err.forbidden"
 	ErrVal = "err.invalid_data"
+	ErrMediaType = "err.unsupported_media_type"
+	ErrPrecondition = "err.precondition_failed"
+	ErrUpstreamUnavailable = "err.upstream_unavailable"
+	ErrUpstreamConfig = "err.upstream_config"
 )
 // endregion =============================================================================
 
@@ -27,6 +32,85 @@ const (
 	ErrDetInvalidType     = "invalid interface type (type assertion)"
 	ErrDetInvalidCred     = "something was wrong with the provided user credentials"
 	ErrDetInvalidProvider = "wrong or invalid provider"
+	ErrDetInvalidSort     = "invalid sorting column or keyset pagination combined with a non Id sorting"
+	ErrDetInvalidResetTk  = "invalid or expired password reset token"
+	ErrDetInvalidRefreshTk = "invalid, expired or revoked refresh token"
+	ErrDetNoClaims        = "there is no verified access token claims in the request"
+	ErrDetForbidden       = "the access token lacks the required scopes or roles"
+	ErrDetInvalidPatch    = "the patch can't be applied or the patched book is invalid"
+	ErrDetBatchRolledBack = "some items failed, the atomic batch was rolled back"
+	ErrDetInvalidSearch   = "the search query has no searchable words (letters or digits)"
+	ErrDetUpstreamUnavailable = "the upstream service (e.g. an auth provider) is unavailable"
+	ErrDetUpstreamConfig  = "the upstream service (e.g. an auth provider) rejected the app credentials, check its configuration"
+	ErrDetNoPasswordGrant = "the provider doesn't support the password grant, use its login redirect (/auth/{provider}/login)"
+	ErrDetNoRedirectLogin = "the provider doesn't support the login redirect (authorization code flow)"
+	ErrDetInvalidLoginState = "invalid, expired or already used login state"
+	ErrDetInvalidAuthCode = "the authorization code was rejected by the provider"
+	ErrDetInvalidIdToken  = "invalid ID token"
+	ErrDetPrecondition    = "the resource was modified meanwhile, its entity tag (ETag) doesn't match the If-Match one"
+)
+// endregion =============================================================================
+
+
+// region ======== ROLES & SCOPES ========================================================
+const (
+	RolUser  = "user"  // Default role for the users registered through the default (database) auth provider
+	RolAdmin = "admin"
+)
+
+const (
+	ScopeBooksWrite = "books:write" // Create, update and delete books
+)
+
+const (
+	// Auth providers login flows
+	AuthFlowPassword = "password" // Credentials POSTed to /auth/{provider}
+	AuthFlowRedirect = "redirect" // Login on the provider page, through /auth/{provider}/login
+)
+
+const (
+	// Read routes access (ReadAccess setting)
+	ReadAccessPublic = "public" // Anyone can read
+	ReadAccessToken  = "token"  // A valid access token is required
+)
+// endregion =============================================================================
+
+
+// region ======== PAGINATION ============================================================
+const (
+	PageDefLimit = 20  // Default page size for the listings
+	PageMaxLimit = 100 // Max page size allowed for the listings
+	SearchMaxTerms = 8 // Max amount of words of a full-text search query, the rest are ignored
+)
+// endregion =============================================================================
+
+
+// region ======== BATCHES ===============================================================
+const (
+	// Batch items outcomes
+	BatchCreated    = "created"
+	BatchUpdated    = "updated"
+	BatchDeleted    = "deleted"
+	BatchDuplicate  = "duplicate"
+	BatchInvalid    = "invalid"
+	BatchNotFound   = "not_found"
+	BatchFailed     = "failed"
+	BatchRolledBack = "rolled_back"				// The item was fine, but the atomic batch was rolled back
+)
+// endregion =============================================================================
+
+
+// region ======== AUDIT =================================================================
+const (
+	// Audited actions
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"				// Moved to the trash
+	AuditRestore = "restore"
+	AuditPurge   = "purge"
+
+	AuditEntityBook  = "book"
+	AuditSystemActor = "system"			// Actor of the mutations done without an access token (e.g. the CLI)
 )
 // endregion =============================================================================
 
@@ -34,6 +118,7 @@ const (
 // region ======== SOME STRINGS ==========================================================
 const (
 	StrPgDuplicateKey = "23505" // Postgres error code for duplicate key
-	StrDB404 = "no rows"
+	StrPgSerializationFailure = "40001" // Postgres error code for a serialization failure (concurrent transactions)
+	StrPgDeadlock = "40P01" // Postgres error code for a detected deadlock
 )
 // endregion =============================================================================
\ No newline at end of file
@@ -2,7 +2,6 @@ package database
 
 import (
 	"context"
-	"database/sql"
 	"fmt"
 	"github.com/rubenv/sql-migrate"
 
@@ -33,6 +32,10 @@ func Bootstrap(c *utils.SvcConfig) *pg.DB {
 func CreateSchema(db *pg.DB, testing bool) {
 	schemas := []interface{} {
 		(*models.Book)(nil),
+		(*models.User)(nil),
+		(*models.RefreshToken)(nil),
+		(*models.BlockedToken)(nil),
+		(*models.AuditEntry)(nil),
 	}
 
 	for _, model := range schemas {
@@ -47,39 +50,56 @@ func CreateSchema(db *pg.DB, testing bool) {
 	}
 }
 
-// MkMigrations run the last migrations in a set.
+// MkMigrations run the last migrations in a set. It panics if something goes wrong, see AutoMigrate for a
+// safer alternative when several app replicas may be starting at the same time.
 func MkMigrations(c *utils.SvcConfig) {
 
 	// For migrations we are using https://github.com/rubenv/sql-migrate. There is alternatives like
 	// https://github.com/go-pg/migrations or https://github.com/golang-migrate/. ❗ An important thing to note is
 	// that we are not use migration for the initial tables / schemas creation. For that matter we have go-pg
-	// CreateSchema method. We can can create an endpoint http handler to create migration Up, Down
-	// migrations methods (see https://github.com/rubenv/sql-migrate#usage)
+	// CreateSchema method. The migrations can also be managed from the command line, see RunMigrateCmd
+	// (https://github.com/rubenv/sql-migrate#usage)
 
 	// Making db connection. ❗ Notice that we use database/sql because migration packages use it.
 	// So we can't use go-pg connection instance for talk with the database
-	pgCnxInfo := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable", c.Host, c.Port, c.User, c.DbPass, c.Database)
-
-	pgdb, e := sql.Open("postgres", pgCnxInfo)
+	pgdb, e := openSqlDb(c)
 	if e != nil { panic(e) }
 
 	// Making sure db disconnection just before method return / exit
 	defer pgdb.Close()
 
-	// Check
-	if err := pgdb.Ping(); err != nil { panic(err) }
-
-	// So far so good, setup migration source
-	migrations := &migrate.FileMigrationSource{
-		Dir: c.MigrationDir,
-	}
-
 	// Run the migrations
-	n, err := migrate.Exec(pgdb, "postgres", migrations, migrate.Up)
+	n, err := migrate.Exec(pgdb, "postgres", migrationSource(c), migrate.Up)
 	if err != nil { panic(err) }
 
 	fmt.Printf("Applied %d migrations!\n", n)
 
 	// Note that n can be greater than 0 even if there is an error: any migration that succeeded will remain
 	// applied even if a later one fails.
-}
\ No newline at end of file
+}
+
+// PendingMigrations tells the amount of migrations in the migrations directory that are not applied yet.
+// It reads the sql-migrate records table through the go-pg connection.
+//
+// - ctx [context.Context] ~ Context for the query, e.g. with a timeout
+//
+// - c [*utils.SvcConfig] ~ App conf instance pointer
+//
+// - pgdb [*pg.DB] ~ Postgres database instance
+func PendingMigrations(ctx context.Context, c *utils.SvcConfig, pgdb *pg.DB) (int, error) {
+	migrations, err := migrationSource(c).FindMigrations()
+	if err != nil { return 0, err }
+
+	var applied pg.Strings
+	if _, err := pgdb.WithContext(ctx).Query(&applied, "SELECT id FROM gorp_migrations"); err != nil { return 0, err }
+
+	done := make(map[string]bool, len(applied))
+	for _, id := range applied { done[id] = true }
+
+	pending := 0
+	for _, m := range migrations {
+		if !done[m.Id] { pending++ }
+	}
+
+	return pending, nil
+}
@@ -0,0 +1,219 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/rubenv/sql-migrate"
+
+	"go.api.backend/service/utils"
+)
+
+// migrationsLockKey is the Postgres advisory lock key taken while migrating, so two replicas starting at the same
+// time don't race applying the same migrations
+const migrationsLockKey int64 = 7265367834
+
+// migrationTpl is the template for the new migrations files
+const migrationTpl = `-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+`
+
+// migrateUsage is the migrate subcommand help
+const migrateUsage = `usage: app [flags] migrate <command>
+
+commands:
+  up          create the schema (go-pg models) and apply all the pending migrations
+  down [N]    roll back the last N applied migrations (1 by default)
+  status      list the migrations and their state
+  new <name>  create a new empty migration file in the migrations directory`
+
+// AutoMigrate create the schema (see CreateSchema) and apply the pending migrations, holding a Postgres advisory
+// lock so only one app replica migrates at a time (the others wait and then find nothing to apply).
+// It returns the amount of applied migrations.
+//
+// - c [*utils.SvcConfig] ~ App conf instance pointer
+//
+// - pgdb [*pg.DB] ~ Postgres database instance, used for the schema creation
+func AutoMigrate(c *utils.SvcConfig, pgdb *pg.DB) (int, error) {
+	sqldb, err := openSqlDb(c)
+	if err != nil { return 0, err }
+	defer sqldb.Close()
+
+	n := 0
+	err = withMigrationsLock(sqldb, func() error {
+		CreateSchema(pgdb, false)
+
+		var e error
+		n, e = migrate.Exec(sqldb, "postgres", migrationSource(c), migrate.Up)
+		return e
+	})
+
+	return n, err
+}
+
+// RunMigrateCmd run the migrate subcommand (see migrateUsage), printing the result to the stdout.
+//
+// - c [*utils.SvcConfig] ~ App conf instance pointer
+//
+// - args [[]string] ~ Subcommand arguments, e.g. ["down", "2"]
+func RunMigrateCmd(c *utils.SvcConfig, args []string) error {
+	if len(args) == 0 { return errors.New(migrateUsage) }
+
+	switch args[0] {
+	case "up":
+		pgdb := Bootstrap(c)
+		defer pgdb.Close()
+
+		n, err := AutoMigrate(c, pgdb)
+		fmt.Printf("Applied %d migrations!\n", n)
+		return err
+
+	case "down":
+		max := 1
+		if len(args) > 1 {
+			var err error
+			if max, err = strconv.Atoi(args[1]); err != nil || max < 1 { return errors.New("down: N must be a positive integer") }
+		}
+
+		sqldb, err := openSqlDb(c)
+		if err != nil { return err }
+		defer sqldb.Close()
+
+		n := 0
+		err = withMigrationsLock(sqldb, func() error {
+			var e error
+			n, e = migrate.ExecMax(sqldb, "postgres", migrationSource(c), migrate.Down, max)
+			return e
+		})
+		fmt.Printf("Rolled back %d migrations!\n", n)
+		return err
+
+	case "status":
+		return printMigrationsStatus(c)
+
+	case "new":
+		if len(args) < 2 { return errors.New("new: the migration name is required") }
+
+		path, err := newMigrationFile(c, args[1])
+		if err == nil { fmt.Println("Created " + path) }
+		return err
+	}
+
+	return errors.New(migrateUsage)
+}
+
+// region ======== HELPERS ===============================================================
+
+// openSqlDb open a database/sql connection, the one the migration package works with
+func openSqlDb(c *utils.SvcConfig) (*sql.DB, error) {
+	sqldb, err := sql.Open("postgres", sqlDsn(c))
+	if err != nil { return nil, err }
+
+	if err := sqldb.Ping(); err != nil {
+		sqldb.Close()
+		return nil, err
+	}
+
+	return sqldb, nil
+}
+
+// sqlDsn get the connection URL of the database, the credentials are escaped (e.g. a password with spaces or quotes)
+func sqlDsn(c *utils.SvcConfig) string {
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(c.User, c.DbPass),
+		Host:     net.JoinHostPort(c.Host, c.Port),
+		Path:     "/" + c.Database,
+		RawQuery: "sslmode=disable",
+	}
+
+	return dsn.String()
+}
+
+// migrationSource the migrations files source, from the configured directory
+func migrationSource(c *utils.SvcConfig) *migrate.FileMigrationSource {
+	return &migrate.FileMigrationSource{Dir: c.MigrationDir}
+}
+
+// withMigrationsLock run fn holding the migrations advisory lock. The lock belongs to a database session, so it's
+// taken on a dedicated connection and released (unlock) on that same connection
+func withMigrationsLock(sqldb *sql.DB, fn func() error) error {
+	ctx := context.Background()
+
+	conn, err := sqldb.Conn(ctx)
+	if err != nil { return err }
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationsLockKey); err != nil { return err }
+	defer conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", migrationsLockKey)
+
+	return fn()
+}
+
+// printMigrationsStatus print a table with every migration and when it was applied, if so
+func printMigrationsStatus(c *utils.SvcConfig) error {
+	migrations, err := migrationSource(c).FindMigrations()
+	if err != nil { return err }
+
+	sqldb, err := openSqlDb(c)
+	if err != nil { return err }
+	defer sqldb.Close()
+
+	records, err := migrate.GetMigrationRecords(sqldb, "postgres")
+	if err != nil { return err }
+
+	applied := make(map[string]time.Time, len(records))
+	for _, r := range records { applied[r.Id] = r.AppliedAt }
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MIGRATION\tAPPLIED AT")
+	for _, m := range migrations {
+		at, ok := applied[m.Id]
+		if ok {
+			fmt.Fprintf(w, "%s\t%s\n", m.Id, at.Format(time.RFC3339))
+		} else {
+			fmt.Fprintf(w, "%s\t%s\n", m.Id, "pending")
+		}
+	}
+
+	return w.Flush()
+}
+
+// nonAlnum matches the characters not allowed in the migrations file names
+var nonAlnum = regexp.MustCompile(`[^a-z0-9]+`)
+
+// newMigrationFile create an empty migration file named <next number>_<name>.sql, returning its path
+func newMigrationFile(c *utils.SvcConfig, name string) (string, error) {
+	name = strings.Trim(nonAlnum.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" { return "", errors.New("new: invalid migration name") }
+
+	migrations, err := migrationSource(c).FindMigrations()
+	if err != nil { return "", err }
+
+	next := int64(1)
+	for _, m := range migrations {
+		if v := m.VersionInt(); v >= next { next = v + 1 }
+	}
+
+	path := filepath.Join(c.MigrationDir, fmt.Sprintf("%d_%s.sql", next, name))
+	return path, ioutil.WriteFile(path, []byte(migrationTpl), 0644)
+}
+// endregion =============================================================================
@@ -0,0 +1,21 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/lib/pq"
+
+	"go.api.backend/service/utils"
+)
+
+// The credentials with spaces, quotes or URL reserved characters don't break the connection string
+func TestSqlDsn(t *testing.T) {
+	c := &utils.SvcConfig{}
+	c.Host, c.Port, c.User, c.DbPass, c.Database = "db.local", "5432", "app user", `p@ss w'o/rd?#`, "books"
+
+	kv, err := pq.ParseURL(sqlDsn(c))
+	if err != nil { t.Fatal(err) }
+
+	want := `dbname='books' host='db.local' password='p@ss w\'o/rd?#' port='5432' sslmode='disable' user='app user'`
+	if kv != want { t.Fatalf("connection string = %s, want %s", kv, want) }
+}
@@ -0,0 +1,13 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- Refresh tokens tables created before the login provider was tracked. Their tokens have no provider so they are
+-- revoked, the users must login again
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS provider text NOT NULL DEFAULT '';
+UPDATE refresh_tokens SET revoked_at = now() WHERE provider = '' AND revoked_at IS NULL;
+CREATE INDEX IF NOT EXISTS refresh_token_sub_idx ON refresh_tokens (sub);
+
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP INDEX IF EXISTS refresh_token_sub_idx;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS provider;
@@ -6,4 +6,4 @@ CREATE UNIQUE INDEX book_name_idx ON books (lower (name));          -- Its also
 
 -- +migrate Down
 -- SQL section 'Down' is executed when this migration is rolled back
-DROP TABLE people;
+DROP INDEX IF EXISTS book_name_idx;
@@ -0,0 +1,9 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- The users table is created by the go-pg CreateSchema method, here we just make the username case-insensitive unique
+CREATE UNIQUE INDEX user_username_idx ON users (lower (username));
+
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP INDEX IF EXISTS user_username_idx;
@@ -0,0 +1,9 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- The refresh_tokens table is created by the go-pg CreateSchema method, here we just index the token families
+CREATE INDEX refresh_token_family_idx ON refresh_tokens (family_id);
+
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP INDEX IF EXISTS refresh_token_family_idx;
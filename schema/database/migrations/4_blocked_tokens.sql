@@ -0,0 +1,9 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- The blocked_tokens table is created by the go-pg CreateSchema method, here we just index the expiration for the purges
+CREATE INDEX blocked_token_expires_idx ON blocked_tokens (expires_at);
+
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP INDEX IF EXISTS blocked_token_expires_idx;
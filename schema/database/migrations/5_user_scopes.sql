@@ -0,0 +1,9 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- Users tables created before the scopes were introduced
+ALTER TABLE users ADD COLUMN IF NOT EXISTS scopes text[];
+
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+ALTER TABLE users DROP COLUMN IF EXISTS scopes;
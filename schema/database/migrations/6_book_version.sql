@@ -0,0 +1,9 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- Books tables created before the optimistic concurrency (ETag) was introduced
+ALTER TABLE books ADD COLUMN IF NOT EXISTS version bigint NOT NULL DEFAULT 1;
+
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+ALTER TABLE books DROP COLUMN IF EXISTS version;
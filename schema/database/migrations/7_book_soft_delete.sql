@@ -0,0 +1,18 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- Books tables created before the soft delete was introduced. The names must be unique only among the books that
+-- aren't in the trash, so the unique constraint (old go-pg CreateSchema) & index become a partial index
+ALTER TABLE books ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
+ALTER TABLE books DROP CONSTRAINT IF EXISTS books_name_key;
+DROP INDEX IF EXISTS book_name_idx;
+CREATE UNIQUE INDEX book_name_idx ON books (lower (name)) WHERE deleted_at IS NULL;
+CREATE INDEX book_deleted_at_idx ON books (deleted_at) WHERE deleted_at IS NOT NULL;
+
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP INDEX IF EXISTS book_deleted_at_idx;
+DROP INDEX IF EXISTS book_name_idx;
+DELETE FROM books WHERE deleted_at IS NOT NULL;
+CREATE UNIQUE INDEX book_name_idx ON books (lower (name));
+ALTER TABLE books DROP COLUMN IF EXISTS deleted_at;
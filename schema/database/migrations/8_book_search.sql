@@ -0,0 +1,15 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- ❗ Requires PostgreSQL 12 or later (generated columns)
+-- Full-text search document of the books, kept up to date by Postgres (generated column). The 'simple'
+-- configuration doesn't stem nor drop stop words, the names may be in any language. New text fields are added
+-- to the document with a lower weight (B, C, D), so the name matches keep ranking first
+ALTER TABLE books ADD COLUMN IF NOT EXISTS search tsvector
+    GENERATED ALWAYS AS (setweight(to_tsvector('simple', coalesce(name, '')), 'A')) STORED;
+CREATE INDEX book_search_idx ON books USING GIN (search);
+
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP INDEX IF EXISTS book_search_idx;
+ALTER TABLE books DROP COLUMN IF EXISTS search;
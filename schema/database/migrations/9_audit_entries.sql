@@ -0,0 +1,9 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+-- The audit_entries table is created by the go-pg CreateSchema method, here we just index the entity history lookup
+CREATE INDEX audit_entry_entity_idx ON audit_entries (entity, entity_id, id);
+
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP INDEX IF EXISTS audit_entry_entity_idx;
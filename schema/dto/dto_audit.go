@@ -0,0 +1,16 @@
+package dto
+
+// AuditListIn holds the query parameters for the audit log listing. Without Id, the entries of all the entities of
+// the given type are listed
+type AuditListIn struct {
+	Entity string `url:"entity" example:"book" validate:"required,oneof=book"`
+	Id     uint   `url:"id" example:"24"`
+	Page   uint   `url:"page" example:"1" validate:"omitempty,gte=1,lte=1000000"`
+	Limit  uint   `url:"limit" example:"20" validate:"omitempty,gte=1,lte=100"`
+}
+
+// AuditFilter the filtering criteria for the audit log listing
+type AuditFilter struct {
+	Entity   string
+	EntityId uint // 0 for any entity Id
+}
@@ -5,10 +5,39 @@ package dto
 // struct is in the endpoint parameters
 type UserCredIn struct {
 	Username string `example:"mynickname" validate:"required,ascii,gte=3,lte=60"`
-	Password string `example:"secret" validate:"required,ascii,gte=3,lte=20"`
+	Password string `example:"secret" validate:"required,ascii,gte=3,lte=72"`
 	Domain   string `example:"web" validate:"required,ascii,gte=3,lte=10"`
 }
 
+// UserRegisterIn new user data for the default (database) auth provider
+type UserRegisterIn struct {
+	Username string `example:"mynickname" validate:"required,alphanum,gte=3,lte=60"`
+	Password string `example:"my.secret.pass" validate:"required,ascii,gte=8,lte=72"`
+}
+
+// UserScopesIn scopes granted to an user of the default (database) auth provider, by an admin
+type UserScopesIn struct {
+	Scopes []string `example:"books:write" validate:"unique,dive,oneof=books:write"`
+}
+
+// PasswordChangeIn password change data for the logged user of the default (database) auth provider
+type PasswordChangeIn struct {
+	OldPassword string `example:"my.secret.pass" validate:"required,ascii,lte=72"`
+	NewPassword string `example:"my.new.secret.pass" validate:"required,ascii,gte=8,lte=72,nefield=OldPassword"`
+}
+
+// PasswordForgotIn request a password reset token for an user of the default (database) auth provider
+type PasswordForgotIn struct {
+	Username string `example:"mynickname" validate:"required,alphanum,gte=3,lte=60"`
+}
+
+// PasswordResetIn set a new password using a previously requested password reset token
+type PasswordResetIn struct {
+	Username    string `example:"mynickname" validate:"required,alphanum,gte=3,lte=60"`
+	Token       string `example:"3q2-7wEAbgnYCLf8fDSVYA3TW4A4PSAk" validate:"required,ascii,lte=100"`
+	NewPassword string `example:"my.new.secret.pass" validate:"required,ascii,gte=8,lte=72"`
+}
+
 //goland:noinspection GoSnakeCaseUsage
 type SISECGrantIntentIn struct {
 	Token_Type   string
@@ -22,6 +51,78 @@ type SisecAccessToken struct {
 	Scope string
 }
 
+// OAuthErrorIn is the body of an OAuth2 error response (RFC 6749 section 5.2), e.g. from SISEC or an OIDC provider
+//goland:noinspection GoSnakeCaseUsage
+type OAuthErrorIn struct {
+	Error             string `example:"invalid_grant"`
+	Error_Description string `example:"Bad credentials"`
+}
+
+// OIDCDiscoveryIn is the OpenID Connect discovery document of an issuer ({issuer}/.well-known/openid-configuration)
+//goland:noinspection GoSnakeCaseUsage
+type OIDCDiscoveryIn struct {
+	Issuer                 string
+	Authorization_Endpoint string
+	Token_Endpoint         string
+	Jwks_Uri               string
+}
+
+// OIDCTokenIn is the token endpoint response of an OpenID Connect authorization code exchange
+//goland:noinspection GoSnakeCaseUsage
+type OIDCTokenIn struct {
+	Token_Type   string
+	Access_Token string
+	Id_Token     string
+	Expires_In   int
+}
+
+// JWKSIn is a JSON Web Key Set (RFC 7517), e.g. the ID tokens verification keys of an OpenID Connect issuer
+type JWKSIn struct {
+	Keys []JWK
+}
+
+// JWKSOut is the JSON Web Key Set of the access tokens verification keys, for the downstream services
+type JWKSOut struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK is a JSON Web Key, only the public key members of the RSA, EC & OKP (Ed25519) key types
+type JWK struct {
+	Kty string `json:"kty" example:"RSA"`
+	Kid string `json:"kid,omitempty" example:"NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"`
+	Use string `json:"use,omitempty" example:"sig"`
+	Alg string `json:"alg,omitempty" example:"RS256"`
+	N   string `json:"n,omitempty"`   // RSA modulus
+	E   string `json:"e,omitempty"`   // RSA exponent
+	Crv string `json:"crv,omitempty"` // EC / OKP curve
+	X   string `json:"x,omitempty"`   // EC / OKP x coordinate
+	Y   string `json:"y,omitempty"`   // EC y coordinate
+}
+
+// OIDCUserIn is the user data taken from a verified OpenID Connect ID token
+type OIDCUserIn struct {
+	Sub   string
+	Roles []string
+	Scope []string
+}
+
+// AuthProviderOut is an enabled auth provider, for the login UIs
+type AuthProviderOut struct {
+	Name     string `example:"sisec"`
+	Type     string `example:"sisec"`
+	Label    string `example:"SISEC"`
+	Flow     string `example:"password"`	// password (credentials POSTed to the LoginUrl) | redirect (browser sent to the LoginUrl)
+	LoginUrl string `example:"/auth/sisec"`
+}
+
+// TokenOut is the response of a granted authentication or a refresh token rotation
+type TokenOut struct {
+	AccessToken  string `example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
+	RefreshToken string `example:"3q2-7wEAbgnYCLf8fDSVYA3TW4A4PSAkr1Gk8nvSDQ0"`
+	TokenType    string `example:"Bearer"`
+	ExpiresIn    uint   `example:"1500"` // Access token lifetime, in seconds
+}
+
 // AccessTokenData using by this REST Api (HLF client node) to grant access to the resources
 type AccessTokenData struct {
 	Scope  []string
@@ -0,0 +1,32 @@
+package dto
+
+// BatchOut is the outcome of a batch operation. Applied is false if the batch was atomic and some item failed, in
+// that case nothing was written
+type BatchOut struct {
+	Applied bool           `example:"true"`
+	Results []BatchItemOut
+}
+
+// BatchItemOut is the outcome of a single item of a batch operation
+type BatchItemOut struct {
+	Index  int    `example:"0"`                           // Item position in the request
+	Id     uint   `example:"24"`
+	Status string `example:"created"`                     // created | updated | deleted | duplicate | invalid | not_found | failed | rolled_back
+	Error  string `json:",omitempty" example:"err.duplicate_key"`
+	Detail string `json:",omitempty" example:"a unique resource field is duplicated"`
+}
+
+// ImportOut is the report of a file import. Only the failed rows are detailed
+type ImportOut struct {
+	Created int `example:"120"`
+	Updated int `example:"8"`
+	Failed  int `example:"1"`
+	Errors  []ImportRowOut
+}
+
+// ImportRowOut is a failed row of a file import
+type ImportRowOut struct {
+	Row    int    `example:"14"`                          // Line of the row in the file, 1-based (the CSV header is the line 1)
+	Error  string `example:"err.invalid_data"`
+	Detail string `json:",omitempty" example:"Key: 'BookCreateIn.Name' Error:Field validation for 'Name' failed on the 'gte' tag"`
+}
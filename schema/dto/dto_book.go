@@ -12,3 +12,56 @@ type BookCreateIn struct {
 	Name  string `example:"The Book of Eli" validate:"required,ascii,gte=3,lte=60"`
 	Items uint   `example:"46" validate:"required,number,gte=0,lte=130"`
 }
+
+// BookListIn holds the query parameters for the books listing. Sort is a comma separated list of columns,
+// prefixing a column with '-' means descending order, e.g. sort=-items,name
+type BookListIn struct {
+	Page     uint   `url:"page" example:"1" validate:"omitempty,gte=1,lte=1000000"`
+	Limit    uint   `url:"limit" example:"20" validate:"omitempty,gte=1,lte=100"`
+	After    uint   `url:"after" example:"24"`
+	Before   uint   `url:"before" example:"48" validate:"omitempty,excluded_with=After"`
+	Sort     string `url:"sort" example:"-items,name" validate:"omitempty,lte=120"`
+	Name     string `url:"name" example:"eli" validate:"omitempty,lte=60"`
+	ItemsMin *uint  `url:"items_min" example:"10"`
+	ItemsMax *uint  `url:"items_max" example:"50"`
+}
+
+// BookSearchIn holds the query parameters for the books full-text search. Every word of Q is matched as a prefix
+type BookSearchIn struct {
+	Q     string `url:"q" example:"hobb" validate:"required,lte=120"`
+	Page  uint   `url:"page" example:"1" validate:"omitempty,gte=1,lte=1000000"`
+	Limit uint   `url:"limit" example:"20" validate:"omitempty,gte=1,lte=100"`
+}
+
+// BookFilter the filtering criteria for the books listing
+type BookFilter struct {
+	Name     string // Case-insensitive substring
+	ItemsMin *uint
+	ItemsMax *uint
+	Deleted  bool // Only the books in the trash (soft deleted)
+}
+
+// BookBatchCreateIn is the body of the books batch creation. Every item is validated on its own, the invalid ones
+// are reported in the batch outcome
+type BookBatchCreateIn struct {
+	Atomic bool           `example:"true"` // All-or-nothing, otherwise best-effort
+	Items  []BookCreateIn `validate:"required,min=1,max=1000"`
+}
+
+// BookBatchUpdateIn is the body of the books batch update, see BookBatchCreateIn
+type BookBatchUpdateIn struct {
+	Atomic bool           `example:"true"`
+	Items  []BookUpdateIn `validate:"required,min=1,max=1000"`
+}
+
+// BookBatchDeleteIn is the body of the books batch deletion, see BookBatchCreateIn
+type BookBatchDeleteIn struct {
+	Atomic bool   `example:"false"`
+	Ids    []uint `validate:"required,min=1,max=1000,dive,gt=0" example:"24,25"`
+}
+
+// BookFileIn holds the query parameters for the books export & import, the file format. For the import it can be
+// omitted if the file extension tells it (.csv, .jsonl)
+type BookFileIn struct {
+	Format string `url:"format" example:"csv" validate:"omitempty,oneof=csv jsonl"`
+}
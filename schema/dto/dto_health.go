@@ -0,0 +1,15 @@
+package dto
+
+// HealthOut is the health (liveness / readiness) report of the app
+type HealthOut struct {
+	Status string                    `example:"up"` // up | down
+	Checks map[string]HealthCheckOut `json:",omitempty"` // Report per dependency, only for the readiness
+}
+
+// HealthCheckOut is the health report of a single dependency
+type HealthCheckOut struct {
+	Status   string `example:"up"` // up | down
+	Critical bool   `example:"true"` // A critical dependency down makes the app not ready
+	Latency  string `example:"1.2ms"`
+	Detail   string `example:"3 pending migrations"`
+}
@@ -0,0 +1,35 @@
+package dto
+
+// QueryOpts is the generic listing contract (pagination & sorting) shared by the repositories. Every repository
+// exposing a listing should honor it, so the handlers & services can treat all the collections the same way.
+//
+// Offset pagination is used when Page is set. Keyset (cursor based) pagination on the entity Id is used when
+// After or Before are set, in that case the Page field is ignored.
+type QueryOpts struct {
+	Page   uint        // 1-based page number, for offset pagination
+	Limit  uint        // Max amount of records per page
+	After  uint        // Keyset cursor, return the records placed after this Id in the listing order
+	Before uint        // Keyset cursor, return the records placed before this Id in the listing order
+	Sort   []SortField // Sorting columns, in priority order
+}
+
+// SortField a sorting criteria for a specific column
+type SortField struct {
+	Column string
+	Desc   bool
+}
+
+// IsKeyset tells if the query options are requesting keyset (cursor) pagination
+func (q *QueryOpts) IsKeyset() bool {
+	return q.After > 0 || q.Before > 0
+}
+
+// PageOut is the response envelope for the paginated listings
+type PageOut struct {
+	Data  interface{}
+	Total int    `example:"120"`
+	Page  uint   `example:"2"`
+	Limit uint   `example:"20"`
+	Next  string `example:"/books?limit=20&page=3"`
+	Prev  string `example:"/books?limit=20&page=1"`
+}
@@ -0,0 +1,82 @@
+package errs
+
+import (
+	"errors"
+)
+
+// region ======== TYPES =================================================================
+
+// Kind is the category of a domain error. The response service maps each kind to an HTTP status
+type Kind uint8
+
+const (
+	Internal     Kind = iota // Unexpected failure, e.g. a database connection error
+	NotFound                 // The requested entity doesn't exist
+	Conflict                 // A unique field is duplicated, or the entity state doesn't allow the operation
+	Validation               // The given data is invalid
+	Unauthorized             // Missing or wrong credentials / tokens
+	Forbidden                // The credentials are fine, but they lack the required permissions
+	Upstream                 // An external service (e.g. an auth provider) failed or is unreachable
+	PreconditionFailed       // A request precondition doesn't hold, e.g. the If-Match entity tag (optimistic concurrency)
+)
+
+// Error is a domain error. It carries its kind, the i18n key (see schema.Err*) for the clients, a detail for
+// humans, and optionally the underlying error
+type Error struct {
+	Kind   Kind
+	Key    string
+	Detail string
+	Err    error
+}
+
+func (e *Error) Error() string {
+	msg := e.Key
+	if e.Detail != "" { msg += ": " + e.Detail }
+	if e.Err != nil { msg += ": " + e.Err.Error() }
+
+	return msg
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+// endregion =============================================================================
+
+// New create a domain error.
+//
+// - kind [Kind] ~ Error category
+//
+// - key [string] ~ i18n error key, one of schema.Err*
+//
+// - detail [string] ~ Error detail, e.g. one of schema.ErrDet*
+func New(kind Kind, key string, detail string) *Error {
+	return &Error{Kind: kind, Key: key, Detail: detail}
+}
+
+// Wrap create a domain error around an underlying one, which is kept for errors.Is / errors.As. The detail is
+// the underlying error message.
+//
+// - kind [Kind] ~ Error category
+//
+// - key [string] ~ i18n error key, one of schema.Err*
+//
+// - err [error] ~ Underlying error
+func Wrap(kind Kind, key string, err error) *Error {
+	return &Error{Kind: kind, Key: key, Err: err}
+}
+
+// KindOf get the kind of an error. Errors that aren't domain errors (in any place of the chain) are Internal
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) { return e.Kind }
+
+	return Internal
+}
+
+// Is tells if the error is a domain error of the given kind
+//
+// - err [error] ~ Error to be checked, nil is never of any kind
+//
+// - kind [Kind] ~ Error category
+func Is(err error, kind Kind) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Kind == kind
+}
@@ -1,9 +1,12 @@
 package mapper
 
 import (
+	"go.api.backend/schema"
 	"go.api.backend/schema/dto"
 	"go.api.backend/schema/models"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // TIP ref https://hellokoding.com/crud-restful-apis-with-go-modules-wire-gin-gorm-and-mysql/
@@ -20,6 +23,91 @@ func ToBookCreateV(dto *dto.BookCreateIn) *models.Book {
 func ToBookUpdateV(dto *dto.BookUpdateIn) *models.Book {
 	return &models.Book{Id: dto.Id, Name: dto.Name, Items: dto.Items}
 }
+
+// ToBookPatchDocV map a models.Book to the document the PATCH patches are applied to, holding only the editable
+// fields. Its validation tags are the same than the create / update ones
+func ToBookPatchDocV(book *models.Book) *dto.BookCreateIn {
+	return &dto.BookCreateIn{Name: book.Name, Items: book.Items}
+}
+
+// ToBookPatchV apply a patched document (see ToBookPatchDocV) to the book, returning the columns that changed
+func ToBookPatchV(book *models.Book, doc *dto.BookCreateIn) []string {
+	var columns []string
+
+	if doc.Name != book.Name { book.Name = doc.Name; columns = append(columns, "name") }
+	if doc.Items != book.Items { book.Items = doc.Items; columns = append(columns, "items") }
+
+	return columns
+}
+
+// BookCsvHeader is the header of the books CSV files, the columns of ToBookCsvRecordV
+var BookCsvHeader = []string{"Id", "Name", "Items", "Version", "CreatedAt", "UpdatedAt"}
+
+// csvFormulaChars are the leading characters making a spreadsheet take a CSV cell as a formula
+const csvFormulaChars = "=+-@\t\r"
+
+// ToBookCsvRecordV map a models.Book to a CSV record (row), see BookCsvHeader. This is the export alternative. A name
+// starting like a formula is prefixed with a ' so the spreadsheets take it as text (CSV injection), see FromCsvCell
+func ToBookCsvRecordV(book *models.Book) []string {
+	updatedAt := ""
+	if !book.UpdatedAt.IsZero() { updatedAt = book.UpdatedAt.Format(time.RFC3339) }
+
+	return []string{
+		strconv.FormatUint(uint64(book.Id), 10),
+		toCsvCell(book.Name),
+		strconv.FormatUint(uint64(book.Items), 10),
+		strconv.FormatUint(uint64(book.Version), 10),
+		book.CreatedAt.Format(time.RFC3339),
+		updatedAt,
+	}
+}
+
+// FromCsvCell get the value of an exported CSV text cell, without the ' prefix escaping a formula (see
+// ToBookCsvRecordV), so an export can be imported back as it is
+func FromCsvCell(cell string) string {
+	if len(cell) > 1 && cell[0] == '\'' && strings.IndexByte(csvFormulaChars, cell[1]) >= 0 { return cell[1:] }
+
+	return cell
+}
+
+// toCsvCell escape a CSV text cell starting like a formula, or like an escaped one (so FromCsvCell gets it back)
+func toCsvCell(value string) string {
+	if value != "" && (strings.IndexByte(csvFormulaChars, value[0]) >= 0 || FromCsvCell(value) != value) { return "'" + value }
+
+	return value
+}
+
+// ToBookQueryV map a dto.BookListIn (query parameters) to the generic dto.QueryOpts and the books dto.BookFilter.
+// The page limit defaults to schema.PageDefLimit when it's not provided
+func ToBookQueryV(in *dto.BookListIn) (*dto.QueryOpts, *dto.BookFilter) {
+	opts := &dto.QueryOpts{Page: in.Page, Limit: in.Limit, After: in.After, Before: in.Before}
+	if opts.Page == 0 { opts.Page = 1 }
+	if opts.Limit == 0 { opts.Limit = schema.PageDefLimit }
+
+	// e.g. "-items,name" => items DESC, name ASC
+	for _, col := range strings.Split(in.Sort, ",") {
+		col = strings.TrimSpace(col)
+		if col == "" { continue }
+
+		if strings.HasPrefix(col, "-") {
+			opts.Sort = append(opts.Sort, dto.SortField{Column: col[1:], Desc: true})
+		} else {
+			opts.Sort = append(opts.Sort, dto.SortField{Column: strings.TrimPrefix(col, "+")})
+		}
+	}
+
+	return opts, &dto.BookFilter{Name: in.Name, ItemsMin: in.ItemsMin, ItemsMax: in.ItemsMax}
+}
+
+// ToBookSearchV map a dto.BookSearchIn (query parameters) to the offset pagination dto.QueryOpts. The page limit
+// defaults to schema.PageDefLimit when it's not provided
+func ToBookSearchV(in *dto.BookSearchIn) *dto.QueryOpts {
+	opts := &dto.QueryOpts{Page: in.Page, Limit: in.Limit}
+	if opts.Page == 0 { opts.Page = 1 }
+	if opts.Limit == 0 { opts.Limit = schema.PageDefLimit }
+
+	return opts
+}
 // endregion =============================================================================
 
 // region ======== AUTHORIZATION =========================================================
@@ -31,4 +119,58 @@ func ToAccessTokenDataV(obj *dto.SisecAccessToken) *dto.AccessTokenData {
 	return &dto.AccessTokenData{ Scope: strings.Fields(obj.Scope), Claims: claims }
 }
 
+// ToUserAccessTokenDataV map a models.User of the default (database) auth provider to dto.AccessTokenData.
+// The user's roles are space separated in the Rol claim
+func ToUserAccessTokenDataV(user *models.User) *dto.AccessTokenData {
+	claims := dto.Claims{ Sub: user.Username, Rol: strings.Join(user.Roles, " ") }
+
+	return &dto.AccessTokenData{ Scope: user.Scopes, Claims: claims }
+}
+
+// ToOIDCAccessTokenDataV map the dto.OIDCUserIn of an OpenID Connect ID token to dto.AccessTokenData. The subject is
+// namespaced by the provider (<provider>:<sub>), so it never matches a local user. Only the provider roles & scopes
+// found in the role & scope maps grant an app role (space separated in the Rol claim) or scope, so without the maps
+// nothing is granted
+//
+// - provider [string] ~ Provider name
+//
+// - user [*dto.OIDCUserIn] ~ ID token user data
+//
+// - roleMap [map[string]string] ~ App role of the provider roles
+//
+// - scopeMap [map[string]string] ~ App scope of the provider scopes
+func ToOIDCAccessTokenDataV(provider string, user *dto.OIDCUserIn, roleMap map[string]string, scopeMap map[string]string) *dto.AccessTokenData {
+	claims := dto.Claims{ Sub: provider + ":" + user.Sub, Rol: strings.Join(mapGrants(user.Roles, roleMap), " ") }
+	return &dto.AccessTokenData{ Scope: mapGrants(user.Scope, scopeMap), Claims: claims }
+}
+
+// mapGrants get the app grants (roles or scopes) of the provider ones found in the map, without repeating them.
+// Nil if none is mapped
+func mapGrants(provided []string, grantMap map[string]string) []string {
+	var grants []string
+	granted := map[string]bool{}
+	for _, g := range provided {
+		if app, ok := grantMap[g]; ok && !granted[app] {
+			granted[app] = true
+			grants = append(grants, app)
+		}
+	}
+
+	return grants
+}
+
+// endregion =============================================================================
+
+// region ======== AUDIT =================================================================
+
+// ToAuditListV map a dto.AuditListIn (query parameters) to the offset pagination dto.QueryOpts and the
+// dto.AuditFilter. The page limit defaults to schema.PageDefLimit when it's not provided
+func ToAuditListV(in *dto.AuditListIn) (*dto.QueryOpts, *dto.AuditFilter) {
+	opts := &dto.QueryOpts{Page: in.Page, Limit: in.Limit}
+	if opts.Page == 0 { opts.Page = 1 }
+	if opts.Limit == 0 { opts.Limit = schema.PageDefLimit }
+
+	return opts, &dto.AuditFilter{Entity: in.Entity, EntityId: in.Id}
+}
+
 // endregion =============================================================================
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditEntry is the database table for holding the audit log, one entry per mutation of an entity. It's written in
+// the same transaction than the mutation itself, so there is no change without its entry (and vice versa).
+// Before & After are the entity JSON snapshots, null when the entity didn't exist (e.g. Before of a creation)
+type AuditEntry struct {
+	Id        uint            `example:"1024"`
+	Actor     string          `pg:",notnull" example:"mynickname"`		// Access token subject (Sub claim), or "system"
+	Action    string          `pg:",notnull" example:"update"`			// create, update, delete, restore or purge
+	Entity    string          `pg:",notnull" example:"book"`
+	EntityId  uint            `pg:",notnull" example:"24"`
+	Before    json.RawMessage `pg:"type:jsonb" swaggertype:"object"`
+	After     json.RawMessage `pg:"type:jsonb" swaggertype:"object"`
+	RequestId string          `example:"5b7e3a4c-53e4-4a5b-b1b8-6f9c8a7f2d1e"`		// X-Request-ID of the request doing the mutation
+	CreatedAt time.Time       `pg:"default:now()" example:"2021-03-12T02:11:03.292442-05:00"`
+}
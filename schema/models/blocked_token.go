@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// BlockedToken is the database table for holding the blocked (e.g. logged out) access tokens, until they expire.
+// The Key is the token "jti" claim, or the token hash if the token has no "jti"
+type BlockedToken struct {
+	Id        uint
+	Key       string    `pg:",unique,notnull"`
+	ExpiresAt time.Time // Token expiration, after that the entry can be purged
+	CreatedAt time.Time `pg:"default:now()"`
+}
@@ -4,13 +4,27 @@ import "time"
 
 // go-pg naming convention (https://pg.uptrace.dev/models/)
 
-// Book is the database table for holding the books
+// Book is the database table for holding the books. The deleted books are kept in the trash (soft delete) until
+// they are purged, go-pg excludes them from the queries unless they are explicitly requested.
+// ❗ The name is unique among the books that aren't in the trash, see the book_name_idx (migrations). The table has
+// database-only columns (e.g. the full-text search tsvector), so the unknown columns are discarded on scan
 type Book struct {
+	tableName struct{}	`pg:"books,discard_unknown_columns"`
 	Id        uint		`example:"24"`
-	Name      string    `pg:",unique" example:"The Book of Eli"`
+	Name      string    `pg:",notnull" example:"The Book of Eli"`
 	Items     uint      `pg:"default:0" example:"46"`
+	Version   uint      `pg:"default:1,notnull" example:"1"`			// Incremented on every update, it's the book ETag
 	CreatedAt time.Time `pg:"default:now()" example:"2021-03-12T02:11:03.292442-05:00"`
 	UpdatedAt time.Time	`example:"0001-01-01T00:00:00Z"`
+	DeletedAt time.Time	`pg:",soft_delete" example:"0001-01-01T00:00:00Z"`
+}
+
+// BookHit is a book found by the full-text search, with its relevance (higher is better) and its text fields
+// excerpt with the matches highlighted (see lib.HighlightStart)
+type BookHit struct {
+	Book
+	Rank    float32 `example:"0.0607927"`
+	Snippet string  `example:"The <mark>Book</mark> of Eli"`
 }
 
 // TIP An model / entity can be an object with methods.
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// RefreshToken is the database table for holding the (opaque) refresh tokens. Only the token hash is stored.
+// Every refresh token rotated from the same login shares the FamilyId, so a reused token revokes its whole family.
+// The rotated tokens keep the ExpiresAt of the login one, so a family can't outlive the RefreshTkMaxAge
+type RefreshToken struct {
+	Id        uint
+	TokenHash string    `pg:",unique,notnull"`
+	FamilyId  string    `pg:",notnull"`
+	Sub       string    `pg:",notnull"`
+	Provider  string    `pg:",notnull"` // Auth provider of the login, e.g. for reloading the user on rotation
+	Rol       string
+	Scope     []string  `pg:",array"`
+	ExpiresAt time.Time `pg:",notnull"`
+	UsedAt    time.Time // Set when the token is rotated
+	RevokedAt time.Time // Set when the token family is revoked
+	CreatedAt time.Time `pg:"default:now()"`
+}
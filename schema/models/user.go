@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// User is the database table for holding the users of the default (database) auth provider.
+// The hashes are never marshalled to the clients
+type User struct {
+	Id             uint      `example:"1"`
+	Username       string    `pg:",unique,notnull" example:"mynickname"`
+	PasswordHash   string    `pg:",notnull" json:"-"`
+	Roles          []string  `pg:",array" example:"user"`
+	Scopes         []string  `pg:",array" example:"books:write"`
+	ResetTokenHash string    `json:"-"`
+	ResetTokenExp  time.Time `json:"-"`
+	CreatedAt      time.Time `pg:"default:now()" example:"2021-03-12T02:11:03.292442-05:00"`
+	UpdatedAt      time.Time `example:"0001-01-01T00:00:00Z"`
+}
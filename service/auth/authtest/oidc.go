@@ -0,0 +1,269 @@
+package authtest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kataras/jwt"
+
+	"go.api.backend/lib"
+	"go.api.backend/schema/dto"
+)
+
+// OIDCUser is the user logged in by the FakeOIDC, every login is auto-approved
+type OIDCUser struct {
+	Sub    string
+	Claims map[string]interface{} // Extra ID token claims, e.g. roles & scope
+}
+
+// FakeOIDC is an in process mock of an OpenID Connect issuer (authorization code flow with PKCE), for testing the OIDC
+// provider and the /auth/{provider}/login flow offline. It serves the discovery document, the JWKS, an authorization
+// endpoint auto-approving the logins (redirecting right away to the redirect_uri with the code & state) and a token
+// endpoint checking the client, the code & the PKCE verifier. The ID tokens are RS256 signed, with a kid. Close it
+// when done
+type FakeOIDC struct {
+	*httptest.Server
+
+	ClientId     string
+	ClientSecret string // Empty for a public client
+
+	mu     sync.Mutex
+	user   OIDCUser
+	keys   jwt.Keys // Signing keys, by kid
+	kid    string   // Signing key id
+	pubKid string   // Published key id, in the JWKS
+	codes  map[string]oidcGrant
+	tamper func(claims map[string]interface{})
+	status int
+	calls  map[string]int
+}
+
+// oidcGrant is an issued authorization code, with the authorization request data
+type oidcGrant struct {
+	redirectURI string
+	challenge   string
+	nonce       string
+}
+
+// NewFakeOIDC start a mock OpenID Connect issuer, its URL is the issuer (the oidc provider Url conf)
+//
+// - clientId [string] ~ Client id of the app
+//
+// - clientSecret [string] ~ Client secret of the app, empty for a public client
+//
+// - user [OIDCUser] ~ User logged in by every authorization request
+func NewFakeOIDC(clientId string, clientSecret string, user OIDCUser) *FakeOIDC {
+	f := &FakeOIDC{ClientId: clientId, ClientSecret: clientSecret, user: user, keys: jwt.Keys{}, codes: make(map[string]oidcGrant), calls: make(map[string]int)}
+	f.RotateKey(true)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", f.discovery)
+	mux.HandleFunc("/jwks", f.jwks)
+	mux.HandleFunc("/authorize", f.authorize)
+	mux.HandleFunc("/token", f.token)
+	f.Server = httptest.NewServer(f.count(mux))
+
+	return f
+}
+
+// RotateKey replace the signing key by a new one, with a new kid. The old key is no longer published, unless the new
+// one isn't published either (a forged ID token signature)
+//
+// - publish [bool] ~ Publish the new key in the JWKS
+func (f *FakeOIDC) RotateKey(publish bool) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil { panic(err) }
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.kid = "key-" + strconv.Itoa(len(f.keys) + 1)
+	f.keys.Register(jwt.RS256, f.kid, &key.PublicKey, key)
+	if publish { f.pubKid = f.kid }
+}
+
+// Tamper set a function changing the claims of the next ID tokens before signing them (e.g. a wrong audience or an
+// expired token), nil for going back to the normal behavior
+//
+// - fn [func(claims map[string]interface{})] ~ Claims changer
+func (f *FakeOIDC) Tamper(fn func(claims map[string]interface{})) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.tamper = fn
+}
+
+// FailWith make the token endpoint answer with the given status and an OAuth2 server_error body, 0 for going back to
+// the normal behavior
+//
+// - status [int] ~ HTTP status code
+func (f *FakeOIDC) FailWith(status int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.status = status
+}
+
+// Calls get the amount of requests received so far by an endpoint, e.g. "/jwks"
+//
+// - path [string] ~ Endpoint path
+func (f *FakeOIDC) Calls(path string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.calls[path]
+}
+
+// Authorize follow the authorization url as a browser would (the login is auto-approved), getting the callback url
+// the issuer redirects to
+//
+// - authURL [string] ~ Authorization url, with the provider query parameters
+func (f *FakeOIDC) Authorize(authURL string) (*url.URL, error) {
+	client := f.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+
+	res, err := client.Get(authURL)
+	if err != nil { return nil, err }
+	res.Body.Close()
+
+	return res.Location()
+}
+
+// count count the requests by path
+func (f *FakeOIDC) count(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.calls[r.URL.Path]++
+		f.mu.Unlock()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// discovery serve the discovery document
+func (f *FakeOIDC) discovery(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 f.URL,
+		"authorization_endpoint": f.URL + "/authorize",
+		"token_endpoint":         f.URL + "/token",
+		"jwks_uri":               f.URL + "/jwks",
+	})
+}
+
+// jwks serve the published key
+func (f *FakeOIDC) jwks(w http.ResponseWriter, _ *http.Request) {
+	f.mu.Lock()
+	key := f.keys[f.pubKid]
+	f.mu.Unlock()
+
+	jwk, err := lib.ToJWK(key.ID, key.Alg, key.Public)
+	if err != nil { panic(err) }
+
+	writeJSON(w, http.StatusOK, dto.JWKSOut{Keys: []dto.JWK{*jwk}})
+}
+
+// authorize handle an authorization request, approving it and redirecting to the redirect_uri with a new code
+func (f *FakeOIDC) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+
+	switch {
+	case q.Get("client_id") != f.ClientId || err != nil || !redirect.IsAbs():
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request", "error_description": "Bad client or redirect_uri"})
+		return
+	case q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "":
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request", "error_description": "Authorization code with PKCE (S256) required"})
+		return
+	}
+
+	code := mkCode()
+	f.mu.Lock()
+	f.codes[code] = oidcGrant{redirectURI: q.Get("redirect_uri"), challenge: q.Get("code_challenge"), nonce: q.Get("nonce")}
+	f.mu.Unlock()
+
+	cb := redirect.Query()
+	cb.Set("code", code)
+	cb.Set("state", q.Get("state"))
+	redirect.RawQuery = cb.Encode()
+
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+// token handle an authorization code exchange, issuing the ID token
+func (f *FakeOIDC) token(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	status := f.status
+	f.mu.Unlock()
+
+	if status != 0 {
+		writeJSON(w, status, map[string]string{"error": "server_error", "error_description": http.StatusText(status)})
+		return
+	}
+
+	if r.Method != http.MethodPost || r.ParseForm() != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	// client authentication, Basic (confidential client) or just the client_id (public client)
+	id, secret, basic := r.BasicAuth()
+	if basic {
+		id, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		id = r.PostForm.Get("client_id")
+	}
+	if id != f.ClientId || secret != f.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client", "error_description": "Bad client credentials"})
+		return
+	}
+
+	f.mu.Lock()
+	grant, ok := f.codes[r.PostForm.Get("code")]
+	delete(f.codes, r.PostForm.Get("code"))					// single use
+	f.mu.Unlock()
+
+	challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || grant.redirectURI != r.PostForm.Get("redirect_uri") || grant.challenge != base64.RawURLEncoding.EncodeToString(challenge[:]) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "Bad code, redirect_uri or code_verifier"})
+		return
+	}
+
+	idToken, err := f.sign(grant.nonce)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error", "error_description": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"token_type": "Bearer", "access_token": mkCode(), "id_token": idToken, "expires_in": 300})
+}
+
+// sign issue an ID token for the user, with the current key
+func (f *FakeOIDC) sign(nonce string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now().Unix()
+	claims := map[string]interface{}{"iss": f.URL, "sub": f.user.Sub, "aud": f.ClientId, "iat": now, "exp": now + 300, "nonce": nonce}
+	for k, v := range f.user.Claims { claims[k] = v }
+	if f.tamper != nil { f.tamper(claims) }
+
+	token, err := f.keys.SignToken(f.kid, claims)
+	return string(token), err
+}
+
+// mkCode create a random code
+func mkCode() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,112 @@
+package authtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+// SisecUser is a user known by the FakeSisec
+type SisecUser struct {
+	Password string
+	Domain   string
+	Rol      string
+	Scope    string // Space separated scopes
+}
+
+// FakeSisec is an in process fake of the SISEC auth system (password grant), for testing the SISEC provider and the
+// /auth/sisec flow offline. It speaks the way SISEC does: a Basic authenticated form POST, answered with the granted
+// access token data, or with an OAuth2 error body (401 invalid_client, 400 invalid_grant). Close it when done
+type FakeSisec struct {
+	*httptest.Server
+
+	ClientId   string
+	ClientPass string
+
+	mu     sync.Mutex
+	users  map[string]SisecUser
+	status int
+	calls  int
+}
+
+// NewFakeSisec start a fake SISEC server, knowing the given users (by username). Its URL goes to the SisecUrl conf
+//
+// - clientId [string] ~ App (client) id expected in the Basic authentication
+//
+// - clientPass [string] ~ App (client) password expected in the Basic authentication
+//
+// - users [map[string]SisecUser] ~ Known users, by username
+func NewFakeSisec(clientId string, clientPass string, users map[string]SisecUser) *FakeSisec {
+	f := &FakeSisec{ClientId: clientId, ClientPass: clientPass, users: users}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.grant))
+
+	return f
+}
+
+// FailWith make the fake answer every request with the given status (e.g. 503) and an OAuth2 server_error body,
+// 0 for going back to the normal behavior
+//
+// - status [int] ~ HTTP status code
+func (f *FakeSisec) FailWith(status int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.status = status
+}
+
+// Calls get the amount of requests received so far
+func (f *FakeSisec) Calls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.calls
+}
+
+// grant handle a password grant intent
+func (f *FakeSisec) grant(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.calls++
+	status := f.status
+	f.mu.Unlock()
+
+	if status != 0 {
+		writeJSON(w, status, map[string]string{"error": "server_error", "error_description": http.StatusText(status)})
+		return
+	}
+
+	if r.Method == http.MethodHead { return }		// reachability checks
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	if id, pass, ok := r.BasicAuth(); !ok || id != f.ClientId || pass != f.ClientPass {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client", "error_description": "Bad client credentials"})
+		return
+	}
+
+	if r.ParseForm() != nil || r.PostForm.Get("grant_type") != "password" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	username := r.PostForm.Get("username")
+	u, ok := f.users[username]
+	if !ok || u.Password != r.PostForm.Get("password") || u.Domain != r.PostForm.Get("domain") {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "Bad credentials"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"token_type":   "bearer",
+		"access_token": map[string]string{"rol": u.Rol, "client_id": username, "scope": u.Scope},
+	})
+}
+
+// writeJSON write a JSON response with the given status
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/kataras/golog"
+	"github.com/kataras/iris/v12/middleware/jwt"
+	kjwt "github.com/kataras/jwt"
+
+	"go.api.backend/repo/db"
+	"go.api.backend/service/utils"
+)
+
+// NewBlocklist create the JWT blocklist backend selected in the configuration (BlocklistBackend). It can be:
+//
+// - "postgres" ~ Blocked tokens stored in the database, shared between replicas and kept on restarts
+//
+// - "memory" ~ Blocked tokens kept in memory, handy for testing and single instance deployments (default)
+//
+// The expired entries are purged every BlocklistPurgeEvery minutes, until the given context is done.
+//
+// - ctx [context.Context] ~ Context for stopping the purges
+//
+// - svcConfig [*SvcConfig] ~ App conf instance pointer
+//
+// - dbCtx [*pg.DB] ~ Postgres database instance, used by the postgres backend
+func NewBlocklist(ctx context.Context, svcConfig *utils.SvcConfig, dbCtx *pg.DB) jwt.Blocklist {
+
+	every := time.Duration(svcConfig.BlocklistPurgeEvery) * time.Minute
+	if every <= 0 { every = 30 * time.Minute }
+
+	if svcConfig.BlocklistBackend != "postgres" {						// ===== MEMORY CASE =======
+		return kjwt.NewBlocklistContext(ctx, every)
+	}
+
+	// ===== POSTGRES CASE =======
+	blocklist := db.NewRepoDbBlocklist(dbCtx)
+
+	go func() {
+		t := time.NewTicker(every)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				if _, err := blocklist.Purge(); err != nil { golog.Error("blocklist purge: ", err) }
+			}
+		}
+	}()
+
+	return blocklist
+}
@@ -0,0 +1,347 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kataras/iris/v12"
+	"github.com/kataras/jwt"
+	jsoniter "github.com/json-iterator/go"
+
+	"go.api.backend/lib"
+	"go.api.backend/schema"
+	"go.api.backend/schema/dto"
+	"go.api.backend/schema/mapper"
+)
+
+// RedirectProvider is implemented by the providers logging the users in on their own login page, through a redirect
+// (OAuth2 authorization code flow) instead of a password grant. Like GrantIntent, the methods return an error code
+// (i18n key) to identify the kind of error on the caller.
+type RedirectProvider interface {
+	AuthCodeURL(ctx context.Context) (string, error, string)
+	Exchange(ctx context.Context, code string, state string) (*dto.AccessTokenData, error, string)
+}
+
+// region ======== OPENID CONNECT AUTHENTICATION PROVIDER ================================
+
+const (
+	oidcDiscoveryPath = "/.well-known/openid-configuration"
+	oidcLoginTTL      = 10 * time.Minute		// Default time for completing a login on the provider page
+	oidcMaxPending    = 10000					// Max amount of logins in progress, so they can't exhaust the memory
+)
+
+// ProviderOIDC is a generic OpenID Connect provider (e.g. Keycloak, Auth0, Google), using the authorization code
+// flow with PKCE (S256). The issuer endpoints are discovered, and the ID tokens verified against the issuer JWKS.
+//
+// The logins in progress (state, PKCE verifier & nonce) are kept in memory, so the login redirect and the callback
+// must reach the same instance (e.g. sticky sessions) when running several replicas. It's safe for concurrent use
+type ProviderOIDC struct {
+	Name         string				// Provider name, namespacing the user subjects (<name>:<sub>)
+	Issuer       string
+	ClientId     string
+	ClientSecret string				// Empty for a public client
+	RedirectURL  string				// Our callback url, registered on the issuer
+	Scopes       []string			// Requested scopes, "openid" is always requested
+	RolClaim     string				// ID token claim holding the roles, dot separated for the nested ones (e.g. realm_access.roles)
+	RoleMap      map[string]string	// App role of the provider roles, the unmapped ones grant nothing
+	ScopeClaim   string				// ID token claim holding the granted scopes
+	ScopeMap     map[string]string	// App scope of the provider scopes, the unmapped ones grant nothing
+	LoginTTL     time.Duration		// Time for completing a login, 10 minutes by default
+	Client       *lib.OutboundClient	// Outbound client, with the OIDC timeout, retries & circuit breaker
+
+	mu        sync.Mutex
+	discovery *dto.OIDCDiscoveryIn	// Cached issuer metadata
+	keys      jwt.Keys				// Cached issuer keys, replaced as a whole (never written after being published)
+	pending   map[string]oidcLogin	// Logins in progress, by state
+}
+
+// oidcLogin is a login in progress, waiting for the provider callback
+type oidcLogin struct {
+	verifier string			// PKCE code verifier
+	nonce    string			// Expected ID token nonce
+	expires  time.Time
+}
+
+// GrantIntent is not supported, the OpenID Connect users log in through the provider login page (see AuthCodeURL)
+func (p *ProviderOIDC) GrantIntent(context.Context, *dto.UserCredIn, interface{}) (*dto.AccessTokenData, error, string) {
+	return nil, errors.New(schema.ErrDetNoPasswordGrant), schema.ErrWrongAuthProvider
+}
+
+// AuthCodeURL start a login, getting the provider authorization url the user must be redirected to. The login has
+// to be completed (see Exchange) within the LoginTTL
+//
+// - ctx [context.Context] ~ Request context
+func (p *ProviderOIDC) AuthCodeURL(ctx context.Context) (string, error, string) {
+	d, err, code := p.discover(ctx)
+	if err != nil { return "", err, code }
+
+	login := oidcLogin{expires: time.Now().Add(p.loginTTL())}
+	state, err := lib.MkRandomToken(32)
+	if err == nil { login.verifier, err = lib.MkRandomToken(32) }		// 43 chars, RFC 7636 section 4.1
+	if err == nil { login.nonce, err = lib.MkRandomToken(32) }
+	if err != nil { return "", err, schema.ErrGeneric }
+
+	if err := p.putLogin(state, login); err != nil { return "", err, schema.ErrUpstreamUnavailable }
+
+	challenge := sha256.Sum256([]byte(login.verifier))
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.ClientId},
+		"redirect_uri":          {p.RedirectURL},
+		"scope":                 {p.scope()},
+		"state":                 {state},
+		"nonce":                 {login.nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	sep := "?"
+	if strings.Contains(d.Authorization_Endpoint, "?") { sep = "&" }
+
+	return d.Authorization_Endpoint + sep + q.Encode(), nil, ""
+}
+
+// Exchange complete a login, exchanging the authorization code got in the provider callback for the ID token. The
+// verified ID token claims are the data to be tokenized. A login state can be used once
+//
+// - ctx [context.Context] ~ Request context
+//
+// - code [string] ~ Authorization code, from the callback
+//
+// - state [string] ~ Login state, from the callback
+func (p *ProviderOIDC) Exchange(ctx context.Context, code string, state string) (*dto.AccessTokenData, error, string) {
+	login, ok := p.takeLogin(state)
+	if !ok { return nil, errors.New(schema.ErrDetInvalidLoginState), schema.ErrUnauthorized }
+
+	d, err, eCode := p.discover(ctx)
+	if err != nil { return nil, err, eCode }
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.RedirectURL},
+		"client_id":     {p.ClientId},
+		"code_verifier": {login.verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.Token_Endpoint, strings.NewReader(form.Encode()))
+	if err != nil { return nil, err, schema.ErrNetwork }
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.ClientSecret != "" { req.SetBasicAuth(url.QueryEscape(p.ClientId), url.QueryEscape(p.ClientSecret)) }	// RFC 6749 section 2.3.1
+
+	// the authorization codes are single use, so the exchange isn't retried
+	res, err := p.Client.Do(req)
+	if err != nil { return nil, err, schema.ErrNetwork }
+	defer res.Body.Close()
+
+	if res.StatusCode != iris.StatusOK { return upstreamErr(res, schema.ErrDetInvalidAuthCode) }
+
+	tk := dto.OIDCTokenIn{}
+	if e := jsoniter.NewDecoder(res.Body).Decode(&tk); e != nil { return nil, e, schema.ErrJsonParse }
+	if tk.Id_Token == "" { return nil, errors.New(schema.ErrDetHttpResError + " - no id_token in the token response"), schema.ErrHttpResError }
+
+	user, err, eCode := p.verifyIdToken(ctx, d, tk.Id_Token, login.nonce)
+	if err != nil { return nil, err, eCode }
+
+	return mapper.ToOIDCAccessTokenDataV(p.Name, user, p.RoleMap, p.ScopeMap), nil, ""
+}
+
+// Check tells if the issuer is reachable, fetching its discovery document. It doesn't feed the issuer circuit breaker
+// (see lib.WithCheck)
+//
+// - ctx [context.Context] ~ Context for the request, e.g. with a timeout
+func (p *ProviderOIDC) Check(ctx context.Context) error {
+	d := dto.OIDCDiscoveryIn{}
+	err, _ := p.getJSON(lib.WithCheck(ctx), strings.TrimSuffix(p.Issuer, "/") + oidcDiscoveryPath, &d)
+
+	return err
+}
+
+// verifyIdToken verify the ID token signature against the issuer keys and its claims (issuer, audience, expiry &
+// nonce), getting the user data. The keys are fetched again once if the token is signed with an unknown key, the
+// issuer may have rotated them (the token comes straight from the issuer, so this can't be abused for flooding it)
+func (p *ProviderOIDC) verifyIdToken(ctx context.Context, d *dto.OIDCDiscoveryIn, token string, nonce string) (*dto.OIDCUserIn, error, string) {
+	keys, err, code := p.jwks(ctx, d, false)
+	if err != nil { return nil, err, code }
+
+	verified, err := jwt.VerifyWithHeaderValidator(nil, nil, []byte(token), keys.ValidateHeader)
+	if err == jwt.ErrUnknownKid {
+		if keys, err, code = p.jwks(ctx, d, true); err != nil { return nil, err, code }
+		verified, err = jwt.VerifyWithHeaderValidator(nil, nil, []byte(token), keys.ValidateHeader)
+	}
+	if err != nil { return nil, idTokenErr(err.Error()), schema.ErrUnauthorized }
+
+	claims := map[string]interface{}{}
+	if err := verified.Claims(&claims); err != nil { return nil, idTokenErr(err.Error()), schema.ErrUnauthorized }
+
+	c := verified.StandardClaims
+	got, _ := claims["nonce"].(string)
+	azp, _ := claims["azp"].(string)
+
+	switch {
+	case c.Issuer != d.Issuer:
+		return nil, idTokenErr("unexpected issuer " + c.Issuer), schema.ErrUnauthorized
+	case !contains(c.Audience, p.ClientId) || (azp != "" && azp != p.ClientId):
+		return nil, idTokenErr("issued for another client"), schema.ErrUnauthorized
+	case c.Expiry == 0:
+		return nil, idTokenErr("no expiry"), schema.ErrUnauthorized
+	case c.Subject == "":
+		return nil, idTokenErr("no subject"), schema.ErrUnauthorized
+	case subtle.ConstantTimeCompare([]byte(got), []byte(nonce)) != 1:
+		return nil, idTokenErr("nonce mismatch"), schema.ErrUnauthorized
+	}
+
+	return &dto.OIDCUserIn{Sub: c.Subject, Roles: claimStrings(claims, p.RolClaim), Scope: claimStrings(claims, p.ScopeClaim)}, nil, ""
+}
+
+// discover get the issuer metadata, fetched the first time and cached. The discovered issuer must be the configured one
+func (p *ProviderOIDC) discover(ctx context.Context) (*dto.OIDCDiscoveryIn, error, string) {
+	p.mu.Lock()
+	d := p.discovery
+	p.mu.Unlock()
+	if d != nil { return d, nil, "" }
+
+	d = &dto.OIDCDiscoveryIn{}
+	if err, code := p.getJSON(ctx, strings.TrimSuffix(p.Issuer, "/") + oidcDiscoveryPath, d); err != nil { return nil, err, code }
+
+	if d.Issuer != p.Issuer || d.Authorization_Endpoint == "" || d.Token_Endpoint == "" || d.Jwks_Uri == "" {
+		return nil, errors.New(schema.ErrDetHttpResError + " - invalid discovery document of " + p.Issuer), schema.ErrHttpResError
+	}
+
+	p.mu.Lock()
+	p.discovery = d
+	p.mu.Unlock()
+
+	return d, nil, ""
+}
+
+// jwks get the issuer keys, fetched the first time (or when refreshing) and cached
+func (p *ProviderOIDC) jwks(ctx context.Context, d *dto.OIDCDiscoveryIn, refresh bool) (jwt.Keys, error, string) {
+	p.mu.Lock()
+	keys := p.keys
+	p.mu.Unlock()
+	if keys != nil && !refresh { return keys, nil, "" }
+
+	set := dto.JWKSIn{}
+	if err, code := p.getJSON(ctx, d.Jwks_Uri, &set); err != nil { return nil, err, code }
+
+	keys, err := lib.KeysFromJWKS(&set)
+	if err != nil { return nil, err, schema.ErrHttpResError }
+
+	p.mu.Lock()
+	p.keys = keys
+	p.mu.Unlock()
+
+	return keys, nil, ""
+}
+
+// getJSON get an issuer JSON document (e.g. the discovery or the JWKS one), the GETs are retried by the client
+func (p *ProviderOIDC) getJSON(ctx context.Context, u string, dest interface{}) (error, string) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil { return err, schema.ErrNetwork }
+	req.Header.Set("Accept", "application/json")
+
+	res, err := p.Client.Do(req)
+	if err != nil { return err, schema.ErrNetwork }
+	defer res.Body.Close()
+
+	if res.StatusCode >= iris.StatusInternalServerError {
+		return errors.New(schema.ErrDetUpstreamUnavailable + " - " + strconv.Itoa(res.StatusCode)), schema.ErrUpstreamUnavailable
+	} else if res.StatusCode != iris.StatusOK {
+		return errors.New(schema.ErrDetHttpResError + " - " + strconv.Itoa(res.StatusCode)), schema.ErrHttpResError
+	}
+
+	if e := jsoniter.NewDecoder(res.Body).Decode(dest); e != nil { return e, schema.ErrJsonParse }
+
+	return nil, ""
+}
+
+// putLogin keep a login in progress, dropping the expired ones
+func (p *ProviderOIDC) putLogin(state string, login oidcLogin) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.pending == nil { p.pending = make(map[string]oidcLogin) }
+
+	now := time.Now()
+	for s, l := range p.pending {
+		if now.After(l.expires) { delete(p.pending, s) }
+	}
+	if len(p.pending) >= oidcMaxPending { return errors.New("too many logins in progress, try again later") }
+
+	p.pending[state] = login
+	return nil
+}
+
+// takeLogin get & forget a login in progress, if it exists and isn't expired
+func (p *ProviderOIDC) takeLogin(state string) (oidcLogin, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	login, ok := p.pending[state]
+	delete(p.pending, state)
+
+	return login, ok && time.Now().Before(login.expires)
+}
+
+// loginTTL get the time for completing a login
+func (p *ProviderOIDC) loginTTL() time.Duration {
+	if p.LoginTTL <= 0 { return oidcLoginTTL }
+	return p.LoginTTL
+}
+
+// scope get the requested scopes, always including "openid"
+func (p *ProviderOIDC) scope() string {
+	if contains(p.Scopes, "openid") { return strings.Join(p.Scopes, " ") }
+	return strings.Join(append([]string{"openid"}, p.Scopes...), " ")
+}
+
+// idTokenErr create an invalid ID token error, with the given reason
+func idTokenErr(reason string) error {
+	return errors.New(schema.ErrDetInvalidIdToken + " - " + reason)
+}
+
+// claimStrings get the strings of a claim, a space separated string or an array of strings. The dotted paths reach
+// the nested claims, e.g. realm_access.roles
+func claimStrings(claims map[string]interface{}, path string) []string {
+	if path == "" { return nil }
+
+	var v interface{} = claims
+	for _, name := range strings.Split(path, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok { return nil }
+		v = m[name]
+	}
+
+	switch t := v.(type) {
+	case string:
+		return strings.Fields(t)
+	case []interface{}:
+		var out []string
+		for _, e := range t {
+			if s, ok := e.(string); ok && s != "" { out = append(out, s) }
+		}
+		return out
+	}
+
+	return nil
+}
+
+// contains tells if the slice contains the string
+func contains(s []string, v string) bool {
+	for _, e := range s {
+		if e == v { return true }
+	}
+	return false
+}
+// endregion =============================================================================
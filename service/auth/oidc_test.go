@@ -0,0 +1,245 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"go.api.backend/lib"
+	"go.api.backend/schema"
+	"go.api.backend/service/auth/authtest"
+)
+
+const testRedirectURL = "http://app.test/auth/acme/callback"
+
+// newTestOIDC start a mock issuer logging in "alice" (admin, with the books:write scope in a nested claim), and create
+// the provider pointing to it
+func newTestOIDC(t *testing.T) (*authtest.FakeOIDC, *ProviderOIDC) {
+	t.Helper()
+
+	fake := authtest.NewFakeOIDC("app", "app-secret", authtest.OIDCUser{Sub: "alice", Claims: map[string]interface{}{
+		"realm_access": map[string]interface{}{"roles": []string{"admin", "user"}},
+		"scope":        "openid " + schema.ScopeBooksWrite,
+	}})
+	t.Cleanup(fake.Close)
+
+	return fake, &ProviderOIDC{
+		Name: "acme", Issuer: fake.URL, ClientId: "app", ClientSecret: "app-secret", RedirectURL: testRedirectURL,
+		Scopes: []string{"profile"}, RolClaim: "realm_access.roles", ScopeClaim: "scope",
+		RoleMap: map[string]string{"admin": schema.RolAdmin}, ScopeMap: map[string]string{schema.ScopeBooksWrite: schema.ScopeBooksWrite},
+		Client: lib.NewOutboundClient(lib.OutboundOpts{Name: "acme", Backoff: time.Millisecond}),
+	}
+}
+
+// login start a login and follow the issuer redirect, getting the callback code & state
+func login(t *testing.T, fake *authtest.FakeOIDC, p *ProviderOIDC) (string, string) {
+	t.Helper()
+
+	authURL, err, code := p.AuthCodeURL(context.Background())
+	if err != nil { t.Fatalf("AuthCodeURL = %v, %q", err, code) }
+
+	cb, err := fake.Authorize(authURL)
+	if err != nil { t.Fatal(err) }
+	if !strings.HasPrefix(cb.String(), testRedirectURL) { t.Fatalf("callback = %s, want %s", cb, testRedirectURL) }
+
+	return cb.Query().Get("code"), cb.Query().Get("state")
+}
+
+func TestProviderOIDC_AuthCodeURL(t *testing.T) {
+	_, p := newTestOIDC(t)
+
+	authURL, err, _ := p.AuthCodeURL(context.Background())
+	if err != nil { t.Fatal(err) }
+
+	u, _ := url.Parse(authURL)
+	q := u.Query()
+	if u.Path != "/authorize" || q.Get("client_id") != "app" || q.Get("redirect_uri") != testRedirectURL || q.Get("scope") != "openid profile" {
+		t.Fatalf("authorization url = %s", authURL)
+	}
+	if q.Get("code_challenge_method") != "S256" || len(q.Get("code_challenge")) != 43 || q.Get("state") == "" || q.Get("nonce") == "" {
+		t.Fatalf("authorization url PKCE, state & nonce = %s", authURL)
+	}
+}
+
+func TestProviderOIDC_Exchange(t *testing.T) {
+	tests := []struct {
+		name       string
+		tamper     func(claims map[string]interface{})
+		secret     string // Wrong client secret if set
+		failWith   int
+		state      string // Forged state if set
+		verifier   string // Forged PKCE verifier if set
+		wantCode   string
+		wantDetail string
+	}{
+		{name: "granted"},
+		{name: "unknown state", state: "forged", wantCode: schema.ErrUnauthorized, wantDetail: schema.ErrDetInvalidLoginState},
+		{name: "wrong PKCE verifier", verifier: "forged", wantCode: schema.ErrUnauthorized, wantDetail: schema.ErrDetInvalidAuthCode},
+		{name: "wrong client secret", secret: "nope", wantCode: schema.ErrUpstreamConfig, wantDetail: "Bad client credentials"},
+		{name: "issuer failure", failWith: http.StatusBadGateway, wantCode: schema.ErrUpstreamUnavailable},
+		{
+			name: "wrong audience", tamper: func(c map[string]interface{}) { c["aud"] = "other-app" },
+			wantCode: schema.ErrUnauthorized, wantDetail: "issued for another client",
+		},
+		{
+			name: "wrong issuer", tamper: func(c map[string]interface{}) { c["iss"] = "https://evil.test" },
+			wantCode: schema.ErrUnauthorized, wantDetail: "unexpected issuer",
+		},
+		{
+			name: "expired", tamper: func(c map[string]interface{}) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+			wantCode: schema.ErrUnauthorized, wantDetail: "expired",
+		},
+		{
+			name: "no expiry", tamper: func(c map[string]interface{}) { delete(c, "exp") },
+			wantCode: schema.ErrUnauthorized, wantDetail: "no expiry",
+		},
+		{
+			name: "nonce mismatch", tamper: func(c map[string]interface{}) { c["nonce"] = "replayed" },
+			wantCode: schema.ErrUnauthorized, wantDetail: "nonce mismatch",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, p := newTestOIDC(t)
+			if tt.secret != "" { p.ClientSecret = tt.secret }
+			if tt.failWith != 0 { fake.FailWith(tt.failWith) }
+			fake.Tamper(tt.tamper)
+
+			code, state := login(t, fake, p)
+			if tt.state != "" { state = tt.state }
+			if tt.verifier != "" {
+				l := p.pending[state]
+				l.verifier = tt.verifier
+				p.pending[state] = l
+			}
+
+			data, err, eCode := p.Exchange(context.Background(), code, state)
+
+			if eCode != tt.wantCode { t.Fatalf("code = %q (%v), want %q", eCode, err, tt.wantCode) }
+			if tt.wantCode == "" {
+				if err != nil || data.Claims.Sub != "acme:alice" || data.Claims.Rol != schema.RolAdmin || len(data.Scope) != 1 || data.Scope[0] != schema.ScopeBooksWrite {
+					t.Fatalf("granted data = %+v, %v", data, err)
+				}
+				return
+			}
+
+			if err == nil || data != nil { t.Fatalf("data, err = %+v, %v, want an error", data, err) }
+			if !strings.Contains(err.Error(), tt.wantDetail) { t.Fatalf("err = %q, want it containing %q", err, tt.wantDetail) }
+		})
+	}
+}
+
+// A login state is single use, and expires
+func TestProviderOIDC_Exchange_State(t *testing.T) {
+	fake, p := newTestOIDC(t)
+
+	code, state := login(t, fake, p)
+	if _, err, _ := p.Exchange(context.Background(), code, state); err != nil { t.Fatal(err) }
+	if _, _, eCode := p.Exchange(context.Background(), code, state); eCode != schema.ErrUnauthorized { t.Fatalf("replayed state code = %q, want %q", eCode, schema.ErrUnauthorized) }
+
+	p.LoginTTL = time.Millisecond
+	code, state = login(t, fake, p)
+	time.Sleep(5 * time.Millisecond)
+	if _, err, _ := p.Exchange(context.Background(), code, state); err == nil || !strings.Contains(err.Error(), schema.ErrDetInvalidLoginState) {
+		t.Fatalf("expired state err = %v, want %q", err, schema.ErrDetInvalidLoginState)
+	}
+}
+
+// The issuer keys are cached, and fetched again when the issuer rotates them
+func TestProviderOIDC_Exchange_KeyRotation(t *testing.T) {
+	fake, p := newTestOIDC(t)
+
+	for i := 0; i < 2; i++ {
+		code, state := login(t, fake, p)
+		if _, err, _ := p.Exchange(context.Background(), code, state); err != nil { t.Fatal(err) }
+	}
+	if n := fake.Calls("/jwks"); n != 1 { t.Fatalf("JWKS fetches = %d, want 1 (cached)", n) }
+
+	fake.RotateKey(true)
+	code, state := login(t, fake, p)
+	if _, err, _ := p.Exchange(context.Background(), code, state); err != nil { t.Fatalf("exchange after the key rotation = %v", err) }
+	if n := fake.Calls("/jwks"); n != 2 { t.Fatalf("JWKS fetches = %d, want 2", n) }
+	if n := fake.Calls("/.well-known/openid-configuration"); n != 1 { t.Fatalf("discovery fetches = %d, want 1 (cached)", n) }
+}
+
+// A token signed with a key the issuer doesn't publish is rejected, even after fetching the keys again
+func TestProviderOIDC_Exchange_UnknownKey(t *testing.T) {
+	fake, p := newTestOIDC(t)
+	fake.RotateKey(false)
+
+	code, state := login(t, fake, p)
+	_, err, eCode := p.Exchange(context.Background(), code, state)
+
+	if eCode != schema.ErrUnauthorized || err == nil || !strings.Contains(err.Error(), schema.ErrDetInvalidIdToken) {
+		t.Fatalf("forged token = %q, %v, want %q", eCode, err, schema.ErrUnauthorized)
+	}
+	if n := fake.Calls("/jwks"); n != 2 { t.Fatalf("JWKS fetches = %d, want 2 (refetched once)", n) }
+}
+
+func TestProviderOIDC_GrantIntent(t *testing.T) {
+	_, p := newTestOIDC(t)
+
+	if _, err, code := p.GrantIntent(context.Background(), nil, nil); code != schema.ErrWrongAuthProvider || err == nil {
+		t.Fatalf("password grant = %q, %v, want %q", code, err, schema.ErrWrongAuthProvider)
+	}
+}
+
+// Only the mapped provider roles grant an app role
+func TestProviderOIDC_Exchange_RoleMap(t *testing.T) {
+	tests := []struct {
+		name    string
+		roleMap map[string]string
+		wantRol string
+	}{
+		{name: "no role map", wantRol: ""},
+		{name: "unmapped roles", roleMap: map[string]string{"owner": schema.RolAdmin}, wantRol: ""},
+		{name: "mapped roles", roleMap: map[string]string{"admin": schema.RolAdmin, "user": schema.RolUser}, wantRol: "admin user"},
+		{name: "same app role", roleMap: map[string]string{"admin": schema.RolUser, "user": schema.RolUser}, wantRol: schema.RolUser},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, p := newTestOIDC(t)
+			p.RoleMap = tt.roleMap
+
+			code, state := login(t, fake, p)
+			data, err, _ := p.Exchange(context.Background(), code, state)
+			if err != nil { t.Fatal(err) }
+
+			if data.Claims.Rol != tt.wantRol || data.Claims.Sub != "acme:alice" { t.Fatalf("claims = %+v, want the %q roles of acme:alice", data.Claims, tt.wantRol) }
+		})
+	}
+}
+
+// Only the mapped provider scopes grant an app scope
+func TestProviderOIDC_Exchange_ScopeMap(t *testing.T) {
+	tests := []struct {
+		name      string
+		scopeMap  map[string]string
+		wantScope []string
+	}{
+		{name: "no scope map"},
+		{name: "unmapped scopes", scopeMap: map[string]string{"books:admin": schema.ScopeBooksWrite}},
+		{name: "mapped scopes", scopeMap: map[string]string{schema.ScopeBooksWrite: schema.ScopeBooksWrite}, wantScope: []string{schema.ScopeBooksWrite}},
+		{name: "same app scope", scopeMap: map[string]string{"openid": schema.ScopeBooksWrite, schema.ScopeBooksWrite: schema.ScopeBooksWrite}, wantScope: []string{schema.ScopeBooksWrite}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, p := newTestOIDC(t)
+			p.ScopeMap = tt.scopeMap
+
+			code, state := login(t, fake, p)
+			data, err, _ := p.Exchange(context.Background(), code, state)
+			if err != nil { t.Fatal(err) }
+
+			if len(data.Scope) != len(tt.wantScope) || (len(tt.wantScope) > 0 && data.Scope[0] != tt.wantScope[0]) {
+				t.Fatalf("scope = %v, want %v", data.Scope, tt.wantScope)
+			}
+		})
+	}
+}
@@ -1,35 +1,60 @@
 package auth
 
 import (
+	"context"
 	"encoding/base64"
 	"errors"
 	"github.com/kataras/iris/v12"
-	"io/ioutil"
-	"math/rand"
+	"io"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
-	"time"
 
 	jsoniter "github.com/json-iterator/go"
 
+	"go.api.backend/lib"
+	"go.api.backend/repo/db"
 	"go.api.backend/schema"
 	"go.api.backend/schema/dto"
-	"go.api.backend/service/utils"
+	"go.api.backend/schema/errs"
+	"go.api.backend/schema/mapper"
+	"go.api.backend/schema/models"
 )
 
 
+// Provider is an authentication provider. The GrantIntent method validates the user credential and return the
+// data to be tokenized, an error if any, and an error code (i18n key) to identify the kind of error on the caller.
+// The context is the request one, so an upstream call is canceled together with the request
 type Provider interface {
-	GrantIntent(userCredential *dto.UserCredIn, data interface{}) (*dto.SISECGrantIntentIn, error, string)
+	GrantIntent(ctx context.Context, userCredential *dto.UserCredIn, data interface{}) (*dto.AccessTokenData, error, string)
+}
+
+// Checker is implemented by the providers that can report their reachability, see the readiness endpoint
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// Reloader is implemented by the providers storing their users, so a refresh token rotation gets the current user
+// data (e.g. the scopes granted after the login) instead of the login one. If the user doesn't exist anymore then
+// err is an errs.NotFound
+type Reloader interface {
+	Reload(sub string) (*dto.AccessTokenData, error)
 }
 
 // region ======== SISEC AUTHENTICATION PROVIDER =========================================
 
+const (
+	oauthInvalidGrant  = "invalid_grant"	// OAuth2 error code of the rejected user credentials or authorization code
+	oauthInvalidClient = "invalid_client"	// OAuth2 error code of the rejected app (client) credentials
+	maxErrBody         = 64 << 10			// Max size of an upstream error response body to be read, in bytes
+)
+
 type ProviderSisec struct {
 	URL        *url.URL
 	ClientId   string
 	ClientPass string
+	Client     *lib.OutboundClient // Outbound client, with the SISEC timeout, retries & circuit breaker
 }
 
 // password grant type
@@ -37,46 +62,33 @@ type ProviderSisec struct {
 // GrantIntent make login / grant (password type) intent against SISEC Tecnomática auth system, using
 // the given user credentials.
 //
-// It returns XX, error if any, and a error code to identify the kind of error on the caller.
+// It returns the data to be tokenized, error if any, and a error code to identify the kind of error on the caller.
+// The grant isn't retried: a failed attempt may have been counted by SISEC against the user (e.g. a lockout)
+//
+// - ctx [context.Context] ~ Request context, the SISEC request is canceled with it
 //
 // - uCred [*dto.UserCredIn] ~ User credential
 //
-// - options [interface{}] ~ Some options or data of a specific type to be used in the provider authentication method
-func (p *ProviderSisec) GrantIntent(uCred *dto.UserCredIn, options interface{}) (*dto.SISECGrantIntentIn, error, string) {
-
-	v, ok := options.(*utils.SvcConfig)						// Checking the options | Assertion check
-	if !ok { return nil, nil, schema.ErrInvalidType }
+// - options [interface{}] ~ Not used by this provider, the app credential is the provider one
+func (p *ProviderSisec) GrantIntent(ctx context.Context, uCred *dto.UserCredIn, _ interface{}) (*dto.AccessTokenData, error, string) {
 
-	tkData := base64.StdEncoding.EncodeToString([]byte(v.SisecClientId + ":" + v.SisecClientPass))                                                                          			// preparing SISEC basic authentication token with app (this) credential data, see the doc they deliver to us | FIX think about generate this token on system startup, this way you don't need to generate on every client login
+	tkData := base64.StdEncoding.EncodeToString([]byte(p.ClientId + ":" + p.ClientPass))                                                                          			// preparing SISEC basic authentication token with app (this) credential data, see the doc they deliver to us | FIX think about generate this token on system startup, this way you don't need to generate on every client login
 	bodyData := "username=" + url.QueryEscape(uCred.Username) + "&password=" + url.QueryEscape(uCred.Password) + "&domain=" + url.QueryEscape(uCred.Domain) + "&grant_type=password" 	// preparing body with user credentials
 
 	// Building the request for grant intent against SISEC | https://medium.com/rungo/making-external-http-requests-in-go-eb4c015f8839
-	req := &http.Request {
-		Method: "POST",
-		URL: p.URL,
-		Header: map[string][]string {
-			"Content-Type": {"application/x-www-form-urlencoded"},
-			"Accept-Encoding": {"gzip, deflate, br"},
-			"Authorization": {"Basic " + tkData},
-		},
-		Body: ioutil.NopCloser(strings.NewReader(bodyData)),
-	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL.String(), strings.NewReader(bodyData))
+	if err != nil { return nil, err, schema.ErrNetwork }
 
-	// requesting the auth, access grant
-	res, err := http.DefaultClient.Do(req)
-	defer res.Body.Close()												// ensuring closing the body reader
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Basic " + tkData)
 
-	// checking what happen with the request
-	if err != nil {
-		return nil, err, schema.ErrNetwork
-	} else if res.StatusCode == iris.StatusNotFound || res.StatusCode == iris.StatusBadRequest {
-		return nil, errors.New(schema.ErrDetHttpResError + " - " + strconv.Itoa(res.StatusCode)), schema.ErrHttpResError
-	}
+	// requesting the auth, access grant. An open circuit breaker (SISEC down) is a network error too
+	res, err := p.Client.Do(req)
+	if err != nil { return nil, err, schema.ErrNetwork }
+	defer res.Body.Close()												// ensuring closing the body reader
 
-	// TODO This code block is temporal, we need to implement the unauthorized (wrong credentials) case, so we need to read the status code response. check the response body
-	rand.Seed(time.Now().UnixNano())
-	if rand.Intn(3) == 0 { return nil, errors.New(schema.ErrDetInvalidCred), schema.ErrUnauthorized }
-	// see crypto/rand for more secure generations
+	// checking what SISEC says
+	if res.StatusCode != iris.StatusOK { return upstreamErr(res, schema.ErrDetInvalidCred) }
 
 	// Parsing and unmarshalling the response options
 	grantData := &dto.SISECGrantIntentIn{} // new(dto.SISECGrantIntentIn)
@@ -85,6 +97,104 @@ func (p *ProviderSisec) GrantIntent(uCred *dto.UserCredIn, options interface{})
 	}
 
 	// Generating the options to be tokenized
-	return grantData, nil, ""
+	return mapper.ToAccessTokenDataV(&grantData.Access_Token), nil, ""
+}
+
+// upstreamErr map an OAuth2 error response (e.g. from SISEC) to the GrantIntent error & code. The rejected app
+// credentials (a 400 or 401 with an OAuth2 invalid_client error) are a misconfigured provider, not the user fault, so
+// they're an err.upstream_config. The rejected user credentials (any other 401, a 403, or a 400 with an OAuth2
+// invalid_grant error) are an err.unauthorized. Upstream failures (5xx) are an err.upstream_unavailable, and any other
+// response an err.http_res_err. The error carries the upstream error description, if any
+//
+// - res [*http.Response] ~ Upstream error response
+//
+// - rejected [string] ~ Error detail for the rejected credentials
+func upstreamErr(res *http.Response, rejected string) (*dto.AccessTokenData, error, string) {
+	body := dto.OAuthErrorIn{}
+	_ = jsoniter.NewDecoder(io.LimitReader(res.Body, maxErrBody)).Decode(&body)	// the body is optional, only for the details
+
+	detail := func(def string) error {
+		if body.Error_Description != "" { return errors.New(def + " - " + body.Error_Description) }
+		return errors.New(def)
+	}
+
+	switch {
+	case (res.StatusCode == iris.StatusUnauthorized || res.StatusCode == iris.StatusBadRequest) && body.Error == oauthInvalidClient:
+		return nil, detail(schema.ErrDetUpstreamConfig), schema.ErrUpstreamConfig
+	case res.StatusCode == iris.StatusUnauthorized || res.StatusCode == iris.StatusForbidden ||
+		(res.StatusCode == iris.StatusBadRequest && body.Error == oauthInvalidGrant):
+		return nil, detail(rejected), schema.ErrUnauthorized
+	case res.StatusCode >= iris.StatusInternalServerError:
+		return nil, detail(schema.ErrDetUpstreamUnavailable + " - " + strconv.Itoa(res.StatusCode)), schema.ErrUpstreamUnavailable
+	default:
+		return nil, detail(schema.ErrDetHttpResError + " - " + strconv.Itoa(res.StatusCode)), schema.ErrHttpResError
+	}
+}
+
+// Check tells if SISEC is reachable. Any HTTP response but a 5xx means reachable. It doesn't feed the SISEC circuit
+// breaker (see lib.WithCheck)
+//
+// - ctx [context.Context] ~ Context for the request, e.g. with a timeout
+func (p *ProviderSisec) Check(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(lib.WithCheck(ctx), http.MethodHead, p.URL.String(), nil)
+	if err != nil { return err }
+
+	res, err := p.Client.Do(req)
+	if err != nil { return err }
+	defer res.Body.Close()
+
+	if res.StatusCode >= iris.StatusInternalServerError { return errors.New(schema.ErrDetHttpResError + " - " + strconv.Itoa(res.StatusCode)) }
+
+	return nil
+}
+// endregion =============================================================================
+
+// region ======== DEFAULT (DATABASE) AUTHENTICATION PROVIDER ============================
+
+// dummyHash is used for comparing the password when the user doesn't exist, this way the response time of a login
+// intent doesn't tell if the username exist or not
+var dummyHash, _ = lib.MkPasswordHash("not.a.real.password")
+
+type ProviderDefault struct {
+	Repo *db.RepoDbUser
+}
+
+// GrantIntent make login / grant (password type) intent against the users stored in the app database, using the
+// given user credentials. The credential domain is ignored.
+//
+// It returns the data to be tokenized (carrying the user's roles), error if any, and a error code to identify the
+// kind of error on the caller.
+//
+// - _ [context.Context] ~ Request context, not used by this provider
+//
+// - uCred [*dto.UserCredIn] ~ User credential
+//
+// - options [interface{}] ~ Not used by this provider
+func (p *ProviderDefault) GrantIntent(_ context.Context, uCred *dto.UserCredIn, _ interface{}) (*dto.AccessTokenData, error, string) {
+
+	user := models.User{Username: uCred.Username}
+	err := (*p.Repo).GetByUsername(&user)
+	missing := errs.Is(err, errs.NotFound)
+	if err != nil && !missing { return nil, err, schema.ErrRepositoryOps }
+
+	hash := user.PasswordHash
+	if missing { hash = dummyHash }
+
+	if !lib.CheckPasswordHash(hash, uCred.Password) || missing {
+		return nil, errors.New(schema.ErrDetInvalidCred), schema.ErrUnauthorized
+	}
+
+	return mapper.ToUserAccessTokenDataV(&user), nil, ""
+}
+
+// Reload get the current data to be tokenized of a user, with its roles & scopes. If the user doesn't exist anymore
+// then err is an errs.NotFound
+//
+// - sub [string] ~ Tokens subject, the username
+func (p *ProviderDefault) Reload(sub string) (*dto.AccessTokenData, error) {
+	user := models.User{Username: sub}
+	if err := (*p.Repo).GetByUsername(&user); err != nil { return nil, err }
+
+	return mapper.ToUserAccessTokenDataV(&user), nil
 }
 // endregion =============================================================================
@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"go.api.backend/lib"
+	"go.api.backend/schema"
+	"go.api.backend/schema/dto"
+	"go.api.backend/service/auth/authtest"
+)
+
+// newTestSisec start a fake SISEC knowing the user "alice", and create the provider pointing to it
+func newTestSisec(t *testing.T) (*authtest.FakeSisec, *ProviderSisec) {
+	t.Helper()
+
+	fake := authtest.NewFakeSisec("app", "app-pass", map[string]authtest.SisecUser{
+		"alice": {Password: "secret", Domain: "web", Rol: "user", Scope: "books:write profile"},
+	})
+	t.Cleanup(fake.Close)
+
+	u, err := url.Parse(fake.URL)
+	if err != nil { t.Fatal(err) }
+
+	return fake, &ProviderSisec{URL: u, ClientId: "app", ClientPass: "app-pass", Client: newTestClient()}
+}
+
+// newTestClient create an outbound client with a tiny backoff, so the retries don't slow down the tests
+func newTestClient() *lib.OutboundClient {
+	return lib.NewOutboundClient(lib.OutboundOpts{Name: "sisec", Backoff: time.Millisecond})
+}
+
+func TestProviderSisec_GrantIntent(t *testing.T) {
+	tests := []struct {
+		name       string
+		cred       dto.UserCredIn
+		clientPass string // Wrong app credential if set
+		failWith   int    // Forced SISEC status
+		closed     bool   // SISEC down
+		wantCode   string
+		wantDetail string
+	}{
+		{name: "granted", cred: dto.UserCredIn{Username: "alice", Password: "secret", Domain: "web"}},
+		{
+			name: "wrong password", cred: dto.UserCredIn{Username: "alice", Password: "nope", Domain: "web"},
+			wantCode: schema.ErrUnauthorized, wantDetail: "Bad credentials",
+		},
+		{
+			name: "unknown user", cred: dto.UserCredIn{Username: "bob", Password: "secret", Domain: "web"},
+			wantCode: schema.ErrUnauthorized,
+		},
+		{
+			name: "wrong app credential", cred: dto.UserCredIn{Username: "alice", Password: "secret", Domain: "web"}, clientPass: "nope",
+			wantCode: schema.ErrUpstreamConfig, wantDetail: "Bad client credentials",
+		},
+		{name: "forbidden", failWith: http.StatusForbidden, wantCode: schema.ErrUnauthorized},
+		{name: "unauthorized", failWith: http.StatusUnauthorized, wantCode: schema.ErrUnauthorized},
+		{name: "SISEC failure", failWith: http.StatusInternalServerError, wantCode: schema.ErrUpstreamUnavailable, wantDetail: "500"},
+		{name: "SISEC unavailable", failWith: http.StatusServiceUnavailable, wantCode: schema.ErrUpstreamUnavailable, wantDetail: "503"},
+		{name: "unexpected response", failWith: http.StatusNotFound, wantCode: schema.ErrHttpResError, wantDetail: "404"},
+		{name: "SISEC down", closed: true, wantCode: schema.ErrNetwork},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, p := newTestSisec(t)
+			if tt.clientPass != "" { p.ClientPass = tt.clientPass }
+			if tt.failWith != 0 { fake.FailWith(tt.failWith) }
+			if tt.closed { fake.Close() }
+
+			data, err, code := p.GrantIntent(context.Background(), &tt.cred, nil)
+
+			if code != tt.wantCode { t.Fatalf("code = %q (%v), want %q", code, err, tt.wantCode) }
+			if tt.wantCode == "" {
+				if err != nil || data.Claims.Sub != "alice" || data.Claims.Rol != "user" || len(data.Scope) != 2 {
+					t.Fatalf("granted data = %+v, %v", data, err)
+				}
+				return
+			}
+
+			if err == nil || data != nil { t.Fatalf("data, err = %+v, %v, want an error", data, err) }
+			if !strings.Contains(err.Error(), tt.wantDetail) { t.Fatalf("err = %q, want it containing %q", err, tt.wantDetail) }
+		})
+	}
+}
+
+func TestProviderSisec_GrantIntent_MalformedBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte("{not json")) }))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	_, err, code := (&ProviderSisec{URL: u, Client: newTestClient()}).GrantIntent(context.Background(), &dto.UserCredIn{}, nil)
+
+	if code != schema.ErrJsonParse || err == nil { t.Fatalf("code, err = %q, %v, want %q", code, err, schema.ErrJsonParse) }
+}
+
+// Only an OAuth2 invalid_client error is a rejected app credential, not the user fault. Any other 401 is the user one
+func TestProviderSisec_GrantIntent_ErrorBody(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		wantCode string
+	}{
+		{name: "400 invalid_client", status: http.StatusBadRequest, body: `{"error": "invalid_client"}`, wantCode: schema.ErrUpstreamConfig},
+		{name: "401 invalid_client", status: http.StatusUnauthorized, body: `{"error": "invalid_client"}`, wantCode: schema.ErrUpstreamConfig},
+		{name: "401 invalid_token", status: http.StatusUnauthorized, body: `{"error": "invalid_token"}`, wantCode: schema.ErrUnauthorized},
+		{name: "401 without body", status: http.StatusUnauthorized, wantCode: schema.ErrUnauthorized},
+		{name: "400 invalid_grant", status: http.StatusBadRequest, body: `{"error": "invalid_grant"}`, wantCode: schema.ErrUnauthorized},
+		{name: "400 invalid_request", status: http.StatusBadRequest, body: `{"error": "invalid_request"}`, wantCode: schema.ErrHttpResError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			u, _ := url.Parse(srv.URL)
+			_, err, code := (&ProviderSisec{URL: u, Client: newTestClient()}).GrantIntent(context.Background(), &dto.UserCredIn{}, nil)
+
+			if code != tt.wantCode || err == nil { t.Fatalf("code, err = %q, %v, want %q", code, err, tt.wantCode) }
+		})
+	}
+}
+
+// The SISEC request is canceled together with the login request
+func TestProviderSisec_GrantIntent_Canceled(t *testing.T) {
+	_, p := newTestSisec(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err, code := p.GrantIntent(ctx, &dto.UserCredIn{Username: "alice", Password: "secret", Domain: "web"}, nil)
+	if code != schema.ErrNetwork || !errors.Is(err, context.Canceled) { t.Fatalf("canceled intent = %q, %v, want %q", code, err, schema.ErrNetwork) }
+}
+
+// SISEC down for good, the circuit breaker opens and the next intents are short-circuited to err.network
+func TestProviderSisec_GrantIntent_CircuitBreaker(t *testing.T) {
+	fake, p := newTestSisec(t)
+	p.Client = lib.NewOutboundClient(lib.OutboundOpts{Name: "sisec", Backoff: time.Millisecond, BreakerThreshold: 3, BreakerCooldown: time.Hour})
+	fake.FailWith(http.StatusServiceUnavailable)
+
+	cred := &dto.UserCredIn{Username: "alice", Password: "secret", Domain: "web"}
+	for i := 0; i < 3; i++ {
+		if _, _, code := p.GrantIntent(context.Background(), cred, nil); code != schema.ErrUpstreamUnavailable { t.Fatalf("intent %d code = %q, want %q", i, code, schema.ErrUpstreamUnavailable) }
+	}
+	if fake.Calls() != 3 { t.Fatalf("SISEC calls = %d, want 3 (the grant isn't retried)", fake.Calls()) }
+
+	// the readiness checks neither are short-circuited nor close the circuit
+	if err := p.Check(context.Background()); err == nil { t.Fatal("check of a failing SISEC = nil, want an error") }
+	fake.FailWith(0)
+	if err := p.Check(context.Background()); err != nil { t.Fatalf("check = %v", err) }
+
+	calls := fake.Calls()
+	_, err, code := p.GrantIntent(context.Background(), cred, nil)
+	if code != schema.ErrNetwork || err != lib.ErrCircuitOpen { t.Fatalf("intent with open circuit = %q, %v, want %q", code, err, schema.ErrNetwork) }
+	if fake.Calls() != calls { t.Fatalf("SISEC calls with open circuit = %d, want %d", fake.Calls(), calls) }
+}
@@ -1,40 +1,197 @@
 package auth
 
 import (
+	"errors"
+	"fmt"
 	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
+	"go.api.backend/lib"
+	"go.api.backend/repo/db"
+	"go.api.backend/schema"
+	"go.api.backend/schema/dto"
 	"go.api.backend/service/utils"
 )
 
 type SvcAuthentication struct {
 	AuthProviders map[string]Provider 			// similar to slices, maps are reference types.
+	confs         []*utils.AuthProviderConf		// Enabled providers declarations, in the configured order
 }
 
-// NewSvcAuthentication creates the authentication service. It provides the methods to make the
-// authentication intent with the register providers.
+// region ======== PROVIDERS REGISTRY ====================================================
+
+// ProviderDeps are the app dependencies available to the provider factories
+type ProviderDeps struct {
+	Config *utils.SvcConfig	// App conf, e.g. for the outbound clients settings
+	Users  *db.RepoDbUser	// Users repository, e.g. for the default provider
+}
+
+// ProviderFactory create a provider from its declaration (AuthProviders setting)
+type ProviderFactory func(conf *utils.AuthProviderConf, deps *ProviderDeps) (Provider, error)
+
+// reservedNames are the /auth routes the providers names can't shadow
+var reservedNames = map[string]bool{"providers": true, "refresh": true, "register": true, "password": true, "protected": true, "logout": true}
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = make(map[string]ProviderFactory)
+)
+
+func init() {
+	RegisterProvider("default", newProviderDefault)
+	RegisterProvider("sisec", newProviderSisec)
+	RegisterProvider("oidc", newProviderOIDC)
+}
+
+// RegisterProvider make a provider type available for the AuthProviders setting, so a new Provider implementation
+// plugs in without touching the authentication service. It's meant to be called on init, and panics if the type is
+// already registered or the factory is nil
+//
+// - typ [string] ~ Provider type, e.g. sisec
 //
-// - providers [Array] ~ Maps of providers string token / identifiers
+// - factory [ProviderFactory] ~ Provider factory
+func RegisterProvider(typ string, factory ProviderFactory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+
+	if factory == nil { panic("auth: nil factory for the provider type " + typ) }
+	if _, dup := factories[typ]; dup { panic("auth: provider type " + typ + " registered twice") }
+
+	factories[typ] = factory
+}
+
+// ProviderTypes get the registered provider types, sorted
+func ProviderTypes() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+
+	types := make([]string, 0, len(factories))
+	for typ := range factories { types = append(types, typ) }
+	sort.Strings(types)
+
+	return types
+}
+// endregion =============================================================================
+
+// NewSvcAuthentication creates the authentication service. It provides the methods to make the
+// authentication intent with the enabled providers declared in the configuration (AuthProviders setting),
+// created by their type factory (see RegisterProvider).
 //
 // - svcConfig [*SvcConfig] ~ App conf instance pointer
-func NewSvcAuthentication(providers map[string]bool, svcConfig *utils.SvcConfig) *SvcAuthentication {
+//
+// - userRepo [*db.RepoDbUser] ~ Users repository, used by the default (database) provider
+func NewSvcAuthentication(svcConfig *utils.SvcConfig, userRepo *db.RepoDbUser) (*SvcAuthentication, error) {
 
 	k := &SvcAuthentication{AuthProviders: make(map[string]Provider)}
+	deps := &ProviderDeps{Config: svcConfig, Users: userRepo}
+
+	for i := range svcConfig.AuthProviders {
+		c := &svcConfig.AuthProviders[i]
+		if !c.Enabled { continue }
+		if _, dup := k.AuthProviders[c.Name]; dup { return nil, fmt.Errorf("auth provider %s: declared twice", c.Name) }
+		if reservedNames[c.Name] { return nil, fmt.Errorf("auth provider %s: reserved name, it's an /auth route", c.Name) }
+
+		factoriesMu.RLock()
+		factory, ok := factories[c.Type]
+		factoriesMu.RUnlock()
+		if !ok { return nil, fmt.Errorf("auth provider %s: unknown type %q, must be one of %s", c.Name, c.Type, strings.Join(ProviderTypes(), ", ")) }
+
+		p, err := factory(c, deps)
+		if err != nil { return nil, fmt.Errorf("auth provider %s: %w", c.Name, err) }
 
-	for v, _ := range providers {
+		k.AuthProviders[c.Name] = p
+		k.confs = append(k.confs, c)
+	}
+
+	return k, nil
+}
+
+// Enabled get the enabled providers, in the configured order, with their login flow. For the login UIs
+func (s *SvcAuthentication) Enabled() []dto.AuthProviderOut {
+	out := make([]dto.AuthProviderOut, 0, len(s.confs))
+	for _, c := range s.confs { out = append(out, providerOut(c.Name, c.Type, c.Label, s.AuthProviders[c.Name])) }
+
+	return out
+}
 
-		if v == "sisec" {							// ===== SISEC CASE =======
-			_url, err := url.Parse(svcConfig.SisecUrl)
-			if err != nil { panic(err) }
+// providerOut describe an enabled provider, its label is the name if it has none
+func providerOut(name string, typ string, label string, p Provider) dto.AuthProviderOut {
+	if label == "" { label = name }
 
-			(*k).AuthProviders[v] = &ProviderSisec {
-				URL:        _url,
-				ClientId:   svcConfig.SisecClientId,
-				ClientPass: svcConfig.SisecClientPass,
-			}
-		} else if v == "default" {					// ===== DEFAULT CASE, NORMAL DATABASE LOGIN =======
-			// TODO implement normal database login authentication case
-		}
+	if _, ok := p.(RedirectProvider); ok {
+		return dto.AuthProviderOut{Name: name, Type: typ, Label: label, Flow: schema.AuthFlowRedirect, LoginUrl: "/auth/" + name + "/login"}
 	}
+	return dto.AuthProviderOut{Name: name, Type: typ, Label: label, Flow: schema.AuthFlowPassword, LoginUrl: "/auth/" + name}
+}
+
+// region ======== BUILT-IN PROVIDERS FACTORIES ==========================================
+
+// newProviderDefault create the default (database) provider, over the app users repository
+func newProviderDefault(_ *utils.AuthProviderConf, deps *ProviderDeps) (Provider, error) {
+	if deps.Users == nil { return nil, errors.New("the users repository is required") }
+
+	return &ProviderDefault{Repo: deps.Users}, nil
+}
+
+// newProviderSisec create a SISEC provider, its Url is the SISEC grant endpoint
+func newProviderSisec(c *utils.AuthProviderConf, deps *ProviderDeps) (Provider, error) {
+	_url, err := url.Parse(c.Url)
+	if err != nil || !_url.IsAbs() { return nil, errors.New("an absolute Url is required") }
+	if c.ClientId == "" { return nil, errors.New("the ClientId is required") }
 
-	return k
+	return &ProviderSisec {
+		URL:        _url,
+		ClientId:   c.ClientId,
+		ClientPass: c.ClientSecret,
+		Client:     lib.NewOutboundClient(outboundOpts(c.Name, c.Timeout, deps.Config)),
+	}, nil
+}
+
+// newProviderOIDC create an OpenID Connect provider, its Url is the issuer. The provider roles & scopes only grant
+// the app roles & scopes of its RoleMap & ScopeMap
+func newProviderOIDC(c *utils.AuthProviderConf, deps *ProviderDeps) (Provider, error) {
+	if c.Url == "" || c.ClientId == "" || c.RedirectUrl == "" { return nil, errors.New("the Url (issuer), ClientId and RedirectUrl are required") }
+
+	scopes := strings.Fields(c.Scopes)
+	if len(scopes) == 0 { scopes = []string{"openid", "profile"} }
+	if len(c.RoleMap) > 0 && c.RolClaim == "" { return nil, errors.New("the RolClaim is required by the RoleMap") }
+	scopeClaim := c.ScopeClaim
+	if scopeClaim == "" { scopeClaim = "scope" }
+
+	return &ProviderOIDC {
+		Name:         c.Name,
+		Issuer:       c.Url,
+		ClientId:     c.ClientId,
+		ClientSecret: c.ClientSecret,
+		RedirectURL:  c.RedirectUrl,
+		Scopes:       scopes,
+		RolClaim:     c.RolClaim,
+		RoleMap:      c.RoleMap,
+		ScopeClaim:   scopeClaim,
+		ScopeMap:     c.ScopeMap,
+		Client:       lib.NewOutboundClient(outboundOpts(c.Name, c.Timeout, deps.Config)),
+	}, nil
+}
+// endregion =============================================================================
+
+// outboundOpts get the outbound client settings of a provider, from the configuration
+//
+// - name [string] ~ Provider name, for the metrics
+//
+// - timeout [uint16] ~ Provider per attempt timeout, in seconds. 10 by default
+//
+// - svcConfig [*SvcConfig] ~ App conf instance pointer
+func outboundOpts(name string, timeout uint16, svcConfig *utils.SvcConfig) lib.OutboundOpts {
+	if timeout == 0 { timeout = 10 }
+
+	return lib.OutboundOpts{
+		Name:             name,
+		Timeout:          time.Duration(timeout) * time.Second,
+		MaxAttempts:      int(svcConfig.OutboundMaxAttempts),
+		BreakerThreshold: int(svcConfig.OutboundBreakerThreshold),
+		BreakerCooldown:  time.Duration(svcConfig.OutboundBreakerCooldown) * time.Second,
+	}
 }
@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.api.backend/repo/mem"
+	"go.api.backend/schema"
+	"go.api.backend/schema/dto"
+	"go.api.backend/service/utils"
+)
+
+// providerStub is a pluggable provider, registered as the "stub" type
+type providerStub struct{ conf *utils.AuthProviderConf }
+
+func (p *providerStub) GrantIntent(context.Context, *dto.UserCredIn, interface{}) (*dto.AccessTokenData, error, string) {
+	return &dto.AccessTokenData{Claims: dto.Claims{Sub: p.conf.ClientId}}, nil, ""
+}
+
+func init() {
+	RegisterProvider("stub", func(c *utils.AuthProviderConf, _ *ProviderDeps) (Provider, error) { return &providerStub{c}, nil })
+}
+
+// testUsers is the users repository of the default providers
+var testUsers = mem.NewRepoMemUser()
+
+// testAuthConf create a conf declaring the given providers
+func testAuthConf(providers ...utils.AuthProviderConf) *utils.SvcConfig {
+	svcC := &utils.SvcConfig{}
+	svcC.AuthProviders = providers
+
+	return svcC
+}
+
+func TestNewSvcAuthentication(t *testing.T) {
+	sisec := utils.AuthProviderConf{Type: "sisec", Name: "sisec", Enabled: true, Url: "https://sisec.test/login", ClientId: "app"}
+	oidc := utils.AuthProviderConf{Type: "oidc", Name: "acme", Label: "Acme SSO", Enabled: true, Url: "https://sso.test", ClientId: "app", RedirectUrl: "https://app.test/auth/acme/callback"}
+
+	tests := []struct {
+		name      string
+		providers []utils.AuthProviderConf
+		wantNames []string
+		wantErr   string
+	}{
+		{name: "built-ins", providers: []utils.AuthProviderConf{oidc, sisec, {Type: "default", Name: "default", Enabled: true}}, wantNames: []string{"acme", "sisec", "default"}},
+		{name: "disabled skipped", providers: []utils.AuthProviderConf{sisec, {Type: "default", Name: "default"}}, wantNames: []string{"sisec"}},
+		{name: "plugged type", providers: []utils.AuthProviderConf{{Type: "stub", Name: "corp", Enabled: true, ClientId: "bob"}}, wantNames: []string{"corp"}},
+		{name: "unknown type", providers: []utils.AuthProviderConf{{Type: "saml", Name: "corp", Enabled: true}}, wantErr: `unknown type "saml"`},
+		{name: "duplicate name", providers: []utils.AuthProviderConf{sisec, sisec}, wantErr: "declared twice"},
+		{name: "reserved name", providers: []utils.AuthProviderConf{{Type: "default", Name: "refresh", Enabled: true}}, wantErr: "reserved name"},
+		{name: "SISEC without url", providers: []utils.AuthProviderConf{{Type: "sisec", Name: "sisec", Enabled: true, ClientId: "app"}}, wantErr: "Url is required"},
+		{
+			name: "OIDC role map without roles claim", wantErr: "RolClaim is required",
+			providers: []utils.AuthProviderConf{{Type: "oidc", Name: "acme", Enabled: true, Url: "https://sso.test", ClientId: "app", RedirectUrl: "https://app.test/auth/acme/callback", RoleMap: map[string]string{"a": "admin"}}},
+		},
+		{name: "OIDC without redirect url", providers: []utils.AuthProviderConf{{Type: "oidc", Name: "acme", Enabled: true, Url: "https://sso.test", ClientId: "app"}}, wantErr: "RedirectUrl"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svcA, err := NewSvcAuthentication(testAuthConf(tt.providers...), &testUsers)
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) { t.Fatalf("err = %v, want it containing %q", err, tt.wantErr) }
+				return
+			}
+			if err != nil { t.Fatal(err) }
+
+			enabled := svcA.Enabled()
+			if len(enabled) != len(tt.wantNames) { t.Fatalf("enabled = %+v, want %v", enabled, tt.wantNames) }
+			for i, name := range tt.wantNames {
+				if enabled[i].Name != name { t.Fatalf("enabled[%d] = %s, want %s (configured order)", i, enabled[i].Name, name) }
+			}
+		})
+	}
+}
+
+func TestSvcAuthentication_Enabled(t *testing.T) {
+	svcA, err := NewSvcAuthentication(testAuthConf(
+		utils.AuthProviderConf{Type: "oidc", Name: "acme", Label: "Acme SSO", Enabled: true, Url: "https://sso.test", ClientId: "app", RedirectUrl: "https://app.test/auth/acme/callback"},
+		utils.AuthProviderConf{Type: "stub", Name: "corp", Enabled: true, ClientId: "bob"},
+	), &testUsers)
+	if err != nil { t.Fatal(err) }
+
+	want := []dto.AuthProviderOut{
+		{Name: "acme", Type: "oidc", Label: "Acme SSO", Flow: schema.AuthFlowRedirect, LoginUrl: "/auth/acme/login"},
+		{Name: "corp", Type: "stub", Label: "corp", Flow: schema.AuthFlowPassword, LoginUrl: "/auth/corp"},
+	}
+	for i, got := range svcA.Enabled() {
+		if got != want[i] { t.Fatalf("enabled[%d] = %+v, want %+v", i, got, want[i]) }
+	}
+
+	// the plugged provider is the one used for the logins
+	data, _, _ := svcA.AuthProviders["corp"].GrantIntent(context.Background(), &dto.UserCredIn{}, nil)
+	if data.Claims.Sub != "bob" { t.Fatalf("plugged provider grant = %+v", data) }
+}
+
+func TestRegisterProvider_Twice(t *testing.T) {
+	defer func() {
+		if recover() == nil { t.Fatal("registering a type twice must panic") }
+	}()
+
+	RegisterProvider("sisec", newProviderSisec)
+}
@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"time"
+
+	"go.api.backend/lib"
+	"go.api.backend/repo/db"
+	"go.api.backend/schema"
+	"go.api.backend/schema/dto"
+	"go.api.backend/schema/errs"
+	"go.api.backend/schema/models"
+	"go.api.backend/service/utils"
+)
+
+// errInvalidRefreshTk is the error for any invalid, expired, reused or revoked refresh token
+var errInvalidRefreshTk = errs.New(errs.Unauthorized, schema.ErrUnauthorized, schema.ErrDetInvalidRefreshTk)
+
+// SvcToken is the service issuing the access & refresh tokens pairs, and rotating the refresh tokens
+type SvcToken interface {
+	Issue(provider string, data *dto.AccessTokenData) (*dto.TokenOut, error)
+	Refresh(refreshToken string) (*dto.TokenOut, error)
+	Revoke(sub string) error
+	JWKS() *dto.JWKSOut
+}
+
+type svcToken struct {
+	pRepo   *db.RepoDbRefreshToken
+	appConf *utils.SvcConfig
+	keys    *lib.Keyring
+	svcA    *SvcAuthentication
+}
+
+// NewSvcToken create the tokens service. The refresh tokens are opaque, server-stored (hashed) and single use;
+// every rotation issue a new one in the same family, expiring with the login one.
+//
+// - pRepo [*db.RepoDbRefreshToken] ~ Repository instance pointer
+//
+// - svcConfig [*SvcConfig] ~ App conf instance pointer
+//
+// - keys [*lib.Keyring] ~ Access tokens keyring, signing the access tokens
+//
+// - svcA [*SvcAuthentication] ~ Authentication service instance, its providers reload the users on rotation
+func NewSvcToken(pRepo *db.RepoDbRefreshToken, svcConfig *utils.SvcConfig, keys *lib.Keyring, svcA *SvcAuthentication) SvcToken {
+	return &svcToken{pRepo, svcConfig, keys, svcA}
+}
+
+// Issue create a new access & refresh tokens pair for a granted authentication. The refresh token starts a new family,
+// lasting RefreshTkMaxAge hours
+//
+// - provider [string] ~ Name of the auth provider that granted the authentication
+//
+// - data [*dto.AccessTokenData] ~ Data to be tokenized
+func (s *svcToken) Issue(provider string, data *dto.AccessTokenData) (*dto.TokenOut, error) {
+	family, err := lib.MkRandomToken(16)
+	if err != nil { return nil, err }
+
+	expiresAt := time.Now().Add(time.Duration(s.appConf.RefreshTkMaxAge) * time.Hour)
+	return s.mkPair(data, provider, family, expiresAt)
+}
+
+// Refresh rotate the given refresh token, returning a new access & refresh tokens pair. If the token was already
+// used (reuse detection) or revoked, the whole token family is revoked. The users of the providers storing them (see
+// Reloader) get their current roles & scopes, the other ones keep the login ones. Any invalid, expired, reused or
+// revoked token, or a token of a no longer enabled provider or user, result in an errs.Unauthorized error
+//
+// - refreshToken [string] ~ Refresh token to be rotated
+func (s *svcToken) Refresh(refreshToken string) (*dto.TokenOut, error) {
+	tk := models.RefreshToken{TokenHash: lib.MkTokenHash(refreshToken)}
+
+	if err := (*s.pRepo).GetByHash(&tk); errs.Is(err, errs.NotFound) {
+		return nil, errInvalidRefreshTk
+	} else if err != nil {
+		return nil, err
+	}
+
+	// A used or revoked token means it was leaked, so the whole family (including the legit last token) is revoked
+	if !tk.UsedAt.IsZero() || !tk.RevokedAt.IsZero() {
+		if _, err := (*s.pRepo).RevokeFamily(tk.FamilyId); err != nil { return nil, err }
+		return nil, errInvalidRefreshTk
+	}
+
+	if time.Now().After(tk.ExpiresAt) { return nil, errInvalidRefreshTk }
+
+	data, err := s.reload(&tk)
+	if err != nil { return nil, err }
+
+	// Marking as used, a 0 means a concurrent reuse won the race
+	if n, err := (*s.pRepo).MarkUsed(&tk); err != nil {
+		return nil, err
+	} else if n == 0 {
+		if _, err := (*s.pRepo).RevokeFamily(tk.FamilyId); err != nil { return nil, err }
+		return nil, errInvalidRefreshTk
+	}
+
+	return s.mkPair(data, tk.Provider, tk.FamilyId, tk.ExpiresAt)
+}
+
+// Revoke revoke all the refresh tokens of a user, from every login, e.g. on logout
+//
+// - sub [string] ~ Tokens subject, the user identifier
+func (s *svcToken) Revoke(sub string) error {
+	_, err := (*s.pRepo).RevokeBySub(sub)
+	return err
+}
+
+// JWKS get the access tokens verification keys, for the downstream services. Empty with the HS256 (shared) key
+func (s *svcToken) JWKS() *dto.JWKSOut {
+	return s.keys.JWKS()
+}
+
+// reload get the data to be tokenized of a refresh token rotation, the current one for the users of a Reloader
+// provider. A disabled provider or a deleted user revokes the token family
+func (s *svcToken) reload(tk *models.RefreshToken) (*dto.AccessTokenData, error) {
+	p, ok := s.svcA.AuthProviders[tk.Provider]
+	if !ok {
+		if _, err := (*s.pRepo).RevokeFamily(tk.FamilyId); err != nil { return nil, err }
+		return nil, errInvalidRefreshTk
+	}
+
+	r, ok := p.(Reloader)
+	if !ok { return &dto.AccessTokenData{Scope: tk.Scope, Claims: dto.Claims{Sub: tk.Sub, Rol: tk.Rol}}, nil }
+
+	data, err := r.Reload(tk.Sub)
+	if errs.Is(err, errs.NotFound) {
+		if _, err := (*s.pRepo).RevokeFamily(tk.FamilyId); err != nil { return nil, err }
+		return nil, errInvalidRefreshTk
+	}
+
+	return data, err
+}
+
+// mkPair create and store a refresh token in the given family, and sign the access token
+func (s *svcToken) mkPair(data *dto.AccessTokenData, provider string, family string, expiresAt time.Time) (*dto.TokenOut, error) {
+	accessToken, err := lib.MkAccessToken(data, s.keys, s.appConf.TkMaxAge)
+	if err != nil { return nil, err }
+
+	refreshToken, err := lib.MkRandomToken(32)
+	if err != nil { return nil, err }
+
+	tk := models.RefreshToken{
+		TokenHash: lib.MkTokenHash(refreshToken),
+		FamilyId:  family,
+		Sub:       data.Claims.Sub,
+		Provider:  provider,
+		Rol:       data.Claims.Rol,
+		Scope:     data.Scope,
+		ExpiresAt: expiresAt,
+	}
+	if err := (*s.pRepo).Add(&tk); err != nil { return nil, err }
+
+	return &dto.TokenOut{
+		AccessToken:  string(accessToken),
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    uint(s.appConf.TkMaxAge) * 60,
+	}, nil
+}
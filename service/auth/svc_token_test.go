@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"go.api.backend/lib"
+	"go.api.backend/repo/mem"
+	"go.api.backend/schema"
+	"go.api.backend/schema/dto"
+	"go.api.backend/schema/models"
+	"go.api.backend/service/utils"
+)
+
+// newTestSvcToken create a tokens service with the "default" provider (knowing the user "dave", who got the write
+// scope after the login) and the "corp" stub provider, storing the refresh tokens in memory
+func newTestSvcToken(t *testing.T) (SvcToken, *svcToken) {
+	t.Helper()
+
+	users := mem.NewRepoMemUser()
+	dave := models.User{Username: "dave", Roles: []string{schema.RolUser}}
+	if err := users.Add(&dave); err != nil { t.Fatal(err) }
+	dave.Scopes = []string{schema.ScopeBooksWrite}
+	if _, err := users.Update(&dave, "scopes"); err != nil { t.Fatal(err) }
+
+	svcC := testAuthConf(
+		utils.AuthProviderConf{Type: "default", Name: "default", Enabled: true},
+		utils.AuthProviderConf{Type: "stub", Name: "corp", Enabled: true, ClientId: "bob"},
+	)
+	svcC.TkMaxAge, svcC.RefreshTkMaxAge = 5, 1
+	svcA, err := NewSvcAuthentication(svcC, &users)
+	if err != nil { t.Fatal(err) }
+
+	keys, err := lib.LoadKeyring(nil, []byte("a.test.signing.key.of.32.bytes!!"), time.Time{})
+	if err != nil { t.Fatal(err) }
+
+	tokens := mem.NewRepoMemRefreshToken()
+	s := NewSvcToken(&tokens, svcC, keys, svcA)
+	return s, s.(*svcToken)
+}
+
+func TestSvcToken_Refresh(t *testing.T) {
+	tests := []struct {
+		name      string
+		provider  string
+		sub       string
+		wantScope []string
+		wantErr   bool
+	}{
+		{name: "reloaded user", provider: "default", sub: "dave", wantScope: []string{schema.ScopeBooksWrite}},
+		{name: "login data kept", provider: "corp", sub: "bob", wantScope: []string{"corp:read"}},
+		{name: "deleted user", provider: "default", sub: "erin", wantErr: true},
+		{name: "disabled provider", provider: "sisec", sub: "alice", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, impl := newTestSvcToken(t)
+
+			login, err := s.Issue(tt.provider, &dto.AccessTokenData{Scope: []string{"corp:read"}, Claims: dto.Claims{Sub: tt.sub, Rol: schema.RolUser}})
+			if err != nil { t.Fatal(err) }
+
+			refreshed, err := s.Refresh(login.RefreshToken)
+			if tt.wantErr {
+				if err == nil { t.Fatal("Refresh() error = nil, want an invalid refresh token") }
+				return
+			}
+			if err != nil { t.Fatalf("Refresh() error = %v", err) }
+
+			verified, err := impl.keys.Verify([]byte(refreshed.AccessToken))
+			if err != nil { t.Fatal(err) }
+			var claims dto.AccessTokenData
+			if err := verified.Claims(&claims); err != nil { t.Fatal(err) }
+			if len(claims.Scope) != len(tt.wantScope) || claims.Scope[0] != tt.wantScope[0] {
+				t.Errorf("refreshed Scope = %v, want %v", claims.Scope, tt.wantScope)
+			}
+
+			// the rotated token keeps the login expiry
+			first, last := models.RefreshToken{TokenHash: lib.MkTokenHash(login.RefreshToken)}, models.RefreshToken{TokenHash: lib.MkTokenHash(refreshed.RefreshToken)}
+			if err := (*impl.pRepo).GetByHash(&first); err != nil { t.Fatal(err) }
+			if err := (*impl.pRepo).GetByHash(&last); err != nil { t.Fatal(err) }
+			if !last.ExpiresAt.Equal(first.ExpiresAt) { t.Errorf("rotated ExpiresAt = %v, want the login one %v", last.ExpiresAt, first.ExpiresAt) }
+		})
+	}
+}
+
+func TestSvcToken_Revoke(t *testing.T) {
+	s, _ := newTestSvcToken(t)
+
+	var logins []*dto.TokenOut
+	for i := 0; i < 2; i++ {
+		login, err := s.Issue("default", &dto.AccessTokenData{Claims: dto.Claims{Sub: "dave", Rol: schema.RolUser}})
+		if err != nil { t.Fatal(err) }
+		logins = append(logins, login)
+	}
+
+	if err := s.Revoke("dave"); err != nil { t.Fatal(err) }
+
+	for _, login := range logins {
+		if _, err := s.Refresh(login.RefreshToken); err == nil { t.Error("Refresh() error = nil, want the revoked token rejected") }
+	}
+}
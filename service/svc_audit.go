@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+
+	"go.api.backend/repo/db"
+	"go.api.backend/schema"
+	"go.api.backend/schema/dto"
+	"go.api.backend/schema/models"
+)
+
+// SvcAudit is the service for reading the audit log. The entries are written by the other services, in the same
+// transaction than the audited mutation (see recordAudit)
+type SvcAudit interface {
+	GetAll(filter *dto.AuditFilter, opts *dto.QueryOpts) ([]models.AuditEntry, int, error)
+}
+
+type svcAudit struct {
+	pRepo *db.RepoDbAudit
+}
+
+// AuditActor is who is doing a mutation, it's carried by the context given to the services write methods (see
+// WithAuditActor)
+type AuditActor struct {
+	Sub       string // Access token subject
+	RequestId string // X-Request-ID of the request
+}
+
+// auditActorKey the context key of the AuditActor
+type auditActorKey struct{}
+
+// NewSvcAudit create the service for reading the audit log
+//
+// - pRepo [*db.RepoDbAudit] ~ Repository instance pointer
+func NewSvcAudit(pRepo *db.RepoDbAudit) SvcAudit {
+	return &svcAudit{pRepo}
+}
+
+// GetAll get a page of audit entries, newest first. Return the entries and the total amount of entries matching
+// the filter
+//
+// - filter [*dto.AuditFilter] ~ Filtering criteria
+//
+// - opts [*dto.QueryOpts] ~ Offset pagination options
+func (s *svcAudit) GetAll(filter *dto.AuditFilter, opts *dto.QueryOpts) ([]models.AuditEntry, int, error) {
+	list := make([]models.AuditEntry, 0)
+	total, err := (*s.pRepo).GetAll(&list, filter, opts)
+
+	return list, total, err
+}
+
+// WithAuditActor get a copy of the context carrying the actor, so the mutations done with it are attributed to
+// the actor in the audit log
+//
+// - ctx [context.Context] ~ Parent context, e.g. the request one
+//
+// - actor [AuditActor] ~ Who is doing the mutations
+func WithAuditActor(ctx context.Context, actor AuditActor) context.Context {
+	return context.WithValue(ctx, auditActorKey{}, actor)
+}
+
+// recordAudit add an entry to the audit log through the unit of work transaction. The actor comes from the context,
+// it's the "system" one if there is none. The before & after snapshots are marshaled to JSON, nil means null
+func recordAudit(ctx context.Context, tx db.RepoTx, action string, entity string, id uint, before, after interface{}) error {
+	actor, _ := ctx.Value(auditActorKey{}).(AuditActor)
+	if actor.Sub == "" { actor.Sub = schema.AuditSystemActor }
+
+	entry := models.AuditEntry{Actor: actor.Sub, Action: action, Entity: entity, EntityId: id, RequestId: actor.RequestId}
+
+	var err error
+	if entry.Before, err = auditSnapshot(before); err != nil { return err }
+	if entry.After, err = auditSnapshot(after); err != nil { return err }
+
+	return tx.Audits().Add(&entry)
+}
+
+// auditSnapshot marshal an entity to JSON, nil (or a nil pointer) is kept as nil
+func auditSnapshot(v interface{}) (json.RawMessage, error) {
+	if v == nil { return nil, nil }
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() { return nil, nil }
+
+	return json.Marshal(v)
+}
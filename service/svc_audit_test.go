@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"go.api.backend/schema"
+	"go.api.backend/schema/dto"
+	"go.api.backend/schema/models"
+)
+
+// auditOf get all the audit entries of a book, newest first
+func auditOf(t *testing.T, svcA SvcAudit, id uint) []models.AuditEntry {
+	t.Helper()
+
+	list, _, err := svcA.GetAll(&dto.AuditFilter{Entity: schema.AuditEntityBook, EntityId: id}, &dto.QueryOpts{Page: 1, Limit: 100})
+	if err != nil { t.Fatalf("getting the audit entries of book %d: %v", id, err) }
+
+	return list
+}
+
+// snapshotName get the book name of an audit snapshot, "" if the snapshot is null
+func snapshotName(t *testing.T, raw json.RawMessage) string {
+	t.Helper()
+
+	if raw == nil { return "" }
+
+	var book models.Book
+	if err := json.Unmarshal(raw, &book); err != nil { t.Fatalf("unmarshaling the snapshot %s: %v", raw, err) }
+
+	return book.Name
+}
+
+func TestSvcAudit_BookMutations(t *testing.T) {
+	tests := []struct {
+		name       string
+		mutate     func(t *testing.T, svc SvcBook)
+		wantAction string
+		wantBefore string // Book name before, "" for null
+		wantAfter  string // Book name after, "" for null
+	}{
+		{
+			name:       "create",
+			mutate:     func(t *testing.T, svc SvcBook) {},
+			wantAction: schema.AuditCreate, wantAfter: "Dune",
+		},
+		{
+			name: "update",
+			mutate: func(t *testing.T, svc SvcBook) {
+				_, err := svc.UpdateBook(testCtx, &models.Book{Id: 1, Name: "Dune Messiah"})
+				checkErr(t, err, nil)
+			},
+			wantAction: schema.AuditUpdate, wantBefore: "Dune", wantAfter: "Dune Messiah",
+		},
+		{
+			name:       "delete",
+			mutate:     func(t *testing.T, svc SvcBook) { trash(t, svc, 1) },
+			wantAction: schema.AuditDelete, wantBefore: "Dune",
+		},
+		{
+			name: "restore",
+			mutate: func(t *testing.T, svc SvcBook) {
+				trash(t, svc, 1)
+				_, err := svc.Restore(testCtx, uintP(1))
+				checkErr(t, err, nil)
+			},
+			wantAction: schema.AuditRestore, wantBefore: "Dune", wantAfter: "Dune",
+		},
+		{
+			name: "purge",
+			mutate: func(t *testing.T, svc SvcBook) {
+				trash(t, svc, 1)
+				_, err := svc.PurgeByID(testCtx, uintP(1))
+				checkErr(t, err, nil)
+			},
+			wantAction: schema.AuditPurge, wantBefore: "Dune",
+		},
+		{
+			name: "purge trash",
+			mutate: func(t *testing.T, svc SvcBook) {
+				trash(t, svc, 1)
+				_, err := svc.PurgeTrash(testCtx)
+				checkErr(t, err, nil)
+			},
+			wantAction: schema.AuditPurge, wantBefore: "Dune",
+		},
+		{
+			name: "upsert of an existing book",
+			mutate: func(t *testing.T, svc SvcBook) {
+				_, outcomes, err := svc.UpsertBatch(testCtx, []models.Book{{Name: "DUNE", Items: 9}})
+				checkErr(t, err, nil)
+				checkErr(t, outcomes[0], nil)
+			},
+			wantAction: schema.AuditUpdate, wantBefore: "Dune", wantAfter: "Dune",	// Found by name, it keeps its name
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, svcA := newTestSvcs(t, models.Book{Name: "Dune", Items: 3})
+			tt.mutate(t, svc)
+
+			entries := auditOf(t, svcA, 1)
+			if len(entries) == 0 { t.Fatal("no audit entries") }
+
+			got := entries[0]
+			if got.Action != tt.wantAction || got.Actor != "tester" || got.RequestId != "req-1" || got.Entity != schema.AuditEntityBook {
+				t.Fatalf("entry = %s %s/%d by %s (%s), want %s book/1 by tester (req-1)", got.Action, got.Entity, got.EntityId, got.Actor, got.RequestId, tt.wantAction)
+			}
+			if b, a := snapshotName(t, got.Before), snapshotName(t, got.After); b != tt.wantBefore || a != tt.wantAfter {
+				t.Fatalf("before, after = %q, %q, want %q, %q", b, a, tt.wantBefore, tt.wantAfter)
+			}
+		})
+	}
+}
+
+// The audit entries share the transaction of the mutation: the failed items (or the whole atomic batch) leave none
+func TestSvcAudit_Batch(t *testing.T) {
+	tests := []struct {
+		name     string
+		atomic   bool
+		wantBook map[uint]int // Amount of entries per book Id
+	}{
+		{name: "best-effort", wantBook: map[uint]int{1: 1, 2: 1}},
+		{name: "atomic", atomic: true, wantBook: map[uint]int{1: 1, 2: 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, svcA := newTestSvcs(t, models.Book{Name: "The Hobbit"})
+
+			_, err := svc.CreateBatch(testCtx, []models.Book{{Name: "The Hobbit"}, {Name: "Dune"}}, tt.atomic)
+			checkErr(t, err, nil)
+
+			for id, want := range tt.wantBook {
+				if got := len(auditOf(t, svcA, id)); got != want { t.Fatalf("entries of book %d = %d, want %d", id, got, want) }
+			}
+		})
+	}
+}
+
+func TestSvcAudit_GetAll(t *testing.T) {
+	svc, svcA := newTestSvcs(t, models.Book{Name: "Dune"}, models.Book{Name: "Emma"})
+	if _, err := svc.UpdateBook(context.Background(), &models.Book{Id: 2, Name: "Emma", Items: 1}); err != nil { t.Fatal(err) }
+
+	list, total, err := svcA.GetAll(&dto.AuditFilter{Entity: schema.AuditEntityBook}, &dto.QueryOpts{Page: 1, Limit: 2})
+	checkErr(t, err, nil)
+
+	if total != 3 || len(list) != 2 { t.Fatalf("total, page = %d, %d, want 3, 2", total, len(list)) }
+	if list[0].EntityId != 2 || list[0].Action != schema.AuditUpdate || list[1].Action != schema.AuditCreate {
+		t.Fatalf("page = %s %d, %s %d, want the newest first", list[0].Action, list[0].EntityId, list[1].Action, list[1].EntityId)
+	}
+	if list[0].Actor != schema.AuditSystemActor || list[0].RequestId != "" {
+		t.Fatalf("actor without context = %q (%q), want %q", list[0].Actor, list[0].RequestId, schema.AuditSystemActor)
+	}
+}
@@ -1,39 +1,77 @@
 package service
 
 import (
+	"context"
+	"errors"
+
+	"go.api.backend/schema"
+	"go.api.backend/schema/dto"
+	"go.api.backend/schema/errs"
 	"go.api.backend/schema/models"
 	"go.api.backend/repo/db"
 )
 
 // SvcBook is a sample for the service interface, defining its methods / functions
 type SvcBook interface {
-	GetAll() ([]models.Book, error)
+	GetAll(opts *dto.QueryOpts, filter *dto.BookFilter) ([]models.Book, int, error)
+	Search(q string, opts *dto.QueryOpts) ([]models.BookHit, int, error)
 	GetByID(Id *uint) (models.Book, error)
-	DelByID(Id *uint) (uint, error)
-	Create(book *models.Book) error
-	UpdateBook(book *models.Book) (uint, error)
+	DelByID(ctx context.Context, Id *uint, version uint) (uint, error)
+	Create(ctx context.Context, book *models.Book) error
+	UpdateBook(ctx context.Context, book *models.Book, columns ...string) (uint, error)
+	Restore(ctx context.Context, Id *uint) (models.Book, error)
+	PurgeByID(ctx context.Context, Id *uint) (uint, error)
+	PurgeTrash(ctx context.Context) (uint, error)
+	CreateBatch(ctx context.Context, books []models.Book, atomic bool) ([]error, error)
+	UpdateBatch(ctx context.Context, books []models.Book, atomic bool) ([]error, error)
+	DelBatch(ctx context.Context, ids []uint, atomic bool) ([]error, error)
+	UpsertBatch(ctx context.Context, books []models.Book) ([]bool, []error, error)
+	ForEach(fn func(book *models.Book) error) error
 }
 
 type svcBook struct {
 	pRepo *db.RepoDbBook
+	pUow  *db.UnitOfWork
 }
 
 // NewSvcBooks create the service Books that handles for the CRUD and other operations
-// It depends on repository for accomplish his responsibility.
+// It depends on repository for accomplish his responsibility. Every write runs in a unit of work, together with
+// its audit log entry (see SvcAudit), attributed to the actor carried by the given context (see WithAuditActor).
 // The code here decouple the data login from the higher level components.
 // As a result, different repositories type can be used with this same logic without any additional changes here.
 //
 // - pRepo [*db.RepoDbBook] ~ Repository instance pointer
-func NewSvcBooks(pRepo *db.RepoDbBook) SvcBook {
-	return &svcBook{pRepo}
+//
+// - pUow [*db.UnitOfWork] ~ Unit of work instance pointer, for the operations spanning several repository calls
+func NewSvcBooks(pRepo *db.RepoDbBook, pUow *db.UnitOfWork) SvcBook {
+	return &svcBook{pRepo, pUow}
 }
 
-// GetAll Get a list of all the books on the repository. If there is a error it's != from null
-// Return a slice of books
-func (s *svcBook) GetAll() ([]models.Book, error) {
+// GetAll Get a page of books from the repository. If there is a error it's != from null
+// Return a slice of books and the total amount of books matching the filter
+//
+// - opts [*dto.QueryOpts] ~ Pagination & sorting options
+//
+// - filter [*dto.BookFilter] ~ Filtering criteria
+func (s *svcBook) GetAll(opts *dto.QueryOpts, filter *dto.BookFilter) ([]models.Book, int, error) {
 	list := make([]models.Book, 0)
+	total, err := (*s.pRepo).GetAll(&list, opts, filter)
+
+	return list, total, err
+}
 
-	return list, (*s.pRepo).GetAll(&list)
+// Search find the books matching a free text query (full-text, prefix matching), most relevant first.
+// Return a page of hits and the total amount of matching books. If the query has no searchable words then err
+// is an errs.Validation
+//
+// - q [string] ~ Free text search query
+//
+// - opts [*dto.QueryOpts] ~ Offset pagination options
+func (s *svcBook) Search(q string, opts *dto.QueryOpts) ([]models.BookHit, int, error) {
+	list := make([]models.BookHit, 0)
+	total, err := (*s.pRepo).Search(&list, q, opts)
+
+	return list, total, err
 }
 
 // GetByID Get A book by its Id. If there is a error it's != from nil
@@ -45,25 +83,284 @@ func (s *svcBook) GetByID(pId *uint) (models.Book, error) {
 	return book, (*s.pRepo).GetByID(&book)
 }
 
-// DelByID delete a book by its Id. If there is a error it's != from nil.
+// ForEach run fn for every book in Id order, streaming them from the repository (e.g. for an export). An fn error
+// stops the iteration and it's returned
+//
+// - fn [func(book *models.Book) error] ~ Function receiving every book, don't keep the book pointer around
+func (s *svcBook) ForEach(fn func(book *models.Book) error) error {
+	return (*s.pRepo).ForEach(fn)
+}
+
+// DelByID delete a book by its Id, moving it to the trash. If there is a error it's != from nil.
 // Row affected (first return data) > 0 if any record was deleted, otherwise if 0 and no error then 404.
 //
+// - ctx [context.Context] ~ Context carrying the actor (see WithAuditActor)
+//
 // - pId [*uint] ~ Book ID pointer
-func (s *svcBook) DelByID(pId *uint) (uint, error) {
-	return (*s.pRepo).DelByID(pId)
+//
+// - version [uint] ~ Expected book version (If-Match), 0 for no precondition
+func (s *svcBook) DelByID(ctx context.Context, pId *uint, version uint) (uint, error) {
+	var n uint
+
+	err := (*s.pUow).Do(ctx, func(tx db.RepoTx) (err error) {
+		n, err = s.del(ctx, tx, *pId, version)
+		return err
+	})
+
+	return n, err
 }
 
 // Create creat a book. If there is a error it's != from nil.
 // If the name key exist then a duplicated key error will be returned
 //
+// - ctx [context.Context] ~ Context carrying the actor (see WithAuditActor)
+//
 // - pBook [*models.Book] ~ New book struct pointer to be created
-func (s *svcBook) Create(pBook *models.Book) error {
-	return (*s.pRepo).Add(pBook)
+func (s *svcBook) Create(ctx context.Context, pBook *models.Book) error {
+	return (*s.pUow).Do(ctx, func(tx db.RepoTx) error { return s.create(ctx, tx, pBook) })
 }
 
-// UpdateBook update a book with the giving data
+// UpdateBook update a book with the giving data. If columns are given then only those are updated (e.g. PATCH).
+// If the book carries a version then the update is conditioned to it (see RepoDbBook.Update)
+//
+// - ctx [context.Context] ~ Context carrying the actor (see WithAuditActor)
 //
 // - pBookDto [*models.Book] ~ Book data to be updated
-func (s *svcBook) UpdateBook(pBook *models.Book) (uint, error) {
-	return (*s.pRepo).Update(pBook)
-}
\ No newline at end of file
+//
+// - columns [...string] ~ Columns to be updated, all the editable ones if none
+func (s *svcBook) UpdateBook(ctx context.Context, pBook *models.Book, columns ...string) (uint, error) {
+	var n uint
+
+	err := (*s.pUow).Do(ctx, func(tx db.RepoTx) (err error) {
+		n, err = s.update(ctx, tx, pBook, columns...)
+		return err
+	})
+
+	return n, err
+}
+
+// Restore take a book out of the trash. If the book isn't in the trash then err is an errs.NotFound
+//
+// - ctx [context.Context] ~ Context carrying the actor (see WithAuditActor)
+//
+// - pId [*uint] ~ Book ID pointer
+func (s *svcBook) Restore(ctx context.Context, pId *uint) (models.Book, error) {
+	before, book := models.Book{Id: *pId}, models.Book{Id: *pId}
+
+	err := (*s.pUow).Do(ctx, func(tx db.RepoTx) error {
+		if err := tx.Books().GetTrashedByID(&before); err != nil { return err }
+		if err := tx.Books().Restore(&book); err != nil { return err }
+		return recordAudit(ctx, tx, schema.AuditRestore, schema.AuditEntityBook, book.Id, &before, &book)
+	})
+
+	return book, err
+}
+
+// PurgeByID delete for good a book in the trash. Row affected (first return data) > 0 if the book was purged,
+// otherwise if 0 and no error then 404.
+//
+// - ctx [context.Context] ~ Context carrying the actor (see WithAuditActor)
+//
+// - pId [*uint] ~ Book ID pointer
+func (s *svcBook) PurgeByID(ctx context.Context, pId *uint) (uint, error) {
+	var n uint
+
+	err := (*s.pUow).Do(ctx, func(tx db.RepoTx) (err error) {
+		n, err = s.purge(ctx, tx, *pId)
+		return err
+	})
+
+	return n, err
+}
+
+// purgeTrashBatch is the maximum amount of books purged by each PurgeTrash transaction
+var purgeTrashBatch uint = 500
+
+// PurgeTrash delete for good all the books in the trash, returning the amount of purged books. The trash is purged
+// in batches of purgeTrashBatch books, each one a bounded delete in its own unit of work together with the audit
+// entries of its books, so a big trash neither holds a long transaction nor is loaded at once. If a batch fails,
+// the previous ones are kept and counted
+//
+// - ctx [context.Context] ~ Context carrying the actor (see WithAuditActor)
+func (s *svcBook) PurgeTrash(ctx context.Context) (uint, error) {
+	var total uint
+
+	for {
+		var n uint
+		err := (*s.pUow).Do(ctx, func(tx db.RepoTx) (err error) {
+			purged := make([]models.Book, 0)
+			if n, err = tx.Books().PurgeTrash(&purged, purgeTrashBatch); err != nil { return err }
+
+			for i := range purged {
+				if err := recordAudit(ctx, tx, schema.AuditPurge, schema.AuditEntityBook, purged[i].Id, &purged[i], nil); err != nil { return err }
+			}
+			return nil
+		})
+		if err != nil { return total, err }
+
+		total += n
+		if n < purgeTrashBatch { return total, nil }
+	}
+}
+
+// region ======== BATCHES ===============================================================
+
+// errBatchRollback is used for rolling back an atomic batch with failed items, it never leaves the service
+var errBatchRollback = errors.New("batch rollback")
+
+// CreateBatch create many books in one transaction. It returns the outcome of every book, in the same order (nil
+// if created, an errs.Conflict if the name is duplicated, etc.), and an error if the batch itself failed. If atomic
+// then any failed book rolls back the whole batch (all-or-nothing), otherwise the rest of the books are kept
+// (best-effort). The created books get their Id set
+//
+// - ctx [context.Context] ~ Context carrying the actor (see WithAuditActor)
+//
+// - books [[]models.Book] ~ New books to be created
+//
+// - atomic [bool] ~ All-or-nothing semantics
+func (s *svcBook) CreateBatch(ctx context.Context, books []models.Book, atomic bool) ([]error, error) {
+	return s.batch(ctx, len(books), atomic, func(tx db.RepoTx, i int) error {
+		return s.create(ctx, tx, &books[i])
+	})
+}
+
+// UpdateBatch update many books in one transaction, the same way than CreateBatch (see it). If a book carries a
+// version then its update is conditioned to it
+//
+// - ctx [context.Context] ~ Context carrying the actor (see WithAuditActor)
+//
+// - books [[]models.Book] ~ Books data to be updated, found by their Id
+//
+// - atomic [bool] ~ All-or-nothing semantics
+func (s *svcBook) UpdateBatch(ctx context.Context, books []models.Book, atomic bool) ([]error, error) {
+	return s.batch(ctx, len(books), atomic, func(tx db.RepoTx, i int) error {
+		_, err := s.update(ctx, tx, &books[i])
+		return err
+	})
+}
+
+// DelBatch delete (move to the trash) many books in one transaction, the same way than CreateBatch (see it). The
+// outcome of a missing book is an errs.NotFound
+//
+// - ctx [context.Context] ~ Context carrying the actor (see WithAuditActor)
+//
+// - ids [[]uint] ~ Id of the books to be deleted
+//
+// - atomic [bool] ~ All-or-nothing semantics
+func (s *svcBook) DelBatch(ctx context.Context, ids []uint, atomic bool) ([]error, error) {
+	return s.batch(ctx, len(ids), atomic, func(tx db.RepoTx, i int) error {
+		if n, err := s.del(ctx, tx, ids[i], 0); err != nil {
+			return err
+		} else if n == 0 {
+			return errs.New(errs.NotFound, schema.ErrNotFound, schema.ErrDetNotFound)
+		}
+
+		return nil
+	})
+}
+
+// UpsertBatch create or update (by name) many books in one transaction, best-effort (see CreateBatch). Besides the
+// outcome of every book, it tells for each one if it was created (true) or updated
+//
+// - ctx [context.Context] ~ Context carrying the actor (see WithAuditActor)
+//
+// - books [[]models.Book] ~ Books to be created or updated
+func (s *svcBook) UpsertBatch(ctx context.Context, books []models.Book) ([]bool, []error, error) {
+	created := make([]bool, len(books))
+
+	outcomes, err := s.batch(ctx, len(books), false, func(tx db.RepoTx, i int) error {
+		var e error
+		created[i], e = s.upsert(ctx, tx, &books[i])
+		return e
+	})
+
+	return created, outcomes, err
+}
+
+// batch run the op for n items in one unit of work, every item inside its own savepoint so a failed item doesn't
+// abort the transaction and all of them get an outcome. If atomic and any item failed, the transaction is rolled
+// back at the end. If the unit of work is retried (see db.UnitOfWork) the outcomes are set again from scratch
+func (s *svcBook) batch(ctx context.Context, n int, atomic bool, op func(tx db.RepoTx, i int) error) ([]error, error) {
+	outcomes := make([]error, n)
+
+	err := (*s.pUow).Do(ctx, func(tx db.RepoTx) error {
+		failed := false
+
+		for i := 0; i < n; i++ {
+			outcomes[i] = tx.Savepoint(func() error { return op(tx, i) })
+			if outcomes[i] != nil { failed = true }
+		}
+
+		if atomic && failed { return errBatchRollback }
+		return nil
+	})
+
+	if err == errBatchRollback { err = nil }
+	return outcomes, err
+}
+// endregion =============================================================================
+
+// region ======== AUDITED WRITES ========================================================
+
+// create add a book through the unit of work transaction, and its audit entry
+func (s *svcBook) create(ctx context.Context, tx db.RepoTx, pBook *models.Book) error {
+	if err := tx.Books().Add(pBook); err != nil { return err }
+
+	return recordAudit(ctx, tx, schema.AuditCreate, schema.AuditEntityBook, pBook.Id, nil, pBook)
+}
+
+// update a book through the unit of work transaction, and add its audit entry with the book before & after. The
+// book row is locked when read, so a concurrent update can't slip between the before snapshot and the update
+func (s *svcBook) update(ctx context.Context, tx db.RepoTx, pBook *models.Book, columns ...string) (uint, error) {
+	before, after := models.Book{Id: pBook.Id}, models.Book{Id: pBook.Id}
+	if err := tx.Books().GetByIDForUpdate(&before); err != nil { return 0, err }	// locked, so before is what's updated
+
+	n, err := tx.Books().Update(pBook, columns...)
+	if err != nil { return 0, err }
+
+	if err := tx.Books().GetByID(&after); err != nil { return 0, err }
+	return n, recordAudit(ctx, tx, schema.AuditUpdate, schema.AuditEntityBook, pBook.Id, &before, &after)
+}
+
+// del delete (move to the trash) a book through the unit of work transaction, and add its audit entry. A 0 and no
+// error means the book doesn't exist (see RepoDbBook.DelByID)
+func (s *svcBook) del(ctx context.Context, tx db.RepoTx, id uint, version uint) (uint, error) {
+	before := models.Book{Id: id}
+	if err := tx.Books().GetByIDForUpdate(&before); err != nil && !errs.Is(err, errs.NotFound) { return 0, err }
+
+	n, err := tx.Books().DelByID(&id, version)
+	if err != nil || n == 0 { return n, err }
+
+	return n, recordAudit(ctx, tx, schema.AuditDelete, schema.AuditEntityBook, id, &before, nil)
+}
+
+// purge delete for good a book in the trash through the unit of work transaction, and add its audit entry. A 0
+// and no error means the book isn't in the trash
+func (s *svcBook) purge(ctx context.Context, tx db.RepoTx, id uint) (uint, error) {
+	before := models.Book{Id: id}
+	if err := tx.Books().GetTrashedByID(&before); errs.Is(err, errs.NotFound) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	n, err := tx.Books().PurgeByID(&id)
+	if err != nil || n == 0 { return n, err }
+
+	return n, recordAudit(ctx, tx, schema.AuditPurge, schema.AuditEntityBook, id, &before, nil)
+}
+
+// upsert create or update (by name) a book through the unit of work transaction, and add its audit entry
+func (s *svcBook) upsert(ctx context.Context, tx db.RepoTx, pBook *models.Book) (bool, error) {
+	before, after := models.Book{}, models.Book{}
+
+	created, err := tx.Books().Upsert(pBook, &before)
+	if err != nil { return false, err }
+
+	after.Id = pBook.Id
+	if err := tx.Books().GetByID(&after); err != nil { return false, err }
+
+	if created { return true, recordAudit(ctx, tx, schema.AuditCreate, schema.AuditEntityBook, pBook.Id, nil, &after) }
+	return false, recordAudit(ctx, tx, schema.AuditUpdate, schema.AuditEntityBook, pBook.Id, &before, &after)
+}
+// endregion =============================================================================
@@ -0,0 +1,465 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"go.api.backend/repo/db"
+	"go.api.backend/repo/mem"
+	"go.api.backend/schema/dto"
+	"go.api.backend/schema/errs"
+	"go.api.backend/schema/models"
+)
+
+// testCtx the context of the test writes, attributed to the "tester" actor
+var testCtx = WithAuditActor(context.Background(), AuditActor{Sub: "tester", RequestId: "req-1"})
+
+// newTestSvcBook create a books service over an in memory repository, seeded with the given books (in order, so
+// their Id are 1, 2, ...)
+func newTestSvcBook(t *testing.T, seed ...models.Book) SvcBook {
+	t.Helper()
+
+	svc, _ := newTestSvcs(t, seed...)
+	return svc
+}
+
+// newTestSvcs create the books service the same way than newTestSvcBook, and the audit log service sharing its
+// unit of work
+func newTestSvcs(t *testing.T, seed ...models.Book) (SvcBook, SvcAudit) {
+	t.Helper()
+
+	repo, audits := mem.NewRepoMemBook(), mem.NewRepoMemAudit()
+	uow := mem.NewUnitOfWork(&repo, &audits)
+	svc := NewSvcBooks(&repo, &uow)
+
+	for i := range seed {
+		if err := svc.Create(testCtx, &seed[i]); err != nil { t.Fatalf("seeding book %q: %v", seed[i].Name, err) }
+	}
+
+	return svc, NewSvcAudit(&audits)
+}
+
+// trash move the book with the given Id to the trash
+func trash(t *testing.T, svc SvcBook, id uint) {
+	t.Helper()
+
+	if n, err := svc.DelByID(testCtx, &id, 0); err != nil || n != 1 { t.Fatalf("trashing book %d: %d, %v", id, n, err) }
+}
+
+// checkErr fail the test if err isn't of the wanted kind, or if it isn't nil when wantErr is nil
+func checkErr(t *testing.T, err error, wantErr *errs.Kind) {
+	t.Helper()
+
+	if wantErr == nil && err != nil { t.Fatalf("unexpected error: %v", err) }
+	if wantErr != nil && !errs.Is(err, *wantErr) { t.Fatalf("error = %v, want kind %v", err, *wantErr) }
+}
+
+func kind(k errs.Kind) *errs.Kind { return &k }
+
+func uintP(n uint) *uint { return &n }
+
+func TestSvcBook_Create(t *testing.T) {
+	tests := []struct {
+		name    string
+		book    models.Book
+		trashed bool
+		wantErr *errs.Kind
+	}{
+		{name: "new book", book: models.Book{Name: "Dune", Items: 3}},
+		{name: "duplicated name", book: models.Book{Name: "The Hobbit"}, wantErr: kind(errs.Conflict)},
+		{name: "duplicated name, case-insensitive", book: models.Book{Name: "THE HOBBIT"}, wantErr: kind(errs.Conflict)},
+		{name: "name of a trashed book", book: models.Book{Name: "The Hobbit"}, trashed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestSvcBook(t, models.Book{Name: "The Hobbit", Items: 1})
+			if tt.trashed { trash(t, svc, 1) }
+
+			err := svc.Create(testCtx, &tt.book)
+			checkErr(t, err, tt.wantErr)
+			if err != nil { return }
+
+			if tt.book.Id == 0 || tt.book.Version != 1 { t.Fatalf("created book = %+v, want an Id and version 1", tt.book) }
+
+			got, err := svc.GetByID(&tt.book.Id)
+			if err != nil || got.Name != tt.book.Name || got.Items != tt.book.Items {
+				t.Fatalf("GetByID = %+v, %v, want %+v", got, err, tt.book)
+			}
+		})
+	}
+}
+
+func TestSvcBook_GetByID(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      uint
+		trashed bool
+		wantErr *errs.Kind
+	}{
+		{name: "existing book", id: 1},
+		{name: "missing book", id: 9, wantErr: kind(errs.NotFound)},
+		{name: "trashed book", id: 1, trashed: true, wantErr: kind(errs.NotFound)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestSvcBook(t, models.Book{Name: "The Hobbit", Items: 1})
+			if tt.trashed { trash(t, svc, 1) }
+
+			got, err := svc.GetByID(&tt.id)
+			checkErr(t, err, tt.wantErr)
+			if err == nil && got.Name != "The Hobbit" { t.Fatalf("GetByID = %+v", got) }
+		})
+	}
+}
+
+func TestSvcBook_GetAll(t *testing.T) {
+	seed := []models.Book{
+		{Name: "The Hobbit", Items: 10},
+		{Name: "Dune", Items: 30},
+		{Name: "The Silmarillion", Items: 20},
+		{Name: "Hyperion", Items: 40},
+	}
+
+	tests := []struct {
+		name      string
+		opts      dto.QueryOpts
+		filter    dto.BookFilter
+		wantIds   []uint
+		wantTotal int
+		wantErr   *errs.Kind
+	}{
+		{name: "first page", opts: dto.QueryOpts{Page: 1, Limit: 2}, wantIds: []uint{1, 2}, wantTotal: 4},
+		{name: "last page", opts: dto.QueryOpts{Page: 2, Limit: 3}, wantIds: []uint{4}, wantTotal: 4},
+		{name: "page out of range", opts: dto.QueryOpts{Page: 3, Limit: 2}, wantIds: []uint{}, wantTotal: 4},
+		{
+			name: "sorted by items desc", opts: dto.QueryOpts{Page: 1, Limit: 10, Sort: []dto.SortField{{Column: "items", Desc: true}}},
+			wantIds: []uint{4, 2, 3, 1}, wantTotal: 4,
+		},
+		{
+			name: "filtered by name", opts: dto.QueryOpts{Page: 1, Limit: 10}, filter: dto.BookFilter{Name: "the "},
+			wantIds: []uint{1, 3}, wantTotal: 2,
+		},
+		{
+			name: "filtered by items range", opts: dto.QueryOpts{Page: 1, Limit: 10},
+			filter: dto.BookFilter{ItemsMin: uintP(20), ItemsMax: uintP(30)}, wantIds: []uint{2, 3}, wantTotal: 2,
+		},
+		{name: "keyset after", opts: dto.QueryOpts{After: 2, Limit: 10}, wantIds: []uint{3, 4}, wantTotal: 4},
+		{name: "keyset before", opts: dto.QueryOpts{Before: 4, Limit: 2}, wantIds: []uint{2, 3}, wantTotal: 4},
+		{
+			name: "keyset after, desc", opts: dto.QueryOpts{After: 3, Limit: 10, Sort: []dto.SortField{{Column: "id", Desc: true}}},
+			wantIds: []uint{2, 1}, wantTotal: 4,
+		},
+		{
+			name: "unknown sorting column", opts: dto.QueryOpts{Page: 1, Limit: 10, Sort: []dto.SortField{{Column: "pages"}}},
+			wantErr: kind(errs.Validation),
+		},
+		{
+			name: "keyset with a non Id sorting", opts: dto.QueryOpts{After: 1, Limit: 10, Sort: []dto.SortField{{Column: "name"}}},
+			wantErr: kind(errs.Validation),
+		},
+		{name: "trash", opts: dto.QueryOpts{Page: 1, Limit: 10}, filter: dto.BookFilter{Deleted: true}, wantIds: []uint{}, wantTotal: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestSvcBook(t, seed...)
+
+			list, total, err := svc.GetAll(&tt.opts, &tt.filter)
+			checkErr(t, err, tt.wantErr)
+			if err != nil { return }
+
+			if total != tt.wantTotal { t.Fatalf("total = %d, want %d", total, tt.wantTotal) }
+			if len(list) != len(tt.wantIds) { t.Fatalf("got %d books, want Ids %v", len(list), tt.wantIds) }
+			for i, b := range list {
+				if b.Id != tt.wantIds[i] { t.Fatalf("book %d Id = %d, want Ids %v", i, b.Id, tt.wantIds) }
+			}
+		})
+	}
+}
+
+func TestSvcBook_Search(t *testing.T) {
+	seed := []models.Book{{Name: "The Hobbit"}, {Name: "Hobbit Tales and Other Hobbies"}, {Name: "Dune"}, {Name: "The Lord of the Rings"}}
+
+	tests := []struct {
+		name        string
+		q           string
+		opts        dto.QueryOpts
+		trashed     bool
+		wantIds     []uint
+		wantTotal   int
+		wantSnippet string
+		wantErr     *errs.Kind
+	}{
+		{name: "prefix", q: "hobb", opts: dto.QueryOpts{Page: 1, Limit: 10}, wantIds: []uint{1, 2}, wantTotal: 2, wantSnippet: "The <mark>Hobbit</mark>"},
+		{name: "all the words", q: "the HOB!", opts: dto.QueryOpts{Page: 1, Limit: 10}, wantIds: []uint{1}, wantTotal: 1},
+		{name: "paginated", q: "hobb", opts: dto.QueryOpts{Page: 2, Limit: 1}, wantIds: []uint{2}, wantTotal: 2},
+		{name: "no hits", q: "emma", opts: dto.QueryOpts{Page: 1, Limit: 10}, wantIds: []uint{}, wantTotal: 0},
+		{name: "trash excluded", q: "hobbit", opts: dto.QueryOpts{Page: 1, Limit: 10}, trashed: true, wantIds: []uint{2}, wantTotal: 1},
+		{name: "no searchable words", q: "&|!", opts: dto.QueryOpts{Page: 1, Limit: 10}, wantErr: kind(errs.Validation)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestSvcBook(t, seed...)
+			if tt.trashed { trash(t, svc, 1) }
+
+			hits, total, err := svc.Search(tt.q, &tt.opts)
+			checkErr(t, err, tt.wantErr)
+			if err != nil { return }
+
+			if total != tt.wantTotal || len(hits) != len(tt.wantIds) { t.Fatalf("Search = %+v, %d, want Ids %v", hits, total, tt.wantIds) }
+			for i, h := range hits {
+				if h.Id != tt.wantIds[i] { t.Fatalf("hit %d Id = %d, want Ids %v", i, h.Id, tt.wantIds) }
+			}
+			if tt.wantSnippet != "" && hits[0].Snippet != tt.wantSnippet { t.Fatalf("snippet = %q, want %q", hits[0].Snippet, tt.wantSnippet) }
+		})
+	}
+}
+
+func TestSvcBook_UpdateBook(t *testing.T) {
+	tests := []struct {
+		name        string
+		book        models.Book
+		columns     []string
+		want        models.Book
+		wantVersion uint
+		wantErr     *errs.Kind
+	}{
+		{name: "all the columns", book: models.Book{Id: 1, Name: "Dune Messiah", Items: 5}, want: models.Book{Name: "Dune Messiah", Items: 5}, wantVersion: 2},
+		{name: "only the given columns", book: models.Book{Id: 1, Name: "ignored", Items: 7}, columns: []string{"items"}, want: models.Book{Name: "Dune", Items: 7}, wantVersion: 2},
+		{name: "matching version", book: models.Book{Id: 1, Name: "Dune", Items: 2, Version: 1}, want: models.Book{Name: "Dune", Items: 2}, wantVersion: 2},
+		{name: "stale version", book: models.Book{Id: 1, Name: "Dune", Items: 2, Version: 7}, wantErr: kind(errs.PreconditionFailed)},
+		{name: "missing book", book: models.Book{Id: 9, Name: "Dune", Items: 2}, wantErr: kind(errs.NotFound)},
+		{name: "duplicated name", book: models.Book{Id: 1, Name: "the hobbit", Items: 2}, wantErr: kind(errs.Conflict)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestSvcBook(t, models.Book{Name: "Dune", Items: 1}, models.Book{Name: "The Hobbit", Items: 1})
+
+			_, err := svc.UpdateBook(testCtx, &tt.book, tt.columns...)
+			checkErr(t, err, tt.wantErr)
+			if err != nil { return }
+
+			got, _ := svc.GetByID(&tt.book.Id)
+			if got.Name != tt.want.Name || got.Items != tt.want.Items || got.Version != tt.wantVersion || tt.book.Version != tt.wantVersion {
+				t.Fatalf("updated book = %+v (entity version %d), want %+v with version %d", got, tt.book.Version, tt.want, tt.wantVersion)
+			}
+		})
+	}
+}
+
+func TestSvcBook_DelByID(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      uint
+		version uint
+		wantN   uint
+		wantErr *errs.Kind
+	}{
+		{name: "existing book", id: 1, wantN: 1},
+		{name: "matching version", id: 1, version: 1, wantN: 1},
+		{name: "missing book", id: 9, wantN: 0},
+		{name: "missing book with version", id: 9, version: 1, wantErr: kind(errs.NotFound)},
+		{name: "stale version", id: 1, version: 3, wantErr: kind(errs.PreconditionFailed)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestSvcBook(t, models.Book{Name: "Dune", Items: 1})
+
+			n, err := svc.DelByID(testCtx, &tt.id, tt.version)
+			checkErr(t, err, tt.wantErr)
+			if err != nil { return }
+
+			if n != tt.wantN { t.Fatalf("deleted = %d, want %d", n, tt.wantN) }
+			if _, err := svc.GetByID(&tt.id); !errs.Is(err, errs.NotFound) { t.Fatalf("GetByID after delete = %v, want NotFound", err) }
+		})
+	}
+}
+
+func TestSvcBook_Trash(t *testing.T) {
+	tests := []struct {
+		name      string
+		id        uint
+		retaken   bool
+		wantErr   *errs.Kind
+	}{
+		{name: "trashed book", id: 1},
+		{name: "book not in the trash", id: 2, wantErr: kind(errs.NotFound)},
+		{name: "missing book", id: 9, wantErr: kind(errs.NotFound)},
+		{name: "name taken meanwhile", id: 1, retaken: true, wantErr: kind(errs.Conflict)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestSvcBook(t, models.Book{Name: "Dune", Items: 1}, models.Book{Name: "Hyperion", Items: 1})
+			trash(t, svc, 1)
+			if tt.retaken { _ = svc.Create(testCtx, &models.Book{Name: "dune"}) }
+
+			book, err := svc.Restore(testCtx, &tt.id)
+			checkErr(t, err, tt.wantErr)
+			if err != nil { return }
+
+			if book.Name != "Dune" || !book.DeletedAt.IsZero() { t.Fatalf("restored book = %+v", book) }
+			if n, _ := svc.PurgeByID(testCtx, &tt.id); n != 0 { t.Fatalf("purged a restored book") }
+		})
+	}
+
+	t.Run("purge", func(t *testing.T) {
+		svc := newTestSvcBook(t, models.Book{Name: "Dune"}, models.Book{Name: "Hyperion"}, models.Book{Name: "Emma"})
+		trash(t, svc, 1)
+		trash(t, svc, 2)
+
+		if n, err := svc.PurgeByID(testCtx, uintP(1)); n != 1 || err != nil { t.Fatalf("PurgeByID = %d, %v, want 1", n, err) }
+		if n, err := svc.PurgeTrash(testCtx); n != 1 || err != nil { t.Fatalf("PurgeTrash = %d, %v, want 1", n, err) }
+		if _, err := svc.Restore(testCtx, uintP(2)); !errs.Is(err, errs.NotFound) { t.Fatalf("Restore of a purged book = %v, want NotFound", err) }
+	})
+
+	t.Run("purge trash in batches", func(t *testing.T) {
+		defer func(batch uint) { purgeTrashBatch = batch }(purgeTrashBatch)
+		purgeTrashBatch = 2
+
+		svc := newTestSvcBook(t, models.Book{Name: "Dune"}, models.Book{Name: "Hyperion"}, models.Book{Name: "Emma"},
+			models.Book{Name: "Ulysses"}, models.Book{Name: "Walden"})
+		for id := uint(1); id <= 4; id++ { trash(t, svc, id) }
+
+		if n, err := svc.PurgeTrash(testCtx); n != 4 || err != nil { t.Fatalf("PurgeTrash = %d, %v, want 4", n, err) }
+		if _, err := svc.GetByID(uintP(5)); err != nil { t.Fatalf("the book out of the trash was purged: %v", err) }
+	})
+}
+
+func TestSvcBook_CreateBatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		books       []string
+		atomic      bool
+		wantFailed  []int
+		wantCreated int
+	}{
+		{name: "all good, atomic", books: []string{"Emma", "Ulysses"}, atomic: true, wantCreated: 2},
+		{name: "duplicated, atomic", books: []string{"Emma", "Dune"}, atomic: true, wantFailed: []int{1}, wantCreated: 0},
+		{name: "duplicated, best-effort", books: []string{"Emma", "Dune"}, wantFailed: []int{1}, wantCreated: 1},
+		{name: "duplicated inside the batch", books: []string{"Emma", "emma", "Ulysses"}, wantFailed: []int{1}, wantCreated: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestSvcBook(t, models.Book{Name: "Dune"})
+
+			books := make([]models.Book, len(tt.books))
+			for i, n := range tt.books { books[i].Name = n }
+
+			outcomes, err := svc.CreateBatch(testCtx, books, tt.atomic)
+			if err != nil { t.Fatalf("unexpected error: %v", err) }
+
+			failed := make([]int, 0)
+			for i, o := range outcomes {
+				if o != nil {
+					failed = append(failed, i)
+					if !errs.Is(o, errs.Conflict) { t.Fatalf("outcome %d = %v, want Conflict", i, o) }
+				}
+			}
+			if len(failed) != len(tt.wantFailed) { t.Fatalf("failed items = %v, want %v", failed, tt.wantFailed) }
+
+			_, total, _ := svc.GetAll(&dto.QueryOpts{Page: 1, Limit: 10}, nil)
+			if total != 1 + tt.wantCreated { t.Fatalf("books = %d, want %d", total, 1 + tt.wantCreated) }
+		})
+	}
+}
+
+func TestSvcBook_UpdateDelBatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		atomic     bool
+		wantItems  uint
+		wantTotal  int
+	}{
+		{name: "atomic", atomic: true, wantItems: 1, wantTotal: 2},
+		{name: "best-effort", wantItems: 9, wantTotal: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestSvcBook(t, models.Book{Name: "Dune", Items: 1}, models.Book{Name: "Emma", Items: 1})
+
+			outcomes, err := svc.UpdateBatch(testCtx, []models.Book{{Id: 1, Name: "Dune", Items: 9}, {Id: 7, Name: "Lost"}}, tt.atomic)
+			if err != nil || outcomes[0] != nil || !errs.Is(outcomes[1], errs.NotFound) { t.Fatalf("UpdateBatch = %v, %v", outcomes, err) }
+
+			book, _ := svc.GetByID(uintP(1))
+			if book.Items != tt.wantItems { t.Fatalf("items = %d, want %d", book.Items, tt.wantItems) }
+
+			outcomes, err = svc.DelBatch(testCtx, []uint{2, 7}, tt.atomic)
+			if err != nil || outcomes[0] != nil || !errs.Is(outcomes[1], errs.NotFound) { t.Fatalf("DelBatch = %v, %v", outcomes, err) }
+
+			_, total, _ := svc.GetAll(&dto.QueryOpts{Page: 1, Limit: 10}, nil)
+			if total != tt.wantTotal { t.Fatalf("books = %d, want %d", total, tt.wantTotal) }
+		})
+	}
+}
+
+func TestSvcBook_UpsertBatch(t *testing.T) {
+	svc := newTestSvcBook(t, models.Book{Name: "Dune", Items: 1})
+
+	books := []models.Book{{Name: "DUNE", Items: 5}, {Name: "Emma", Items: 2}}
+	created, outcomes, err := svc.UpsertBatch(testCtx, books)
+	if err != nil || outcomes[0] != nil || outcomes[1] != nil { t.Fatalf("UpsertBatch = %v, %v", outcomes, err) }
+
+	if created[0] || !created[1] { t.Fatalf("created = %v, want [false true]", created) }
+	if books[0].Id != 1 || books[0].Version != 2 { t.Fatalf("upserted book = %+v, want Id 1 & version 2", books[0]) }
+
+	book, _ := svc.GetByID(uintP(1))
+	if book.Name != "Dune" || book.Items != 5 { t.Fatalf("updated book = %+v, want the original name and 5 items", book) }
+}
+
+func TestSvcBook_ForEach(t *testing.T) {
+	svc := newTestSvcBook(t, models.Book{Name: "Dune"}, models.Book{Name: "Emma"}, models.Book{Name: "Ulysses"})
+	trash(t, svc, 2)
+
+	var ids []uint
+	err := svc.ForEach(func(book *models.Book) error { ids = append(ids, book.Id); return nil })
+	if err != nil || len(ids) != 2 || ids[0] != 1 || ids[1] != 3 { t.Fatalf("ForEach = %v, %v, want [1 3]", ids, err) }
+}
+
+// The in memory unit of work has to honor the rollback of the database one, otherwise the batch tests above prove
+// nothing about the service
+func TestUnitOfWork_Rollback(t *testing.T) {
+	repo, audits := mem.NewRepoMemBook(), mem.NewRepoMemAudit()
+	uow := mem.NewUnitOfWork(&repo, &audits)
+
+	func() {
+		defer func() { _ = recover() }()
+		_ = uow.Do(context.Background(), func(tx db.RepoTx) error {
+			_ = tx.Books().Add(&models.Book{Name: "Dune"})
+			panic("boom")
+		})
+	}()
+
+	if _, total, _ := NewSvcBooks(&repo, &uow).GetAll(&dto.QueryOpts{Page: 1, Limit: 10}, nil); total != 0 {
+		t.Fatalf("books after a panicking unit of work = %d, want 0", total)
+	}
+}
+
+// Concurrent adds of the same name, only one of them wins (the repository is shared by the requests)
+func TestSvcBook_ConcurrentCreate(t *testing.T) {
+	svc := newTestSvcBook(t)
+
+	const n = 20
+	errc := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func() { errc <- svc.Create(testCtx, &models.Book{Name: "Dune"}) }()
+	}
+
+	created := 0
+	for i := 0; i < n; i++ {
+		if err := <-errc; err == nil {
+			created++
+		} else if !errs.Is(err, errs.Conflict) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if created != 1 { t.Fatalf("created = %d, want 1", created) }
+}
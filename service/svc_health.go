@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+
+	"go.api.backend/schema/database"
+	"go.api.backend/schema/dto"
+	"go.api.backend/service/auth"
+	"go.api.backend/service/utils"
+)
+
+const (
+	healthUp      = "up"
+	healthDown    = "down"
+	healthTimeout = 3 * time.Second // Max time for every dependency check
+)
+
+// SvcHealth is the service reporting the app health, for the orchestrators probes
+type SvcHealth interface {
+	Liveness() dto.HealthOut
+	Readiness(ctx context.Context) dto.HealthOut
+}
+
+type svcHealth struct {
+	pgdb    *pg.DB
+	appConf *utils.SvcConfig
+	authSvc *auth.SvcAuthentication
+}
+
+// healthCheck a dependency check, critical checks make the app not ready when they fail
+type healthCheck struct {
+	critical bool
+	check    func(ctx context.Context) error
+}
+
+// NewSvcHealth create the health service, checking the database, the migrations state and the auth providers.
+//
+// - pgdb [*pg.DB] ~ Postgres database instance
+//
+// - svcConfig [*SvcConfig] ~ App conf instance pointer
+//
+// - authSvc [*auth.SvcAuthentication] ~ Authentication service, holding the configured providers
+func NewSvcHealth(pgdb *pg.DB, svcConfig *utils.SvcConfig, authSvc *auth.SvcAuthentication) SvcHealth {
+	return &svcHealth{pgdb, svcConfig, authSvc}
+}
+
+// Liveness tells that the process is alive, it doesn't check any dependency
+func (s *svcHealth) Liveness() dto.HealthOut {
+	return dto.HealthOut{Status: healthUp}
+}
+
+// Readiness check every dependency concurrently and report them. The app is ready (Status == up) only if all
+// the critical dependencies (database & migrations) are up. The auth providers are reported, but they aren't
+// critical: an unreachable provider shouldn't take the app out of the load balancer.
+//
+// - ctx [context.Context] ~ Request context
+func (s *svcHealth) Readiness(ctx context.Context) dto.HealthOut {
+	checks := map[string]healthCheck{
+		"database": {true, s.pgdb.Ping},
+		"migrations": {true, func(ctx context.Context) error {
+			n, err := database.PendingMigrations(ctx, s.appConf, s.pgdb)
+			if err == nil && n > 0 { return fmt.Errorf("%d pending migrations", n) }
+			return err
+		}},
+	}
+
+	for name, provider := range s.authSvc.AuthProviders {
+		if checker, ok := provider.(auth.Checker); ok {
+			checks["auth_" + name] = healthCheck{false, checker.Check}
+		}
+	}
+
+	// Running the checks concurrently
+	out := dto.HealthOut{Status: healthUp, Checks: make(map[string]dto.HealthCheckOut, len(checks))}
+	mu, wg := sync.Mutex{}, sync.WaitGroup{}
+
+	for name, hc := range checks {
+		wg.Add(1)
+		go func(name string, hc healthCheck) {
+			defer wg.Done()
+
+			cCtx, cancel := context.WithTimeout(ctx, healthTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := hc.check(cCtx)
+			res := dto.HealthCheckOut{Status: healthUp, Critical: hc.critical, Latency: time.Since(start).String()}
+			if err != nil { res.Status, res.Detail = healthDown, err.Error() }
+
+			mu.Lock()
+			out.Checks[name] = res
+			if err != nil && hc.critical { out.Status = healthDown }
+			mu.Unlock()
+		}(name, hc)
+	}
+	wg.Wait()
+
+	return out
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"crypto/subtle"
+	"time"
+
+	"go.api.backend/lib"
+	"go.api.backend/repo/db"
+	"go.api.backend/schema"
+	"go.api.backend/schema/errs"
+	"go.api.backend/schema/models"
+)
+
+// resetTkMaxAge is the lifetime of a password reset token
+const resetTkMaxAge = 30 * time.Minute
+
+// SvcUser is the service for managing the users of the default (database) auth provider
+type SvcUser interface {
+	Register(user *models.User, password string) error
+	ChangePassword(username string, oldPassword string, newPassword string) error
+	ForgotPassword(username string) (string, error)
+	ResetPassword(username string, token string, newPassword string) error
+	SetScopes(username string, scopes []string) error
+}
+
+type svcUser struct {
+	pRepo   *db.RepoDbUser
+	pTokens *db.RepoDbRefreshToken
+}
+
+// NewSvcUsers create the service Users that handles the registration and password management of the users
+// authenticated through the default (database) auth provider.
+//
+// - pRepo [*db.RepoDbUser] ~ Repository instance pointer
+//
+// - pTokens [*db.RepoDbRefreshToken] ~ Refresh tokens repository instance pointer, revoked on a password change
+func NewSvcUsers(pRepo *db.RepoDbUser, pTokens *db.RepoDbRefreshToken) SvcUser {
+	return &svcUser{pRepo, pTokens}
+}
+
+// Register create a new user with the default role and no scopes, so a self-registered user can't write (see
+// SetScopes for granting them). If there is a error it's != from nil.
+// If the username exist then an errs.Conflict (duplicated key) error will be returned
+//
+// - pUser [*models.User] ~ New user struct pointer to be created
+//
+// - password [string] ~ User's plain password, only the hash will be stored
+func (s *svcUser) Register(pUser *models.User, password string) error {
+	hash, err := lib.MkPasswordHash(password)
+	if err != nil { return err }
+
+	pUser.PasswordHash = hash
+	pUser.Roles = []string{schema.RolUser}
+	pUser.Scopes = nil
+
+	return (*s.pRepo).Add(pUser)
+}
+
+// ChangePassword change the password of an user, checking first the old (current) one. All the user's refresh
+// tokens are revoked, so every session must login again.
+// If the old password doesn't match then err is an errs.Unauthorized, and if the user isn't a default provider
+// user then err is an errs.NotFound
+//
+// - username [string] ~ User's username
+//
+// - oldPassword [string] ~ Current user's password
+//
+// - newPassword [string] ~ New user's password
+func (s *svcUser) ChangePassword(username string, oldPassword string, newPassword string) error {
+	user := models.User{Username: username}
+
+	if err := (*s.pRepo).GetByUsername(&user); err != nil { return err }
+
+	if !lib.CheckPasswordHash(user.PasswordHash, oldPassword) {
+		return errs.New(errs.Unauthorized, schema.ErrUnauthorized, schema.ErrDetInvalidCred)
+	}
+
+	return s.setPassword(&user, newPassword)
+}
+
+// ForgotPassword create a password reset token for the user, only its hash is stored. If the user doesn't exist
+// then an empty token is returned without error, so the callers can't find out the registered usernames.
+//
+// - username [string] ~ User's username
+func (s *svcUser) ForgotPassword(username string) (string, error) {
+	user := models.User{Username: username}
+
+	if err := (*s.pRepo).GetByUsername(&user); errs.Is(err, errs.NotFound) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	token, err := lib.MkRandomToken(24)
+	if err != nil { return "", err }
+
+	user.ResetTokenHash = lib.MkTokenHash(token)
+	user.ResetTokenExp = time.Now().Add(resetTkMaxAge)
+	if _, err := (*s.pRepo).Update(&user, "reset_token_hash", "reset_token_exp"); err != nil { return "", err }
+
+	return token, nil
+}
+
+// ResetPassword set a new password for the user, using a previously requested password reset token. The token can
+// be used just once, and all the user's refresh tokens are revoked. If the token is wrong or expired then err is an
+// errs.Unauthorized
+//
+// - username [string] ~ User's username
+//
+// - token [string] ~ Password reset token
+//
+// - newPassword [string] ~ New user's password
+func (s *svcUser) ResetPassword(username string, token string, newPassword string) error {
+	user := models.User{Username: username}
+
+	if err := (*s.pRepo).GetByUsername(&user); errs.Is(err, errs.NotFound) {
+		return errs.New(errs.Unauthorized, schema.ErrUnauthorized, schema.ErrDetInvalidResetTk)
+	} else if err != nil {
+		return err
+	}
+
+	valid := subtle.ConstantTimeCompare([]byte(user.ResetTokenHash), []byte(lib.MkTokenHash(token))) == 1
+	if user.ResetTokenHash == "" || !valid || time.Now().After(user.ResetTokenExp) {
+		return errs.New(errs.Unauthorized, schema.ErrUnauthorized, schema.ErrDetInvalidResetTk)
+	}
+
+	return s.setPassword(&user, newPassword)
+}
+
+// SetScopes replace the scopes of an user, e.g. an admin granting books:write. The user gets them on its next login
+// or access token refresh.
+// If the user isn't a default provider user then err is an errs.NotFound
+//
+// - username [string] ~ User's username
+//
+// - scopes [[]string] ~ Granted scopes, empty for revoking them all
+func (s *svcUser) SetScopes(username string, scopes []string) error {
+	user := models.User{Username: username}
+
+	if err := (*s.pRepo).GetByUsername(&user); err != nil { return err }
+
+	user.Scopes = scopes
+	_, err := (*s.pRepo).Update(&user, "scopes")
+	return err
+}
+
+// setPassword hash and store the new password of the user, invalidating any pending password reset token and
+// revoking the user's refresh tokens
+func (s *svcUser) setPassword(pUser *models.User, password string) error {
+	hash, err := lib.MkPasswordHash(password)
+	if err != nil { return err }
+
+	pUser.PasswordHash = hash
+	pUser.ResetTokenHash = ""
+	pUser.ResetTokenExp = time.Time{}
+
+	if _, err := (*s.pRepo).Update(pUser, "password_hash", "reset_token_hash", "reset_token_exp"); err != nil { return err }
+
+	_, err = (*s.pTokens).RevokeBySub(pUser.Username)
+	return err
+}
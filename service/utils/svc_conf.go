@@ -1,53 +1,321 @@
 package utils
 
-import "github.com/tkanos/gonfig"
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/tkanos/gonfig"
+)
 
 // region ======== TYPES =================================================================
 
-// conf unexported configuration schema holder struct
+// conf unexported configuration schema holder struct. Every field can be overlaid by an environment variable
+// (env tag) and by a command line flag (the env tag without the APP_ prefix, in lower case). E.g. APP_DBPASS & -dbpass
 type conf struct {
 
+	// Server
+	ListenAddr string `env:"APP_LISTENADDR" validate:"required"`
+	ShutdownTimeout uint16 `env:"APP_SHUTDOWNTIMEOUT" validate:"required"`			// In-flight requests draining timeout, in seconds
+
 	// Database configuration
-	Addr     string
-	Host     string
-	Port     string
-	User     string
-	DbPass   string
-	Database string
+	Addr     string `env:"APP_ADDR" validate:"required,hostname_port"`
+	Host     string `env:"APP_HOST" validate:"required"`
+	Port     string `env:"APP_PORT" validate:"required,numeric"`
+	User     string `env:"APP_USER" validate:"required"`
+	DbPass   string `env:"APP_DBPASS" validate:"required"`
+	Database string `env:"APP_DATABASE" validate:"required"`
 
 	// Migrations directory
-	MigrationDir string
+	MigrationDir string `env:"APP_MIGRATIONDIR" validate:"required"`
+	AutoMigrate bool `env:"APP_AUTOMIGRATE"`										// Create the schema and apply the pending migrations at boot
 
 	// Environment
-	Debug bool
+	Debug bool `env:"APP_DEBUG"`
 
 	// Cryptographic conf
-	JWTSignKey string
-	TkMaxAge uint8
+	JWTSignKey string `env:"APP_JWTSIGNKEY" validate:"required_without=JWTKeyFiles,omitempty,min=32"`		// HS256 shared key. With JWTKeyFiles, it only verifies the tokens issued before
+	JWTKeyFiles []string `env:"APP_JWTKEYFILES" validate:"omitempty,dive,file"`					// PEM keys (RSA or Ed25519), the first one signs. A JSON array in APP_JWTKEYFILES
+	JWTLegacyUntil string `env:"APP_JWTLEGACYUNTIL" validate:"required_with_all=JWTSignKey JWTKeyFiles,omitempty,excluded_without=JWTSignKey,excluded_without=JWTKeyFiles,datetime=2006-01-02T15:04:05Z07:00"`	// RFC 3339 time. Required by (and only allowed with) JWTSignKey along JWTKeyFiles, its tokens are rejected after it
+	TkMaxAge uint8 `env:"APP_TKMAXAGE" validate:"required"`							// Access token lifetime, in minutes
+	RefreshTkMaxAge uint16 `env:"APP_REFRESHTKMAXAGE" validate:"required"`			// Refresh token lifetime, in hours
+
+	// JWT blocklist (logged out tokens)
+	BlocklistBackend string `env:"APP_BLOCKLISTBACKEND" validate:"required,oneof=postgres memory"`
+	BlocklistPurgeEvery uint16 `env:"APP_BLOCKLISTPURGEEVERY"`						// Expired entries purge interval, in minutes
+
+	// Route protection policy
+	ReadAccess string `env:"APP_READACCESS" validate:"required,oneof=public token"`		// public, or token for requiring a valid access token
+	WriteScopes string `env:"APP_WRITESCOPES" validate:"required"`						// Space separated scopes required by the writes
 
-	// SISEC Auth Provider
-	SisecUrl        string
-	SisecClientId   string
-	SisecClientPass string
+	// Auth providers, see AuthProviderConf. From the .yaml file, or a JSON array in APP_AUTHPROVIDERS
+	AuthProviders []AuthProviderConf `env:"APP_AUTHPROVIDERS" validate:"required,unique=Name,dive"`
+
+	// Outbound HTTP clients (auth providers)
+	OutboundMaxAttempts uint8 `env:"APP_OUTBOUNDMAXATTEMPTS" validate:"required"`				// Attempts of the idempotent requests
+	OutboundBreakerThreshold uint8 `env:"APP_OUTBOUNDBREAKERTHRESHOLD" validate:"required"`		// Consecutive failures opening the circuit breaker
+	OutboundBreakerCooldown uint16 `env:"APP_OUTBOUNDBREAKERCOOLDOWN" validate:"required"`		// Open circuit time, in seconds
+}
+
+// AuthProviderConf is an auth provider declaration (AuthProviders setting). The settings used depend on the provider
+// type, see the provider factories (auth.RegisterProvider)
+type AuthProviderConf struct {
+	Type         string `validate:"required"`					// Provider type, e.g. default, sisec or oidc
+	Name         string `validate:"required,alphanum"`			// Provider name, e.g. /auth/<name>
+	Label        string										// Display name, for the login UIs
+	Enabled      bool
+	Url          string `validate:"omitempty,url"`				// Provider url, e.g. the SISEC endpoint or the OpenID Connect issuer
+	ClientId     string										// App (client) credentials on the provider
+	ClientSecret string										// ❗ APP_<NAME>_CLIENTSECRET, e.g. APP_SISEC_CLIENTSECRET
+	RedirectUrl  string `validate:"omitempty,url"`				// Our /auth/<name>/callback absolute url (redirect providers)
+	Scopes       string										// Space separated requested scopes (redirect providers)
+	RolClaim     string										// Claim holding the roles (OpenID Connect)
+	RoleMap      map[string]string `validate:"omitempty,dive,keys,required,endkeys,oneof=user admin"`	// App role of the provider roles (OpenID Connect), none granted by default
+	ScopeClaim   string										// Claim holding the granted scopes (OpenID Connect)
+	ScopeMap     map[string]string `validate:"omitempty,dive,keys,required,endkeys,oneof=books:write"`	// App scope of the provider scopes (OpenID Connect), none granted by default
+	Timeout      uint16										// Per attempt timeout, in seconds
 }
 
 // SvcConfig exported configuration service struct
 type SvcConfig struct {
 	Path string `string:"Path to the config YAML file"`
+	Args []string `[]string:"Remaining command line arguments, after the flags"`
+	Warnings []string `[]string:"Deprecated settings in use, to be logged on startup"`
 	conf `conf:"Configuration object"`
 }
+
+// confFlag is a flag.Value holding the raw value of a configuration flag, so it can be parsed the same way that
+// the environment variables are
+type confFlag struct {
+	name   string
+	value  string
+	isBool bool
+}
+
+func (f *confFlag) String() string   { return f.value }
+func (f *confFlag) Set(v string) error { f.value = v; return nil }
+func (f *confFlag) IsBoolFlag() bool { return f.isBool }
+
+// legacySisecFile holds the SISEC keys of the configuration file from before the AuthProviders setting, as raw JSON
+// values (the file is converted to JSON), nil when not set. See overlayLegacySisec
+type legacySisecFile struct {
+	SisecUrl        json.RawMessage `env:"-"`
+	SisecClientId   json.RawMessage `env:"-"`
+	SisecClientPass json.RawMessage `env:"-"`
+	SisecTimeout    json.RawMessage `env:"-"`
+}
 // endregion =============================================================================
 
-// NewSvcConfig create a new configuration service.
+// region ======== DEFAULTS ==============================================================
+
+const (
+	confPathEnv  = "APP_CONFIG"
+	confPathFlag = "config"
+	confPathDef  = "conf.dev.yaml"
+	envPrefix    = "APP_"
+	envSecret    = "_CLIENTSECRET"		// Auth providers secrets suffix, e.g. APP_SISEC_CLIENTSECRET
+)
+
+// legacySisec are the SISEC configuration file keys & environment variables from before the AuthProviders setting,
+// with the sisec provider setting each one stands for. See overlayLegacySisec
+var legacySisec = []struct{ key, env, setting string }{
+	{"SisecUrl", "APP_SISECURL", "Url"}, {"SisecClientId", "APP_SISECCLIENTID", "ClientId"},
+	{"SisecClientPass", "APP_SISECCLIENTPASS", "ClientSecret"}, {"SisecTimeout", "APP_SISECTIMEOUT", "Timeout"},
+}
+
+// defaults are applied to the empty settings, before the validation
+var defaults = map[string]string{
+	"ListenAddr":       ":8080",
+	"ShutdownTimeout":  "15",
+	"BlocklistBackend": "memory",
+	"ReadAccess":       "public",
+	"WriteScopes":      "books:write",
+	"OutboundMaxAttempts":      "3",
+	"OutboundBreakerThreshold": "5",
+	"OutboundBreakerCooldown":  "30",
+}
+// endregion =============================================================================
+
+// NewSvcConfig create a new configuration service. The configuration is built in layers, the latest wins:
+//
+// 1. The .yaml file, its path is given by the -config flag or the APP_CONFIG environment variable (conf.dev.yaml by default)
 //
-// - path [string] ~ Path to the configuration .yaml file
-func NewSvcConfig(path string) *SvcConfig {
+// 2. The environment variables, e.g. APP_DBPASS (see the conf env tags)
+//
+// 3. The command line flags, e.g. -dbpass
+//
+// Then the configuration is validated. The returned error lists every missing or invalid setting.
+//
+// - args [[]string] ~ Command line arguments, without the program name (os.Args[1:])
+func NewSvcConfig(args []string) (*SvcConfig, error) {
 	c := conf{}
 
-	err := gonfig.GetConf(path, &c) 				// getting the conf
-	if err != nil {                 // error check
-		panic(err)
+	// Command line flags, one per setting
+	fs := flag.NewFlagSet("app", flag.ContinueOnError)
+	path := fs.String(confPathFlag, "", "path to the configuration .yaml file (env " + confPathEnv + ")")
+	flags := make(map[string]*confFlag)
+
+	t := reflect.TypeOf(c)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.ToLower(strings.TrimPrefix(f.Tag.Get("env"), envPrefix))
+
+		flags[f.Name] = &confFlag{name: name, isBool: f.Type.Kind() == reflect.Bool}
+		fs.Var(flags[f.Name], name, "overrides " + f.Name + " (env " + f.Tag.Get("env") + ")")
+	}
+
+	if err := fs.Parse(args); err != nil { return nil, err }
+
+	// Configuration file path
+	if *path == "" { *path = os.Getenv(confPathEnv) }
+	if *path == "" { *path = confPathDef }
+
+	if err := gonfig.GetConf(*path, &c); err != nil { 			// getting the conf from the file
+		return nil, fmt.Errorf("reading the configuration file %s: %w", *path, err)
+	}
+	legacy := legacySisecFile{}
+	if err := gonfig.GetConf(*path, &legacy); err != nil {
+		return nil, fmt.Errorf("reading the configuration file %s: %w", *path, err)
+	}
+
+	// Overlaying the environment variables and the flags, and setting the defaults
+	var problems []string
+	v := reflect.ValueOf(&c).Elem()
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		raw, source := "", ""
+
+		if env, ok := os.LookupEnv(f.Tag.Get("env")); ok { raw, source = env, f.Tag.Get("env") }
+		if flags[f.Name].value != "" { raw, source = flags[f.Name].value, "-" + flags[f.Name].name }
+		if raw == "" && v.Field(i).IsZero() { raw, source = defaults[f.Name], "default" }
+		if raw == "" { continue }
+
+		if err := setConfValue(v.Field(i), raw); err != nil {
+			problems = append(problems, fmt.Sprintf("%s (%s): %s", f.Name, source, err.Error()))
+		}
+	}
+
+	// Overlaying the deprecated SISEC file keys & environment variables, then the auth providers secrets
+	warning, legacyProblems := overlayLegacySisec(&c, &legacy)
+	problems = append(problems, legacyProblems...)
+	var warnings []string
+	if warning != "" { warnings = append(warnings, warning) }
+
+	for i := range c.AuthProviders {
+		if env, ok := os.LookupEnv(envPrefix + strings.ToUpper(c.AuthProviders[i].Name) + envSecret); ok { c.AuthProviders[i].ClientSecret = env }
+	}
+
+	// Validating
+	if err := validator.New().Struct(c); err != nil {
+		var vErrs validator.ValidationErrors
+		if !errors.As(err, &vErrs) { return nil, err }
+
+		for _, e := range vErrs {
+			name := strings.TrimPrefix(e.Namespace(), t.Name() + ".")		// e.g. AuthProviders[0].Name
+			f, _ := t.FieldByName(strings.FieldsFunc(name, func(r rune) bool { return r == '[' || r == '.' })[0])
+			rule := e.Tag()
+			if e.Param() != "" { rule += "=" + e.Param() }
+
+			if strings.HasPrefix(e.Tag(), "required") {				// required, required_with...
+				problems = append(problems, fmt.Sprintf("%s (%s): missing", name, f.Tag.Get("env")))
+			} else if strings.HasPrefix(e.Tag(), "excluded") {		// excluded_without...
+				problems = append(problems, fmt.Sprintf("%s (%s): only allowed along %s", name, f.Tag.Get("env"), e.Param()))
+			} else {
+				problems = append(problems, fmt.Sprintf("%s (%s): invalid value, must satisfy '%s'", name, f.Tag.Get("env"), rule))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, errors.New("invalid configuration (" + *path + "):\n  - " + strings.Join(problems, "\n  - "))
+	}
+
+	return &SvcConfig{*path, fs.Args(), warnings, c}, nil 			// We are using struct composition here. Hence the anonymous field (https://golangbot.com/inheritance/)
+}
+
+// overlayLegacySisec keep the SISEC settings from before the AuthProviders setting working, the configuration file keys
+// (SisecUrl, SisecClientId, SisecClientPass & SisecTimeout) and their environment variables (APP_SISECURL,
+// APP_SISECCLIENTID, APP_SISECCLIENTPASS & APP_SISECTIMEOUT, winning over the keys): the ones set override the
+// settings of the provider named sisec, which is declared (enabled) if there is none. The new APP_SISEC_CLIENTSECRET
+// wins over them. It returns a deprecation warning if any of them is set, and the problems of their values
+//
+// - c [*conf] ~ Configuration, with the file, environment & flags settings
+//
+// - file [*legacySisecFile] ~ SISEC keys of the configuration file
+func overlayLegacySisec(c *conf, file *legacySisecFile) (string, []string) {
+	var used, problems []string
+	var p *AuthProviderConf
+
+	for _, l := range legacySisec {
+		raw, source := "", ""
+		if v := reflect.ValueOf(file).Elem().FieldByName(l.key).Bytes(); len(v) > 0 && string(v) != "null" {
+			raw, source = rawJSONValue(v), l.key
+		}
+		if env, ok := os.LookupEnv(l.env); ok { raw, source = env, l.env }
+		if source == "" { continue }
+		used = append(used, source)
+
+		if p == nil {
+			for i := range c.AuthProviders {
+				if c.AuthProviders[i].Name == "sisec" { p = &c.AuthProviders[i] }
+			}
+		}
+		if p == nil {
+			c.AuthProviders = append(c.AuthProviders, AuthProviderConf{Type: "sisec", Name: "sisec", Label: "SISEC", Enabled: true})
+			p = &c.AuthProviders[len(c.AuthProviders) - 1]
+		}
+
+		if err := setConfValue(reflect.ValueOf(p).Elem().FieldByName(l.setting), raw); err != nil {
+			problems = append(problems, fmt.Sprintf("AuthProviders[sisec].%s (%s): %s", l.setting, source, err.Error()))
+		}
+	}
+
+	if len(used) == 0 { return "", nil }
+	return strings.Join(used, ", ") + " are deprecated, declare the SISEC settings in the sisec provider of the " +
+		"AuthProviders setting, and pass its client password as APP_SISEC_CLIENTSECRET", problems
+}
+
+// rawJSONValue get a configuration file value as a raw setting value, the JSON strings unquoted (e.g. "10" or 10)
+func rawJSONValue(v json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(v, &s); err == nil { return s }
+
+	return string(v)
+}
+
+// setConfValue parse the raw value according to the setting type, and set it
+//
+// - f [reflect.Value] ~ Setting field
+//
+// - raw [string] ~ Raw value, from the environment or the command line
+func setConfValue(f reflect.Value, raw string) error {
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil { return errors.New("not a boolean") }
+		f.SetBool(b)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, f.Type().Bits())
+		if err != nil { return fmt.Errorf("not an unsigned integer of %d bits", f.Type().Bits()) }
+		f.SetUint(n)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, f.Type().Bits())
+		if err != nil { return fmt.Errorf("not an integer of %d bits", f.Type().Bits()) }
+		f.SetInt(n)
+	case reflect.Slice:													// JSON array, e.g. the auth providers
+		if err := json.Unmarshal([]byte(raw), f.Addr().Interface()); err != nil { return errors.New("not a valid JSON array: " + err.Error()) }
+	default:
+		return errors.New("unsupported setting type " + f.Kind().String())
 	}
 
-	return &SvcConfig{path, c} 			// We are using struct composition here. Hence the anonymous field (https://golangbot.com/inheritance/)
+	return nil
 }
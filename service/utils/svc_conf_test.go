@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/tkanos/gonfig"
+)
+
+// The SISEC file keys & environment variables from before the AuthProviders setting still configure the sisec provider
+func TestOverlayLegacySisec(t *testing.T) {
+	declared := []AuthProviderConf{{Type: "default", Name: "default", Enabled: true}, {Type: "sisec", Name: "sisec", Url: "https://old.test", ClientId: "old"}}
+
+	tests := []struct {
+		name        string
+		providers   []AuthProviderConf
+		file        string // Configuration file content
+		env         map[string]string
+		wantSisec   AuthProviderConf // Zero for no sisec provider
+		wantWarning bool
+		wantProblem string
+	}{
+		{name: "none set", providers: declared[:1]},
+		{
+			name: "declared provider overridden", providers: declared, wantWarning: true,
+			env:       map[string]string{"APP_SISECURL": "https://sisec.test", "APP_SISECCLIENTPASS": "pass", "APP_SISECTIMEOUT": "5"},
+			wantSisec: AuthProviderConf{Type: "sisec", Name: "sisec", Url: "https://sisec.test", ClientId: "old", ClientSecret: "pass", Timeout: 5},
+		},
+		{
+			name: "provider declared", providers: declared[:1], wantWarning: true,
+			env:       map[string]string{"APP_SISECURL": "https://sisec.test", "APP_SISECCLIENTID": "app"},
+			wantSisec: AuthProviderConf{Type: "sisec", Name: "sisec", Label: "SISEC", Enabled: true, Url: "https://sisec.test", ClientId: "app"},
+		},
+		{name: "invalid value", providers: declared, env: map[string]string{"APP_SISECTIMEOUT": "soon"}, wantWarning: true, wantProblem: "APP_SISECTIMEOUT"},
+		{
+			name: "file keys", providers: declared[:1], wantWarning: true,
+			file:      "SisecUrl: \"https://sisec.test\"\nSisecClientId: \"app\"\nSisecClientPass: \"pass\"\nSisecTimeout: 5\n",
+			wantSisec: AuthProviderConf{Type: "sisec", Name: "sisec", Label: "SISEC", Enabled: true, Url: "https://sisec.test", ClientId: "app", ClientSecret: "pass", Timeout: 5},
+		},
+		{
+			name: "environment over file keys", providers: declared, wantWarning: true,
+			file:      "SisecUrl: \"https://file.test\"\nSisecTimeout: \"5\"\n",
+			env:       map[string]string{"APP_SISECURL": "https://sisec.test"},
+			wantSisec: AuthProviderConf{Type: "sisec", Name: "sisec", Url: "https://sisec.test", ClientId: "old", Timeout: 5},
+		},
+		{name: "invalid file value", providers: declared, file: "SisecTimeout: \"soon\"\n", wantWarning: true, wantProblem: "SisecTimeout"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				os.Setenv(k, v)
+				defer os.Unsetenv(k)
+			}
+			c := conf{AuthProviders: append([]AuthProviderConf(nil), tt.providers...)}
+
+			path := filepath.Join(t.TempDir(), "conf.yaml")
+			if err := ioutil.WriteFile(path, []byte("ListenAddr: \":8080\"\n" + tt.file), 0600); err != nil { t.Fatal(err) }
+			file := legacySisecFile{}
+			if err := gonfig.GetConf(path, &file); err != nil { t.Fatal(err) }
+
+			warning, problems := overlayLegacySisec(&c, &file)
+
+			if (warning != "") != tt.wantWarning { t.Fatalf("warning = %q, want one: %v", warning, tt.wantWarning) }
+			if tt.wantProblem != "" {
+				if len(problems) != 1 || !strings.Contains(problems[0], tt.wantProblem) { t.Fatalf("problems = %v, want one about %s", problems, tt.wantProblem) }
+				return
+			}
+			if len(problems) > 0 { t.Fatalf("problems = %v", problems) }
+
+			var got AuthProviderConf
+			for _, p := range c.AuthProviders {
+				if p.Name == "sisec" { got = p }
+			}
+			if got.Name != tt.wantSisec.Name || got.Url != tt.wantSisec.Url || got.ClientId != tt.wantSisec.ClientId ||
+				got.ClientSecret != tt.wantSisec.ClientSecret || got.Timeout != tt.wantSisec.Timeout || got.Enabled != tt.wantSisec.Enabled {
+				t.Fatalf("sisec provider = %+v, want %+v", got, tt.wantSisec)
+			}
+		})
+	}
+}
+
+// The OpenID Connect role & scope maps only grant the app roles & scopes
+func TestAuthProviderConf_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider AuthProviderConf
+		wantErr  string
+	}{
+		{name: "no maps", provider: AuthProviderConf{Type: "oidc", Name: "acme"}},
+		{
+			name: "app grants", provider: AuthProviderConf{Type: "oidc", Name: "acme",
+				RoleMap: map[string]string{"books-admin": "admin"}, ScopeMap: map[string]string{"books-write": "books:write"}},
+		},
+		{name: "unknown role", provider: AuthProviderConf{Type: "oidc", Name: "acme", RoleMap: map[string]string{"books-admin": "root"}}, wantErr: "RoleMap"},
+		{name: "unknown scope", provider: AuthProviderConf{Type: "oidc", Name: "acme", ScopeMap: map[string]string{"books-all": "books:all"}}, wantErr: "ScopeMap"},
+		{name: "empty provider scope", provider: AuthProviderConf{Type: "oidc", Name: "acme", ScopeMap: map[string]string{"": "books:write"}}, wantErr: "ScopeMap"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validator.New().Struct(tt.provider)
+
+			if tt.wantErr == "" && err != nil { t.Fatalf("err = %v", err) }
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) { t.Fatalf("err = %v, want one about %s", err, tt.wantErr) }
+		})
+	}
+}
+
+// JWTLegacyUntil is required by, and only allowed with, JWTSignKey along JWTKeyFiles
+func TestNewSvcConfig_JWTLegacyUntil(t *testing.T) {
+	const signKey, keyFiles, until = "-jwtsignkey=a.test.signing.key.of.32.bytes!!", `-jwtkeyfiles=["svc_conf.go"]`, "-jwtlegacyuntil=2030-01-01T00:00:00Z"
+
+	tests := []struct {
+		name        string
+		flags       []string
+		wantProblem string
+	}{
+		{name: "HS256 key only", flags: []string{signKey}},
+		{name: "key files only", flags: []string{keyFiles}},
+		{name: "both keys", flags: []string{signKey, keyFiles, until}},
+		{name: "both keys without expiry", flags: []string{signKey, keyFiles}, wantProblem: "JWTLegacyUntil (APP_JWTLEGACYUNTIL): missing"},
+		{name: "without key files", flags: []string{signKey, until}, wantProblem: "JWTLegacyUntil (APP_JWTLEGACYUNTIL): only allowed along JWTKeyFiles"},
+		{name: "without HS256 key", flags: []string{keyFiles, until}, wantProblem: "JWTLegacyUntil (APP_JWTLEGACYUNTIL): only allowed along JWTSignKey"},
+		{name: "not a RFC 3339 time", flags: []string{signKey, keyFiles, "-jwtlegacyuntil=2030-01-01"}, wantProblem: "JWTLegacyUntil (APP_JWTLEGACYUNTIL): invalid value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"-config=../../conf.dev.yaml", "-dbpass=secret"}, tt.flags...)
+			_, err := NewSvcConfig(args)
+
+			if tt.wantProblem == "" && err != nil { t.Fatalf("err = %v", err) }
+			if tt.wantProblem != "" && (err == nil || !strings.Contains(err.Error(), tt.wantProblem)) { t.Fatalf("err = %v, want %q", err, tt.wantProblem) }
+		})
+	}
+}
@@ -1,7 +1,12 @@
 package utils
 
 import (
+	"errors"
+
 	"github.com/kataras/iris/v12"
+
+	"go.api.backend/schema"
+	"go.api.backend/schema/errs"
 )
 
 
@@ -31,10 +36,10 @@ func (s SvcResponse) ResWithDataStatus(status int, data interface{}, ctx *iris.C
 	// and client's requirements, instead of ctx.JSON:
 	// ctx.Negotiation().JSON().MsgPack().Protobuf()
 	// ctx.Negotiate(books)
+	(*ctx).StatusCode(status)																														// ❗ before writing the body, otherwise the headers are already sent with 200
 	if _, err := (*ctx).JSON(data); err != nil {																									// Logging *marshal* json if error occurs (come internally from iris)
 		(*ctx).Application().Logger().Error(err.Error())
 	}
-	(*ctx).StatusCode(status)
 }
 
 // ResWithDataStatus create response 200 with specified data converted to json in to the context.
@@ -43,10 +48,10 @@ func (s SvcResponse) ResWithDataStatus(status int, data interface{}, ctx *iris.C
 //
 // - ctx [*iris.Context] ~ Iris Request context
 func (s SvcResponse) ResOKWithData(data interface{}, ctx *iris.Context) {
+	(*ctx).StatusCode(iris.StatusOK)
 	if _, err := (*ctx).JSON(data); err != nil {																									// Logging *marshal* json if error occurs (come internally from iris)
 		(*ctx).Application().Logger().Error(err.Error())
 	}
-	(*ctx).StatusCode(iris.StatusOK)
 }
 
 // ResOK create a response OK but with an empty content (204)
@@ -91,4 +96,56 @@ func (s SvcResponse) ResErr(status int, title string, detail string, ctx *iris.C
 
 	return
 }
+
+// ResFromErr create an 'Error Response' (see ResErr) from an error, setting the status and the title (i18n key)
+// according to the domain error kind (see errs package). Errors that aren't domain errors result in a 500.
+//
+// - err [error] ~ Error to be responded
+//
+// - ctx [*iris.Context] ~ Iris Request context
+func (s SvcResponse) ResFromErr(err error, ctx *iris.Context) {
+	var e *errs.Error
+	if !errors.As(err, &e) { e = errs.Wrap(errs.Internal, schema.ErrGeneric, err) }
+
+	status, ok := errStatus[e.Kind]
+	if !ok { status = iris.StatusInternalServerError }
+
+	title := e.Key
+	if title == "" { title = errKeys[e.Kind] }
+
+	detail := e.Detail
+	if detail == "" { detail = err.Error() }
+
+	if status >= iris.StatusInternalServerError { (*ctx).Application().Logger().Error(err.Error()) }
+
+	s.ResErr(status, title, detail, ctx)
+}
+// endregion =============================================================================
+
+// region ======== ERROR KINDS MAPPING ===================================================
+
+// errStatus the HTTP status for each domain error kind. ❗ A Conflict is a 422 (and not a 409) because the
+// duplicated key errors were documented that way from the beginning
+var errStatus = map[errs.Kind]int{
+	errs.Internal:     iris.StatusInternalServerError,
+	errs.NotFound:     iris.StatusNotFound,
+	errs.Conflict:     iris.StatusUnprocessableEntity,
+	errs.Validation:   iris.StatusUnprocessableEntity,
+	errs.Unauthorized: iris.StatusUnauthorized,
+	errs.Forbidden:    iris.StatusForbidden,
+	errs.Upstream:     iris.StatusBadGateway,
+	errs.PreconditionFailed: iris.StatusPreconditionFailed,
+}
+
+// errKeys the default i18n key for each domain error kind, used when the error doesn't carry one
+var errKeys = map[errs.Kind]string{
+	errs.Internal:     schema.ErrGeneric,
+	errs.NotFound:     schema.ErrNotFound,
+	errs.Conflict:     schema.ErrDuplicateKey,
+	errs.Validation:   schema.ErrVal,
+	errs.Unauthorized: schema.ErrUnauthorized,
+	errs.Forbidden:    schema.ErrForbidden,
+	errs.Upstream:     schema.ErrNetwork,
+	errs.PreconditionFailed: schema.ErrPrecondition,
+}
 // endregion =============================================================================